@@ -4,41 +4,25 @@ import (
 	"github.com/rs/zerolog/log"
 	"net/http"
 	"os"
-	"services/api/ws"
+	"services/api/jobs"
 	"services/types"
-	"sync"
 )
 
 type AddressImportHandler struct {
-	fileUploads      *types.WSMessage
-	uploadInProgress bool // we can only handle a single upload to the address file at a time
-	mutux            *sync.Mutex
+	queue *jobs.Queue
 }
 
-func NewAddressImportHandler() *AddressImportHandler {
-	return &AddressImportHandler{
-		fileUploads:      nil,
-		uploadInProgress: false,
-		mutux:            &sync.Mutex{}}
+func NewAddressImportHandler(queue *jobs.Queue) *AddressImportHandler {
+	ah := &AddressImportHandler{queue: queue}
+	queue.Register(types.AddressJob, ah.processAddressJob)
+	return ah
 }
 
 func (ah *AddressImportHandler) AddressUploadHandler(w http.ResponseWriter, r *http.Request) {
 
-	ah.mutux.Lock()
-
-	if ah.uploadInProgress {
-		log.Error().Msg("file is currently being uploaded")
-		ErrorResponse{ErrorMessage: "address file is currently being uploaded"}.sendResponse(w, r)
-		ah.mutux.Unlock()
-		return
-	}
-
-	ah.uploadInProgress = true
-	ah.mutux.Unlock()
-
 	log.Debug().
 		Str("client", r.RemoteAddr).
-		Str("uri", r.RequestURI).
+		Str("uri", logURI(r)).
 		Msg("Received address file upload request")
 
 	fileName := r.FormValue("fileName")
@@ -55,18 +39,17 @@ func (ah *AddressImportHandler) AddressUploadHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	a := ws.NewFileUploads()
-	ah.fileUploads = a.Add(fileName)
-
-	go func() {
-		defer func() {
-			ah.mutux.Lock()
-			ah.uploadInProgress = false
-			ah.mutux.Unlock()
-			_ = os.Remove(tmpfile)
-		}()
-		ah.ParseAddressFile(tmpfile, fileName)
-	}()
+	job, err := ah.queue.Enqueue(types.UploadJob{
+		JobType:     types.AddressJob,
+		FileName:    fileName,
+		FilePath:    tmpfile,
+		SubmittedBy: actingUser(r),
+	})
+	if err != nil {
+		_ = os.Remove(tmpfile)
+		ErrorResponse{ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
 
-	InProgressResponse{}.sendResponse(w, r)
+	InProgressResponse{JobId: job.Id}.sendResponse(w, r)
 }
@@ -5,15 +5,16 @@ import (
 	"github.com/rs/zerolog/log"
 	"services/db"
 	"services/importdata/csv"
+	"services/types"
 	"services/util"
 	"time"
 )
 
-func (ah AddressImportHandler) ParseAddressFile(fileName, datasetName string) {
+func (ah AddressImportHandler) processAddressJob(job types.UploadJob, status *types.WSMessage) error {
 
 	startTime := time.Now()
-
-	ah.fileUploads.SetUploadStarted()
+	fileName := job.FilePath
+	datasetName := job.FileName
 
 	rows, err := csv.ImportCSVToSlice(fileName)
 	if err != nil {
@@ -22,34 +23,33 @@ func (ah AddressImportHandler) ParseAddressFile(fileName, datasetName string) {
 			Str("method", "parseAddressFile").
 			Str("file", fileName).
 			Msg("Cannot import CSV file")
-		ah.fileUploads.SetUploadError(fmt.Sprintf("cannot import CSV file %s", err))
-		return
+		return fmt.Errorf("cannot import CSV file %s", err)
 	}
 
 	if len(rows) < 2 {
 		log.Warn().
 			Str("fileName", fileName).
 			Msg("The CSV file is empty")
-		ah.fileUploads.SetUploadError("CSV file is empty")
-		return
+		return fmt.Errorf("CSV file is empty")
 	}
 
+	status.SetRowCounts(len(rows)-1, len(rows)-1)
+
 	database, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot connect to database")
-		ah.fileUploads.SetUploadError(fmt.Sprintf("cannot connect to database: %s", err))
-		return
+		return fmt.Errorf("cannot connect to database: %s", err)
 	}
 
-	if err := database.PersistAddresses(rows[0], rows[1:], ah.fileUploads); err != nil {
+	if err := database.PersistAddresses(rows[0], rows[1:], status); err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot persist addresses")
-		ah.fileUploads.SetUploadError(fmt.Sprintf("cannot persist addresses: %s", err))
+		return fmt.Errorf("cannot persist addresses: %s", err)
 	}
 
 	log.Debug().
@@ -57,4 +57,5 @@ func (ah AddressImportHandler) ParseAddressFile(fileName, datasetName string) {
 		Str("elapsedTime", util.FmtDuration(startTime)).
 		Msg("Imported and persisted addresses")
 
+	return nil
 }
@@ -0,0 +1,246 @@
+/*
+Package assemble combines the GB weeks and NI months of a month, quarter or year into one dataset. A respondent
+loaded more than once is kept once, NI variables are given their GB names and each variable is given the same
+type in every row whichever source it came from. Whatever had to be settled to do so is reported as a conflict.
+*/
+package assemble
+
+import (
+	"fmt"
+	"services/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the variables that identify a respondent
+const (
+	caseNo  = "CASENO"
+	hSerial = "HSERIAL"
+)
+
+type conflict struct {
+	variable string
+	source   types.FileSource
+	conflict string
+}
+
+type Assembler struct {
+	niNames       map[string]string
+	variableTypes map[string]types.SavType
+	seen          map[string]bool
+	variables     map[types.FileSource]map[string]bool
+	conflicts     map[conflict]int
+	audit         types.BatchAudit
+}
+
+/*
+NewAssembler takes the type of each variable from its definitions, GB definitions being used before NI ones, and
+renames NI variables as niNames maps them. A variable without a definition is left as it is.
+*/
+func NewAssembler(niNames map[string]string, gb, ni []types.VariableDefinitions) *Assembler {
+	a := &Assembler{
+		niNames:       make(map[string]string, len(niNames)),
+		variableTypes: make(map[string]types.SavType),
+		seen:          make(map[string]bool),
+		variables:     map[types.FileSource]map[string]bool{types.GBSource: {}, types.NISource: {}},
+		conflicts:     make(map[conflict]int),
+	}
+
+	for from, to := range niNames {
+		a.niNames[strings.ToUpper(from)] = strings.ToUpper(to)
+	}
+
+	for _, d := range gb {
+		a.variableTypes[strings.ToUpper(d.Variable)] = d.VariableType
+	}
+
+	for _, d := range ni {
+		name := a.niName(strings.ToUpper(d.Variable))
+		t, ok := a.variableTypes[name]
+		if !ok {
+			a.variableTypes[name] = d.VariableType
+			continue
+		}
+		if t != d.VariableType {
+			c := conflict{name, types.NISource, fmt.Sprintf("defined as %s in GB and %s in NI, %s used", t, d.VariableType, t)}
+			a.conflicts[c] = 0
+		}
+	}
+
+	return a
+}
+
+// the name an NI variable has in the dataset
+func (a *Assembler) niName(name string) string {
+	if to, ok := a.niNames[name]; ok {
+		return to
+	}
+	return name
+}
+
+// a value as a string, as it would be written to a SAV file
+func key(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+/*
+An NI row with its variables given their GB names. Where the row also has a variable of the GB name, the renamed
+variable is used.
+*/
+func (a *Assembler) rename(columns map[string]interface{}) map[string]interface{} {
+	if len(a.niNames) == 0 {
+		return columns
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for name, v := range columns {
+		if _, renamed := row[name]; renamed {
+			continue
+		}
+		to := a.niName(name)
+		if to != name {
+			if _, ok := columns[to]; ok {
+				a.conflicts[conflict{to, types.NISource, fmt.Sprintf("NI has both %s and %s, %s used", name, to, name)}]++
+			}
+		}
+		row[to] = v
+	}
+
+	return row
+}
+
+/*
+Add keeps the first row added for each CASENO and HSERIAL. Rows are added latest first, so the latest load of a
+respondent is kept. A row with neither variable cannot be matched and is always kept.
+*/
+func (a *Assembler) Add(source types.FileSource, columns map[string]interface{}) (map[string]interface{}, bool) {
+	switch source {
+	case types.GBSource:
+		a.audit.GBRows++
+	case types.NISource:
+		a.audit.NIRows++
+		columns = a.rename(columns)
+	}
+
+	c, h := key(columns[caseNo]), key(columns[hSerial])
+	if c != "" || h != "" {
+		k := c + "\x00" + h
+		if a.seen[k] {
+			a.audit.Duplicates++
+			return nil, false
+		}
+		a.seen[k] = true
+	}
+
+	variables := a.variables[source]
+	row := make(map[string]interface{}, len(columns))
+	for name, v := range columns {
+		value, ok, converted := a.harmonise(name, v)
+		if converted {
+			a.audit.Converted++
+		}
+		if !ok {
+			a.conflicts[conflict{name, source, "not a number, left missing"}]++
+			continue
+		}
+		row[name] = value
+		if variables != nil {
+			variables[name] = true
+		}
+	}
+
+	a.audit.Rows++
+	return row, true
+}
+
+/*
+A value of the type of its variable. A string that is not a number is dropped from a numeric variable, leaving
+it missing.
+*/
+func (a *Assembler) harmonise(name string, v interface{}) (value interface{}, ok bool, converted bool) {
+	t, defined := a.variableTypes[name]
+	if !defined {
+		return v, true, false
+	}
+
+	switch value := v.(type) {
+	case string:
+		if t == types.TypeString {
+			return value, true, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, false, true
+		}
+		return f, true, true
+	case float64:
+		if t == types.TypeString {
+			return key(value), true, true
+		}
+		return value, true, false
+	}
+
+	return v, true, false
+}
+
+// Audit has the counts of the rows added so far and of the variables of the dataset
+func (a *Assembler) Audit() types.BatchAudit {
+	audit := a.audit
+
+	variables := make(map[string]bool)
+	for _, source := range a.variables {
+		for name := range source {
+			variables[name] = true
+		}
+	}
+	audit.Variables = len(variables)
+
+	return audit
+}
+
+/*
+Conflicts lists what was settled in assembling the dataset, in variable order. When both sources have rows, a
+variable that only one of them has is left missing in the rows of the other.
+*/
+func (a *Assembler) Conflicts() []types.BatchConflict {
+	var conflicts []types.BatchConflict
+
+	for c, rows := range a.conflicts {
+		conflicts = append(conflicts, types.BatchConflict{Variable: c.variable, Source: c.source, Conflict: c.conflict, Rows: rows})
+	}
+
+	if a.audit.GBRows > 0 && a.audit.NIRows > 0 {
+		gb, ni := a.variables[types.GBSource], a.variables[types.NISource]
+		for name := range gb {
+			if !ni[name] {
+				conflicts = append(conflicts, types.BatchConflict{Variable: name, Source: types.NISource,
+					Conflict: "not in NI, left missing", Rows: a.audit.NIRows})
+			}
+		}
+		for name := range ni {
+			if !gb[name] {
+				conflicts = append(conflicts, types.BatchConflict{Variable: name, Source: types.GBSource,
+					Conflict: "not in GB, left missing", Rows: a.audit.GBRows})
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Variable != conflicts[j].Variable {
+			return conflicts[i].Variable < conflicts[j].Variable
+		}
+		if conflicts[i].Source != conflicts[j].Source {
+			return conflicts[i].Source < conflicts[j].Source
+		}
+		return conflicts[i].Conflict < conflicts[j].Conflict
+	})
+
+	return conflicts
+}
@@ -0,0 +1,94 @@
+package assemble_test
+
+import (
+	"services/api/assemble"
+	"services/types"
+	"testing"
+)
+
+var definitions = []types.VariableDefinitions{
+	{Variable: "CASENO", VariableType: types.TypeString},
+	{Variable: "AGE", VariableType: types.TypeInt8},
+	{Variable: "PCODE", VariableType: types.TypeString},
+}
+
+func TestDuplicates(t *testing.T) {
+	a := assemble.NewAssembler(nil, definitions, nil)
+
+	rows := []struct {
+		source  types.FileSource
+		columns map[string]interface{}
+		keep    bool
+	}{
+		{types.GBSource, map[string]interface{}{"CASENO": "1", "HSERIAL": 10.0, "AGE": 30.0}, true},
+		{types.GBSource, map[string]interface{}{"CASENO": "1", "HSERIAL": 10.0, "AGE": 29.0}, false},
+		{types.GBSource, map[string]interface{}{"CASENO": "2", "HSERIAL": 10.0}, true},
+		{types.NISource, map[string]interface{}{"AGE": 40.0}, true},
+		{types.NISource, map[string]interface{}{"AGE": 41.0}, true},
+	}
+
+	for i, r := range rows {
+		if _, keep := a.Add(r.source, r.columns); keep != r.keep {
+			t.Errorf("row %d: kept %t, want %t", i, keep, r.keep)
+		}
+	}
+
+	audit := a.Audit()
+	if audit.GBRows != 3 || audit.NIRows != 2 || audit.Duplicates != 1 || audit.Rows != 4 || audit.Variables != 3 {
+		t.Errorf("unexpected audit: %+v", audit)
+	}
+}
+
+func TestHarmonise(t *testing.T) {
+	a := assemble.NewAssembler(nil, definitions, nil)
+
+	row, _ := a.Add(types.NISource, map[string]interface{}{"CASENO": 123.0, "AGE": " 34", "PCODE": "BT1 1AA", "NEW": "x"})
+	if row["CASENO"] != "123" || row["AGE"] != 34.0 || row["PCODE"] != "BT1 1AA" || row["NEW"] != "x" {
+		t.Errorf("unexpected row: %v", row)
+	}
+
+	row, _ = a.Add(types.NISource, map[string]interface{}{"CASENO": "124", "AGE": "unknown"})
+	if _, ok := row["AGE"]; ok {
+		t.Errorf("a value that is not a number should be dropped from a numeric variable: %v", row)
+	}
+
+	if converted := a.Audit().Converted; converted != 3 {
+		t.Errorf("expected 3 values converted, got %d", converted)
+	}
+}
+
+func TestUKConflicts(t *testing.T) {
+	ni := []types.VariableDefinitions{
+		{Variable: "HOUTCOME", VariableType: types.TypeInt8},
+		{Variable: "PCODE", VariableType: types.TypeInt32},
+	}
+	a := assemble.NewAssembler(map[string]string{"houtcome": "HOUT"}, definitions, ni)
+
+	a.Add(types.GBSource, map[string]interface{}{"CASENO": "1", "HOUT": 11.0, "PCODE": "NP10 8XG", "AGE": 30.0})
+	row, _ := a.Add(types.NISource, map[string]interface{}{"CASENO": "2", "HOUTCOME": 12.0, "HOUT": 20.0, "NIONLY": 1.0})
+
+	if row["HOUT"] != 12.0 {
+		t.Errorf("HOUTCOME should be renamed HOUT and used over HOUT: %v", row)
+	}
+	if _, ok := row["HOUTCOME"]; ok {
+		t.Errorf("HOUTCOME should not be kept: %v", row)
+	}
+
+	want := []types.BatchConflict{
+		{Variable: "AGE", Source: types.NISource, Conflict: "not in NI, left missing", Rows: 1},
+		{Variable: "HOUT", Source: types.NISource, Conflict: "NI has both HOUTCOME and HOUT, HOUTCOME used", Rows: 1},
+		{Variable: "NIONLY", Source: types.GBSource, Conflict: "not in GB, left missing", Rows: 1},
+		{Variable: "PCODE", Source: types.NISource, Conflict: "defined as string in GB and int32 in NI, string used", Rows: 0},
+		{Variable: "PCODE", Source: types.NISource, Conflict: "not in NI, left missing", Rows: 1},
+	}
+
+	conflicts := a.Conflicts()
+	if len(conflicts) != len(want) {
+		t.Fatalf("expected %d conflicts, got %v", len(want), conflicts)
+	}
+	for i := range want {
+		if conflicts[i] != want[i] {
+			t.Errorf("conflict %d = %+v, want %+v", i, conflicts[i], want[i])
+		}
+	}
+}
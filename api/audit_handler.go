@@ -8,15 +8,25 @@ import (
 	"strconv"
 )
 
+// the number of authentication events returned by default and at most
+const (
+	defaultAuthAuditLimit = 1000
+	maxAuthAuditLimit     = 10000
+)
+
 type AuditHandler struct{}
 
 func NewAuditHandler() *AuditHandler {
 	return &AuditHandler{}
 }
 
+/*
+The audits of every upload, or with ?user= only those of one user. The same filter applies to the audits of a
+year, month or week.
+*/
 func (a AuditHandler) HandleAllAuditRequest(w http.ResponseWriter, r *http.Request) {
 
-	res, err := a.GetAllAudits()
+	res, err := a.GetAllAudits(r.URL.Query().Get("user"))
 
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
@@ -38,7 +48,7 @@ func (a AuditHandler) HandleYearAuditRequest(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	res, err := a.GetAuditsForYear(types.Year(yearNo))
+	res, err := a.GetAuditsForYear(types.Year(yearNo), r.URL.Query().Get("user"))
 
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
@@ -70,7 +80,7 @@ func (a AuditHandler) HandleWeekAuditRequest(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	res, err := a.GetAuditsForWeek(types.Week(weekNo), types.Year(yearNo))
+	res, err := a.GetAuditsForWeek(types.Week(weekNo), types.Year(yearNo), r.URL.Query().Get("user"))
 
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
@@ -102,13 +112,116 @@ func (a AuditHandler) HandleMonthAuditRequest(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	res, err := a.GetAuditsForMonth(types.Month(monthNo), types.Year(yearNo))
+	res, err := a.GetAuditsForMonth(types.Month(monthNo), types.Year(yearNo), r.URL.Query().Get("user"))
+
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+
+}
+
+/*
+Download the validation report for an upload, as JSON or, with ?format=csv, as a CSV file
+*/
+func (a AuditHandler) HandleValidationReportRequest(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	auditId := vars["auditId"]
+
+	auditNo, err := strconv.Atoi(auditId)
+	if err != nil {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid audit id: %s, expected an integer", auditId)}.sendResponse(w, r)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid format: %s, expected one of json or csv", format)}.sendResponse(w, r)
+		return
+	}
+
+	res, err := a.GetValidationReport(auditNo)
+
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if format == "csv" {
+		SendCSVResponse{FileName: fmt.Sprintf("validation_report_%d.csv", auditNo)}.sendResponse(w, r, &res)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+/*
+The latest logins, failed logins, lockouts, unlocks and logouts, filtered by the user, client and event query
+parameters. At most limit are returned, 1000 by default.
+*/
+func (a AuditHandler) HandleLoginAuditRequest(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
 
+	limit, err := parsePageParameter(values, "limit", defaultAuthAuditLimit, maxAuthAuditLimit)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
 	}
 
+	query := types.AuthAuditQuery{
+		Username: values.Get("user"),
+		Client:   values.Get("client"),
+		Event:    types.AuthEvent(values.Get("event")),
+		Limit:    limit,
+	}
+
+	res, err := a.GetAuthAudits(query)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
 	SendDataResponse{}.sendResponse(w, r, res)
+}
 
+/*
+The batches created and the datasets assembled for them with the conflicts each settled, or with ?user= only
+those of one user
+*/
+func (a AuditHandler) HandleBatchAuditRequest(w http.ResponseWriter, r *http.Request) {
+	res, err := a.GetBatchAudits(r.URL.Query().Get("user"))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+// Each load of variable definitions and value labels, or with ?user= only those of one user
+func (a AuditHandler) HandleMetadataAuditRequest(w http.ResponseWriter, r *http.Request) {
+	res, err := a.GetMetadataChanges(r.URL.Query().Get("user"))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
 }
@@ -6,7 +6,7 @@ import (
 	"services/types"
 )
 
-func (a AuditHandler) GetAllAudits() ([]types.Audit, error) {
+func (a AuditHandler) GetAllAudits(user string) ([]types.Audit, error) {
 
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
@@ -14,7 +14,7 @@ func (a AuditHandler) GetAllAudits() ([]types.Audit, error) {
 		return nil, err
 	}
 
-	res, err := dbase.GetAllAudits()
+	res, err := dbase.GetAllAudits(user)
 	if err != nil {
 		return nil, err
 	}
@@ -22,28 +22,28 @@ func (a AuditHandler) GetAllAudits() ([]types.Audit, error) {
 	return res, nil
 }
 
-func (a AuditHandler) GetAuditsForYear(year types.Year) ([]types.Audit, error) {
+func (a AuditHandler) GetAuditsForYear(year types.Year, user string) ([]types.Audit, error) {
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
 		return nil, err
 	}
 
-	res, err := dbase.GetAuditsByYear(year)
+	res, err := dbase.GetAuditsByYear(year, user)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (a AuditHandler) GetAuditsForWeek(week types.Week, year types.Year) ([]types.Audit, error) {
+func (a AuditHandler) GetAuditsForWeek(week types.Week, year types.Year, user string) ([]types.Audit, error) {
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
 		return nil, err
 	}
 
-	res, err := dbase.GetAuditsByYearWeek(week, year)
+	res, err := dbase.GetAuditsByYearWeek(week, year, user)
 	if err != nil {
 		return nil, err
 	}
@@ -51,17 +51,66 @@ func (a AuditHandler) GetAuditsForWeek(week types.Week, year types.Year) ([]type
 	return res, nil
 }
 
-func (a AuditHandler) GetAuditsForMonth(month types.Month, year types.Year) ([]types.Audit, error) {
+func (a AuditHandler) GetAuditsForMonth(month types.Month, year types.Year, user string) ([]types.Audit, error) {
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
 		return nil, err
 	}
 
-	res, err := dbase.GetAuditsByYearMonth(month, year)
+	res, err := dbase.GetAuditsByYearMonth(month, year, user)
 	if err != nil {
 		return nil, err
 	}
 
 	return res, nil
 }
+
+func (a AuditHandler) GetValidationReport(auditId int) ([]types.ValidationFailure, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return nil, err
+	}
+
+	if _, err := dbase.GetAudit(auditId); err != nil {
+		return nil, err
+	}
+
+	res, err := dbase.GetValidationReport(auditId)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (a AuditHandler) GetAuthAudits(query types.AuthAuditQuery) ([]types.AuthAudit, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return nil, err
+	}
+
+	return dbase.GetAuthAudits(query)
+}
+
+func (a AuditHandler) GetBatchAudits(user string) (types.BatchAudits, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.BatchAudits{}, err
+	}
+
+	return dbase.GetBatchAudits(user)
+}
+
+func (a AuditHandler) GetMetadataChanges(user string) ([]types.MetadataChange, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return nil, err
+	}
+
+	return dbase.GetMetadataChanges(user)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/db"
+	"services/types"
+	"strings"
+)
+
+// the routes that can be used without a token
+var publicRoutes = map[string]bool{
+	"/login/{user}": true,
+}
+
+type sessionKey struct{}
+
+/*
+The token of a request, from its Authorization header. Browsers cannot set headers on a web socket, so a web
+socket upgrade can give its token in the token query parameter instead.
+*/
+func requestToken(r *http.Request) string {
+	const bearer = "bearer "
+	if h := r.Header.Get("Authorization"); len(h) > len(bearer) && strings.EqualFold(h[:len(bearer)], bearer) {
+		return strings.TrimSpace(h[len(bearer):])
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// the session of an authenticated request
+func requestSession(r *http.Request) (types.Session, bool) {
+	session, ok := r.Context().Value(sessionKey{}).(types.Session)
+	return session, ok
+}
+
+/*
+Authorise rejects a request unless its user has one of the roles allowed. Every role can view and an admin can do
+anything.
+*/
+func Authorise(handler http.HandlerFunc, allowed ...types.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := requestSession(r)
+		if !ok {
+			UnauthorizedResponse{ErrorMessage: "not logged in"}.sendResponse(w, r)
+			return
+		}
+
+		if !types.HasRole(session.Roles, allowed...) {
+			log.Warn().
+				Str("user", session.Username).
+				Str("uri", logURI(r)).
+				Msg("Request forbidden")
+			ForbiddenResponse{ErrorMessage: fmt.Sprintf("user %s is not allowed to do this", session.Username)}.sendResponse(w, r)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+/*
+Authenticate rejects a request to any route other than a public one unless it carries the token of a session
+that has not expired. The session is passed on to the handler in the request's context.
+*/
+func (l LoginHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil && publicRoutes[template] {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		token := requestToken(r)
+		if token == "" {
+			UnauthorizedResponse{ErrorMessage: "not logged in"}.sendResponse(w, r)
+			return
+		}
+
+		dbase, err := db.GetDefaultPersistenceImpl()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Cannot connect to database")
+			ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+			return
+		}
+
+		session, err := dbase.GetSession(hashToken(token))
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Str("client", r.RemoteAddr).
+				Str("uri", logURI(r)).
+				Msg("Request rejected")
+			UnauthorizedResponse{ErrorMessage: err.Error()}.sendResponse(w, r)
+			return
+		}
+
+		// roles are read on every request so that a change to them takes effect at once
+		if session.Roles, err = dbase.GetUserRoles(session.Username); err != nil {
+			ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, session)))
+	})
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"services/types"
+	"testing"
+	"time"
+)
+
+func TestRequestToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/dashboard?token=query", nil)
+	assert.Equal(t, "", requestToken(r), "query token accepted without a web socket upgrade")
+
+	r.Header.Set("Authorization", "Bearer abc")
+	assert.Equal(t, "abc", requestToken(r))
+
+	r = httptest.NewRequest("GET", "/ws?token=query", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	assert.Equal(t, "query", requestToken(r))
+}
+
+func TestAuthenticateWithoutToken(t *testing.T) {
+	router := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/login/{user}", ok).Methods(http.MethodGet)
+	router.HandleFunc("/dashboard", ok).Methods(http.MethodGet)
+	router.Use(LoginHandler{}.Authenticate)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/login/lfs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestIssueToken(t *testing.T) {
+	l := LoginHandler{tokenLifetime: 30 * time.Minute, sessionLifetime: time.Hour}
+	start := time.Now().UTC()
+	session := types.Session{Username: "lfs", CreatedAt: start}
+
+	assert.NoError(t, l.issueToken(&session, start))
+	assert.Equal(t, hashToken(session.Token), session.TokenHash)
+	assert.Equal(t, start.Add(30*time.Minute), session.ExpiresAt)
+
+	token := session.Token
+	assert.NoError(t, l.issueToken(&session, start.Add(45*time.Minute)))
+	assert.NotEqual(t, token, session.Token)
+	assert.Equal(t, start.Add(time.Hour), session.ExpiresAt, "token outlives its session")
+}
+
+func TestAuthorise(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	handler := Authorise(ok, types.BatchManager)
+
+	for _, tc := range []struct {
+		roles []types.Role
+		code  int
+	}{
+		{nil, http.StatusForbidden},
+		{[]types.Role{types.Viewer, types.Uploader}, http.StatusForbidden},
+		{[]types.Role{types.BatchManager}, http.StatusOK},
+		{[]types.Role{types.Admin}, http.StatusOK},
+	} {
+		r := httptest.NewRequest("POST", "/batches/annual/2019", nil)
+		session := types.Session{Username: "lfs", Roles: tc.roles}
+		r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, session))
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+		assert.Equal(t, tc.code, w.Code, "roles %v", tc.roles)
+	}
+
+	w := httptest.NewRecorder()
+	Authorise(ok, types.Viewer)(w, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLoginBackoff(t *testing.T) {
+	l := LoginHandler{loginBackoff: time.Second, maxLoginBackoff: 10 * time.Second}
+	now := time.Now().UTC()
+	last := now.Add(-time.Second)
+
+	assert.Equal(t, time.Duration(0), l.backoff(0, nil, now))
+	assert.Equal(t, time.Duration(0), l.backoff(1, &last, now), "one failure a second ago")
+	assert.Equal(t, 3*time.Second, l.backoff(3, &last, now), "three failures wait 4s")
+	assert.Equal(t, 9*time.Second, l.backoff(60, &last, now), "wait capped at maxLoginBackoff")
+}
+
+func TestActingUser(t *testing.T) {
+	r := httptest.NewRequest("POST", "/batches/monthly/2019/1", nil)
+	r.Header.Set("user", "header")
+	assert.Equal(t, "unknown", actingUser(r), "user header ignored without a session")
+
+	r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, types.Session{Username: "lfs"}))
+	assert.Equal(t, "lfs", actingUser(r))
+}
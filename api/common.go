@@ -6,6 +6,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"services/config"
 	"services/db"
 	"services/types"
 	"services/util"
@@ -48,12 +50,26 @@ func SaveStreamToTempFile(w http.ResponseWriter, r *http.Request) (string, error
 
 	startTime := time.Now()
 
-	tmpfile, err := ioutil.TempFile("", fileName)
+	// uploads are kept until the job that processes them has finished, so use the
+	// configured upload directory rather than the system temporary directory if one is set
+	uploadDirectory := config.Config.Jobs.UploadDirectory
+	if uploadDirectory != "" {
+		if err := os.MkdirAll(uploadDirectory, 0755); err != nil {
+			return "", fmt.Errorf("cannot create upload directory: %s ", err)
+		}
+	}
+
+	tmpfile, err := ioutil.TempFile(uploadDirectory, fileName)
 	if err != nil {
 		return "", fmt.Errorf("cannot create temporary file: %s ", err)
 	}
 
 	n, err := io.Copy(tmpfile, file)
+	if err != nil {
+		_ = tmpfile.Close()
+		_ = os.Remove(tmpfile.Name())
+		return "", fmt.Errorf("cannot save uploaded file: %s ", err)
+	}
 
 	log.Debug().
 		Str("fileName", fileName).
@@ -106,6 +122,17 @@ func FindGBBatch(weekNo, yearNo int) (types.GBBatchItem, error) {
 	return info, nil
 }
 
+/*
+The user making a request, as logged in. This is recorded against changes
+*/
+func actingUser(r *http.Request) string {
+	session, ok := requestSession(r)
+	if !ok || session.Username == "" {
+		return "unknown"
+	}
+	return session.Username
+}
+
 func intConversion(year string) int {
 	yr, err := strconv.Atoi(year)
 	if err != nil {
@@ -113,3 +140,15 @@ func intConversion(year string) int {
 	}
 	return yr
 }
+
+// the database connection, logging a failure to connect
+func connectDatabase() (db.Persistence, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return nil, fmt.Errorf("cannot connect to database: %s", err)
+	}
+	return database, nil
+}
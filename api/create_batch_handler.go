@@ -40,7 +40,7 @@ func (b BatchHandler) CreateMonthlyBatchHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err := b.generateMonthBatchId(mth, yr, description)
+	err := b.generateMonthBatchId(mth, yr, description, actingUser(r))
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
@@ -76,7 +76,7 @@ func (b BatchHandler) CreateQuarterlyBatchHandler(w http.ResponseWriter, r *http
 	}
 
 	// Do
-	res, qErr := b.generateQuarterBatchId(q, yr, description)
+	res, audit, qErr := b.generateQuarterBatchId(q, yr, description, actingUser(r))
 	if res != nil {
 		BadDataResponse{
 			Status:       Error,
@@ -92,8 +92,8 @@ func (b BatchHandler) CreateQuarterlyBatchHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	// Return
-	OkayResponse{OK}.sendResponse(w, r)
+	// Return the counts of the assembled dataset
+	SendDataResponse{}.sendResponse(w, r, audit)
 }
 
 func (b BatchHandler) CreateAnnualBatchHandler(w http.ResponseWriter, r *http.Request) {
@@ -112,7 +112,7 @@ func (b BatchHandler) CreateAnnualBatchHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Do
-	res, aErr := b.generateYearBatchId(yr, description)
+	res, audit, aErr := b.generateYearBatchId(yr, description, actingUser(r))
 	if res != nil {
 		BadDataResponse{
 			Status:       Error,
@@ -127,4 +127,41 @@ func (b BatchHandler) CreateAnnualBatchHandler(w http.ResponseWriter, r *http.Re
 			ErrorMessage: aErr.Error()}.sendResponse(w, r)
 		return
 	}
+
+	// Return the counts of the assembled dataset
+	SendDataResponse{}.sendResponse(w, r, audit)
+}
+
+/*
+Harmonise the GB weeks and NI month of a monthly batch into its UK dataset, returning the counts of the dataset
+and the conflicts that were settled
+*/
+func (b BatchHandler) HarmoniseMonthlyBatchHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	year := vars["year"]
+	month := vars["month"]
+
+	yr := intConversion(year)
+	if yr == -1 {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid year: %s, expected an integer", year)}.sendResponse(w, r)
+		return
+	}
+
+	mth := intConversion(month)
+	if mth == -1 {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid period: %s, expected one of 1-12", month)}.sendResponse(w, r)
+		return
+	}
+
+	audit, err := b.harmoniseMonthlyBatch(mth, yr, actingUser(r))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, audit)
 }
@@ -3,11 +3,13 @@ package api
 import (
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"services/api/assemble"
+	"services/config"
 	"services/db"
 	"services/types"
 )
 
-func (b BatchHandler) generateMonthBatchId(month int, year int, description string) error {
+func (b BatchHandler) generateMonthBatchId(month int, year int, description, user string) error {
 
 	if month < 1 || month > 12 {
 		return fmt.Errorf("the month value is %d, must be between 1 and 12", month)
@@ -28,6 +30,7 @@ func (b BatchHandler) generateMonthBatchId(month int, year int, description stri
 		Month:       month,
 		Status:      0,
 		Description: description,
+		CreatedBy:   user,
 	}
 
 	if err = dbase.CreateMonthlyBatch(batch); err != nil {
@@ -37,7 +40,49 @@ func (b BatchHandler) generateMonthBatchId(month int, year int, description stri
 	return nil
 }
 
-func (b BatchHandler) generateQuarterBatchId(quarter int, year int, description string) ([]types.MonthlyBatch, error) {
+/*
+The GB and NI datasets of a batch are combined with the types of their variable definitions, NI variables being
+renamed as the harmonise configuration maps them
+*/
+func batchAssembler(dbase db.Persistence) (*assemble.Assembler, error) {
+	gb, err := dbase.GetAllGBDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+	ni, err := dbase.GetAllNIDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+
+	niNames := make(map[string]string, len(config.Config.Harmonise.NI))
+	for _, c := range config.Config.Harmonise.NI {
+		niNames[c.From] = c.To
+	}
+
+	return assemble.NewAssembler(niNames, gb, ni), nil
+}
+
+func (b BatchHandler) harmoniseMonthlyBatch(month int, year int, user string) (types.BatchAudit, error) {
+
+	if month < 1 || month > 12 {
+		return types.BatchAudit{}, fmt.Errorf("the month value is %d, must be between 1 and 12", month)
+	}
+
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.BatchAudit{}, err
+	}
+
+	assembler, err := batchAssembler(dbase)
+	if err != nil {
+		return types.BatchAudit{}, err
+	}
+
+	return dbase.HarmoniseMonthlyBatch(month, year, user, assembler)
+}
+
+func (b BatchHandler) generateQuarterBatchId(quarter int, year int, description, user string) ([]types.MonthlyBatch, types.BatchAudit, error) {
 	// Set batch variables
 	batch := types.QuarterlyBatch{
 		Id:          0,
@@ -45,85 +90,99 @@ func (b BatchHandler) generateQuarterBatchId(quarter int, year int, description
 		Year:        year,
 		Status:      0,
 		Description: description,
+		CreatedBy:   user,
 	}
 
 	// Validate quarter
 	if quarter < 1 || quarter > 4 {
-		return nil, fmt.Errorf("the quarter value is %d, must be between 1 and 4", quarter)
+		return nil, types.BatchAudit{}, fmt.Errorf("the quarter value is %d, must be between 1 and 4", quarter)
 	}
 
 	// Establish DB connection
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
-		return nil, err
+		return nil, types.BatchAudit{}, err
 	}
 
 	// Check if quarter batch already exists
 	if found := dbase.QuarterBatchExists(quarter, year); found {
-		return nil, fmt.Errorf("q%d batch for year %d already exists", quarter, year)
+		return nil, types.BatchAudit{}, fmt.Errorf("q%d batch for year %d already exists", quarter, year)
 	}
 
 	// Ensure successful monthly exist
 	result, err := dbase.ValidateMonthsForQuarterlyBatch(quarter, year)
 	if result != nil {
-		return result, fmt.Errorf("3 valid months for Q%d, %d required", quarter, year)
+		return result, types.BatchAudit{}, fmt.Errorf("3 valid months for Q%d, %d required", quarter, year)
 	}
 	if err != nil {
-		return nil, err
+		return nil, types.BatchAudit{}, err
 	}
 
-	// Create shizznizz
-	if err = dbase.CreateQuarterlyBatch(batch); err != nil {
-		return nil, err
+	assembler, err := batchAssembler(dbase)
+	if err != nil {
+		return nil, types.BatchAudit{}, err
 	}
 
-	return nil, nil
+	// Create the batch and assemble its dataset
+	audit, err := dbase.CreateQuarterlyBatch(batch, assembler)
+	if err != nil {
+		return nil, types.BatchAudit{}, err
+	}
+
+	return nil, audit, nil
 }
 
-func (b BatchHandler) generateYearBatchId(year int, description string) (interface{}, error) {
+func (b BatchHandler) generateYearBatchId(year int, description, user string) (interface{}, types.BatchAudit, error) {
 	// Set batch variables
 	batch := types.AnnualBatch{
 		Id:          0,
 		Year:        year,
 		Status:      0,
 		Description: description,
+		CreatedBy:   user,
 	}
 
 	// Establish DB connection
 	dbase, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
-		return nil, err
+		return nil, types.BatchAudit{}, err
 	}
 
 	// Check if year batch already exists
 	if found := dbase.AnnualBatchExists(year); found {
-		return nil, fmt.Errorf("annual batch for year %d already exists", year)
+		return nil, types.BatchAudit{}, fmt.Errorf("annual batch for year %d already exists", year)
 	}
 
 	//Ensure successful monthly exist
 	result, err := dbase.ValidateMonthsForAnnualBatch(year)
 	if result != nil {
-		return result, fmt.Errorf("12 valid months for year %d required", year)
+		return result, types.BatchAudit{}, fmt.Errorf("12 valid months for year %d required", year)
 	}
 	if err != nil {
-		return nil, err
+		return nil, types.BatchAudit{}, err
 	}
 
 	// Ensure successful quarterly exist
 	res, err := dbase.ValidateQuartersForAnnualBatch(year)
 	if res != nil {
-		return res, fmt.Errorf("4 valid quarters for year %d required", year)
+		return res, types.BatchAudit{}, fmt.Errorf("4 valid quarters for year %d required", year)
 	}
 	if err != nil {
-		return nil, err
+		return nil, types.BatchAudit{}, err
 	}
 
-	// Create shizznizz
-	if err = dbase.CreateAnnualBatch(batch); err != nil {
-		return nil, err
+	assembler, err := batchAssembler(dbase)
+	if err != nil {
+		return nil, types.BatchAudit{}, err
+	}
+
+	// Create the batch and assemble its dataset
+	audit, err := dbase.CreateAnnualBatch(batch, assembler)
+	if err != nil {
+		return nil, types.BatchAudit{}, err
 	}
 
-	return nil, nil
+	return nil, audit, nil
 }
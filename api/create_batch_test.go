@@ -4,6 +4,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"net/http/httptest"
+	"services/api/assemble"
 	"services/config"
 	"services/db"
 	"services/types"
@@ -370,8 +371,12 @@ func tearDown(t *testing.T) {
 	batchTable := config.Config.Database.MonthlyBatchTable
 	quarterlyBatchTable := config.Config.Database.QuarterlyBatchTable
 	annualBatchTable := config.Config.Database.AnnualBatchTable
+	batchSurveyTable := config.Config.Database.BatchSurveyTable
+	batchAuditTable := config.Config.Database.BatchAuditTable
+	batchConflictsTable := config.Config.Database.BatchConflictsTable
 
-	tables := []string{gbBatchTable, niBatchTable, batchTable, quarterlyBatchTable, annualBatchTable}
+	tables := []string{batchSurveyTable, batchConflictsTable, batchAuditTable, gbBatchTable, niBatchTable, batchTable,
+		quarterlyBatchTable, annualBatchTable}
 
 	// For each table: confirm configuration is set and then cleanse
 	for _, table := range tables {
@@ -431,7 +436,7 @@ func setupQuarterlyTables(t *testing.T, quarter, count, year, status int) {
 			Status:      status,
 			Description: "Mock data for Testing",
 		}
-		if err := dbase.CreateQuarterlyBatch(batch); err != nil {
+		if _, err := dbase.CreateQuarterlyBatch(batch, assemble.NewAssembler(nil, nil, nil)); err != nil {
 			t.Fatalf(err.Error())
 		}
 	}
@@ -455,7 +460,7 @@ func setupAnnualTables(t *testing.T, year, status int) {
 		Status:      status,
 		Description: "Mock data for Testing",
 	}
-	if err := dbase.CreateAnnualBatch(batch); err != nil {
+	if _, err := dbase.CreateAnnualBatch(batch, assemble.NewAssembler(nil, nil, nil)); err != nil {
 		t.Fatalf(err.Error())
 	}
 }
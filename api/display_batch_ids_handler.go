@@ -26,7 +26,7 @@ func (i IdHandler) HandleAnnualBatchIdsRequest(w http.ResponseWriter, r *http.Re
 	// Logging
 	log.Debug().
 		Str("client", r.RemoteAddr).
-		Str("uri", r.RequestURI).
+		Str("uri", logURI(r)).
 		Msg("Received Annual Batch ID request")
 
 	// Convert year to integer
@@ -61,7 +61,7 @@ func (i IdHandler) HandleAnnualBatchIdsRequest(w http.ResponseWriter, r *http.Re
 	// Logging
 	log.Debug().
 		Str("client", r.RemoteAddr).
-		Str("uri", r.RequestURI).
+		Str("uri", logURI(r)).
 		Str("elapsedTime", util.FmtDuration(startTime)).
 		Msg("Retrieve Annual Batch ID request completed")
 }
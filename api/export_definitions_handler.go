@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"os"
+	"services/types"
+	"strings"
+)
+
+type ExportDefinitionsHandler struct{}
+
+func NewExportDefinitionsHandler() *ExportDefinitionsHandler {
+	return &ExportDefinitionsHandler{}
+}
+
+/*
+All of the export definitions, as JSON or, with format=csv, as a CSV file that can be edited and imported again
+*/
+func (h ExportDefinitionsHandler) HandleAllRequest(w http.ResponseWriter, r *http.Request) {
+
+	res, err := h.getExportDefinitions()
+	if err != nil {
+		log.Error().Err(err).Msg("Get export definitions failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	if strings.ToLower(r.URL.Query().Get("format")) == "csv" {
+		rows := make([]types.ExportDefinitionCSV, len(res))
+		for i, d := range res {
+			rows[i] = exportDefinitionToCSV(d)
+		}
+		SendCSVResponse{FileName: "export_definitions.csv"}.sendResponse(w, r, &rows)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (h ExportDefinitionsHandler) HandleVariableRequest(w http.ResponseWriter, r *http.Request) {
+
+	variable, err := parseExportVariable(mux.Vars(r)["variable"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	res, err := h.getExportDefinition(variable)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+// the definition in the body of a request. For an update the variable is taken from the path.
+func readExportDefinition(r *http.Request, variable string) (types.ExportDefinition, error) {
+	var d types.ExportDefinition
+
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		return d, fmt.Errorf("invalid export definition: %s", err)
+	}
+
+	if variable != "" {
+		d.Variable = variable
+	}
+
+	name, err := parseExportVariable(d.Variable)
+	if err != nil {
+		return d, err
+	}
+	d.Variable = name
+
+	return d, nil
+}
+
+func (h ExportDefinitionsHandler) HandleCreateRequest(w http.ResponseWriter, r *http.Request) {
+
+	d, err := readExportDefinition(r, "")
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if err := h.createExportDefinition(d, actingUser(r)); err != nil {
+		log.Error().Err(err).Str("variable", d.Variable).Msg("Create export definition failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, d)
+}
+
+func (h ExportDefinitionsHandler) HandleUpdateRequest(w http.ResponseWriter, r *http.Request) {
+
+	variable, err := parseExportVariable(mux.Vars(r)["variable"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	d, err := readExportDefinition(r, variable)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if err := h.updateExportDefinition(d, actingUser(r)); err != nil {
+		log.Error().Err(err).Str("variable", d.Variable).Msg("Update export definition failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, d)
+}
+
+func (h ExportDefinitionsHandler) HandleDeleteRequest(w http.ResponseWriter, r *http.Request) {
+
+	variable, err := parseExportVariable(mux.Vars(r)["variable"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if err := h.deleteExportDefinition(variable, actingUser(r)); err != nil {
+		log.Error().Err(err).Str("variable", variable).Msg("Delete export definition failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	OkayResponse{OK}.sendResponse(w, r)
+}
+
+/*
+The changes made to the export definitions, newest first. Set the variable query parameter for the changes
+to one variable.
+*/
+func (h ExportDefinitionsHandler) HandleAuditRequest(w http.ResponseWriter, r *http.Request) {
+
+	variable := r.URL.Query().Get("variable")
+	if variable != "" {
+		name, err := parseExportVariable(variable)
+		if err != nil {
+			ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+			return
+		}
+		variable = name
+	}
+
+	res, err := h.getExportDefinitionsAudit(variable)
+	if err != nil {
+		log.Error().Err(err).Msg("Get export definitions audit failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+/*
+Import a CSV file of export definitions. The file is small so, unlike the other imports, it is loaded
+before the response is sent.
+*/
+func (h ExportDefinitionsHandler) HandleImportRequest(w http.ResponseWriter, r *http.Request) {
+
+	tmpfile, err := SaveStreamToTempFile(w, r)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	defer func() { _ = os.Remove(tmpfile) }()
+
+	res, err := h.importExportDefinitions(tmpfile, r.Form.Get("fileName"), actingUser(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Import export definitions failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"services/importdata"
+	"services/types"
+	"services/util"
+	"strings"
+)
+
+// variable names are upper case and at most 10 characters, the size of the variables column
+func parseExportVariable(variable string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(variable))
+	if name == "" || len(name) > 10 {
+		return "", fmt.Errorf("invalid variable name: %q, must be between 1 and 10 characters", variable)
+	}
+	return name, nil
+}
+
+func exportDefinitionFromCSV(row types.ExportDefinitionCSV) (types.ExportDefinition, error) {
+	variable, err := parseExportVariable(row.Variable)
+	if err != nil {
+		return types.ExportDefinition{}, err
+	}
+
+	flags := []int{row.Research, row.RegionalClient, row.Government, row.SpecialLicense, row.EndUser, row.Adhoc}
+	for i, f := range flags {
+		if f != 0 && f != 1 {
+			return types.ExportDefinition{},
+				fmt.Errorf("invalid %s flag for %s: %d, must be 0 or 1", types.Audiences[i], variable, f)
+		}
+	}
+
+	return types.ExportDefinition{
+		Variable:       variable,
+		Research:       row.Research == 1,
+		RegionalClient: row.RegionalClient == 1,
+		Government:     row.Government == 1,
+		SpecialLicense: row.SpecialLicense == 1,
+		EndUser:        row.EndUser == 1,
+		Adhoc:          row.Adhoc == 1,
+	}, nil
+}
+
+func exportDefinitionToCSV(d types.ExportDefinition) types.ExportDefinitionCSV {
+	return types.ExportDefinitionCSV{
+		Variable:       d.Variable,
+		Research:       util.Flag(d.Research),
+		RegionalClient: util.Flag(d.RegionalClient),
+		Government:     util.Flag(d.Government),
+		SpecialLicense: util.Flag(d.SpecialLicense),
+		EndUser:        util.Flag(d.EndUser),
+		Adhoc:          util.Flag(d.Adhoc),
+	}
+}
+
+func (h ExportDefinitionsHandler) getExportDefinitions() ([]types.ExportDefinition, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return database.GetExportDefinitions()
+}
+
+func (h ExportDefinitionsHandler) getExportDefinition(variable string) (types.ExportDefinition, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return types.ExportDefinition{}, err
+	}
+	return database.GetExportDefinition(variable)
+}
+
+func (h ExportDefinitionsHandler) createExportDefinition(d types.ExportDefinition, user string) error {
+	database, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+	return database.CreateExportDefinition(d, user)
+}
+
+func (h ExportDefinitionsHandler) updateExportDefinition(d types.ExportDefinition, user string) error {
+	database, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+	return database.UpdateExportDefinition(d, user)
+}
+
+func (h ExportDefinitionsHandler) deleteExportDefinition(variable, user string) error {
+	database, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+	return database.DeleteExportDefinition(variable, user)
+}
+
+func (h ExportDefinitionsHandler) getExportDefinitionsAudit(variable string) ([]types.ExportDefinitionAudit, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return database.GetExportDefinitionsAudit(variable)
+}
+
+/*
+Create or update the definitions in a CSV file. Every row is checked before anything is saved and a variable
+can only appear once.
+*/
+func (h ExportDefinitionsHandler) importExportDefinitions(tmpfile, fileName, user string) (types.ExportDefinitionsImport, error) {
+	var csvFile []types.ExportDefinitionCSV
+
+	if err := importdata.ImportCSVFile(tmpfile, &csvFile); err != nil {
+		return types.ExportDefinitionsImport{}, err
+	}
+
+	if len(csvFile) < 1 {
+		return types.ExportDefinitionsImport{}, fmt.Errorf("CSV file: %s, is empty", fileName)
+	}
+
+	seen := make(map[string]bool, len(csvFile))
+	definitions := make([]types.ExportDefinition, len(csvFile))
+	for i, row := range csvFile {
+		d, err := exportDefinitionFromCSV(row)
+		if err != nil {
+			return types.ExportDefinitionsImport{}, fmt.Errorf("row %d: %s", i+1, err)
+		}
+		if seen[d.Variable] {
+			return types.ExportDefinitionsImport{}, fmt.Errorf("row %d: %s is defined more than once", i+1, d.Variable)
+		}
+		seen[d.Variable] = true
+		definitions[i] = d
+	}
+
+	database, err := connectDatabase()
+	if err != nil {
+		return types.ExportDefinitionsImport{}, err
+	}
+
+	res, err := database.ImportExportDefinitions(definitions, user)
+	if err != nil {
+		return types.ExportDefinitionsImport{}, err
+	}
+
+	log.Debug().
+		Str("fileName", fileName).
+		Str("user", user).
+		Int("created", res.Created).
+		Int("updated", res.Updated).
+		Int("unchanged", res.Unchanged).
+		Msg("Imported export definitions")
+
+	return res, nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"services/exportdata"
+	"services/types"
+	"strings"
+)
+
+type ExportHandler struct{}
+
+func NewExportHandler() *ExportHandler {
+	return &ExportHandler{}
+}
+
+/*
+Export the survey data of a month, quarter or year for an audience. The format is set by the format query
+parameter, sav if not given, and the dataset by the dataset parameter, the current survey rows if not given.
+*/
+func (eh ExportHandler) HandleExportRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	audience, err := parseAudience(vars["audience"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	period, err := parseExportPeriod(vars["period"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	dataset, err := parseDataset(r.URL.Query().Get("dataset"))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	formatName := strings.ToLower(r.URL.Query().Get("format"))
+	if formatName == "" {
+		formatName = "sav"
+	}
+
+	format, ok := exportdata.Formats[formatName]
+	if !ok {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid format: %s", formatName)}.sendResponse(w, r)
+		return
+	}
+
+	// the export is written to a file first so that a failure part way through can still be reported
+	tmpfile, err := ioutil.TempFile("", "export-*."+format.Extension)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+	fileName := tmpfile.Name()
+	_ = tmpfile.Close()
+
+	defer func() { _ = os.Remove(fileName) }()
+
+	rows, err := eh.exportSurvey(audience, dataset, period, format, fileName)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("audience", string(audience)).
+			Str("dataset", string(dataset)).
+			Str("period", period.String()).
+			Msg("Export failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if rows == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	name := fmt.Sprintf("%s_%s", audience, period)
+	if dataset != types.SurveyDataset {
+		name = fmt.Sprintf("%s_%s_%s", audience, dataset, period)
+	}
+
+	// what the format leaves out is reported rather than silently dropped
+	if format.Omitted != "" {
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "%s, see /exports/%s/labels"`, format.Omitted, audience))
+	}
+
+	SendFileResponse{
+		FileName:    name + "." + format.Extension,
+		ContentType: format.ContentType,
+	}.sendResponse(w, r, fileName)
+}
+
+/*
+Download the value labels of the variables an audience can be given as a CSV file, for formats that leave them
+out of the export
+*/
+func (eh ExportHandler) HandleLabelsRequest(w http.ResponseWriter, r *http.Request) {
+	audience, err := parseAudience(mux.Vars(r)["audience"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	tmpfile, err := ioutil.TempFile("", "labels-*.csv")
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+	fileName := tmpfile.Name()
+	_ = tmpfile.Close()
+
+	defer func() { _ = os.Remove(fileName) }()
+
+	rows, err := eh.exportValueLabels(audience, fileName)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("audience", string(audience)).
+			Msg("Value labels export failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if rows == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendFileResponse{
+		FileName:    fmt.Sprintf("%s_labels.csv", audience),
+		ContentType: "text/csv",
+	}.sendResponse(w, r, fileName)
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"regexp"
+	"services/db"
+	"services/exportdata"
+	"services/types"
+	"services/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// audiences can be given as in the export_definitions table or with hyphens, so end_user or end-user
+func parseAudience(audience string) (types.Audience, error) {
+	name := strings.Replace(strings.ToLower(audience), "-", "_", -1)
+	for _, a := range types.Audiences {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("invalid audience: %s", audience)
+}
+
+// the dataset parameter of a request, the current survey rows if not given
+func parseDataset(dataset string) (types.Dataset, error) {
+	if dataset == "" {
+		return types.SurveyDataset, nil
+	}
+	for _, d := range types.Datasets {
+		if string(d) == strings.ToLower(dataset) {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("invalid dataset: %s", dataset)
+}
+
+var periodPattern = regexp.MustCompile(`^(\d{4})(?:-(?:([qQ][1-4])|(\d{1,2})))?$`)
+
+/*
+A period is a year (2019), a quarter (2019-Q1) or a month (2019-01)
+*/
+func parseExportPeriod(period string) (types.ExportPeriod, error) {
+	m := periodPattern.FindStringSubmatch(period)
+	if m == nil {
+		return types.ExportPeriod{}, fmt.Errorf("invalid period: %s, expected a year, year-Qn or year-month", period)
+	}
+
+	p := types.ExportPeriod{}
+	p.Year, _ = strconv.Atoi(m[1])
+
+	if m[2] != "" {
+		p.Quarter, _ = strconv.Atoi(m[2][1:])
+	}
+
+	if m[3] != "" {
+		p.Month, _ = strconv.Atoi(m[3])
+		if p.Month < 1 || p.Month > 12 {
+			return types.ExportPeriod{}, fmt.Errorf("invalid month in period: %s", period)
+		}
+	}
+
+	return p, nil
+}
+
+// label sets of different sources can have the same name
+func exportLabelSet(source, name string) string {
+	return fmt.Sprintf("%s_%s", source, strings.ToUpper(name))
+}
+
+/*
+Describe the variables being exported from their definitions. GB definitions are used before NI ones and a
+variable without a definition is taken to be numeric.
+*/
+func exportHeader(variables []string, definitions ...[]types.VariableDefinitions) []types.Header {
+	defined := make(map[string]types.VariableDefinitions)
+	for i := len(definitions) - 1; i >= 0; i-- {
+		for _, d := range definitions[i] {
+			defined[strings.ToUpper(d.Variable)] = d
+		}
+	}
+
+	header := make([]types.Header, len(variables))
+	for i, v := range variables {
+		h := types.Header{VariableName: v, VariableType: types.TypeDouble}
+		if d, ok := defined[v]; ok {
+			h.VariableType = d.VariableType
+			h.VariableDescription = d.Description.String
+			h.VariableLength = d.VariableLength
+			h.VariablePrecision = d.Precision
+			if d.Label.Valid && d.Label.String != "" {
+				h.LabelName = exportLabelSet(d.Source, d.Label.String)
+			}
+		}
+		header[i] = h
+	}
+
+	return header
+}
+
+// the value labels of the label sets used by the variables being exported
+func exportLabels(header []types.Header, valueLabels []types.ValueLabelsRow) map[string][]types.Labels {
+	used := make(map[string]bool)
+	for _, h := range header {
+		if h.LabelName != "" {
+			used[h.LabelName] = true
+		}
+	}
+
+	labels := make(map[string][]types.Labels)
+	for _, l := range valueLabels {
+		name := exportLabelSet(l.Source, l.Name)
+		if !used[name] {
+			continue
+		}
+		labels[name] = append(labels[name], types.Labels{
+			Name:         name,
+			Value:        l.Value,
+			Label:        l.Label,
+			VariableType: l.VariableType,
+		})
+	}
+
+	return labels
+}
+
+// the variables an audience can be given, described from their definitions
+func audienceHeader(database db.Persistence, audience types.Audience) ([]types.Header, error) {
+	variables, err := database.GetExportVariables(audience)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get export definitions: %s", err)
+	}
+	if len(variables) == 0 {
+		return nil, fmt.Errorf("no variables can be exported for %s", audience)
+	}
+
+	gb, err := database.GetAllGBDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+	ni, err := database.GetAllNIDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+
+	return exportHeader(variables, gb, ni), nil
+}
+
+/*
+Write the rows of a dataset for a period to a file, keeping only the variables the audience can be given. The
+number of rows written is returned.
+*/
+func (eh ExportHandler) exportSurvey(audience types.Audience, dataset types.Dataset, period types.ExportPeriod, format exportdata.Format, fileName string) (int, error) {
+	startTime := time.Now()
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return 0, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	header, err := audienceHeader(database, audience)
+	if err != nil {
+		return 0, err
+	}
+
+	writer, err := format.NewWriter(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	if lw, ok := writer.(exportdata.LabelWriter); ok {
+		valueLabels, err := database.GetAllValueLabelsRows()
+		if err != nil {
+			_ = writer.Close()
+			return 0, fmt.Errorf("cannot get value labels: %s", err)
+		}
+		if err := lw.WriteLabels(exportLabels(header, valueLabels)); err != nil {
+			_ = writer.Close()
+			return 0, err
+		}
+	}
+
+	// a writer that needs the number of rows is given them, and the header, once they are counted
+	var count func(rows int) error
+	if cw, ok := writer.(exportdata.CountedWriter); ok {
+		count = func(rows int) error {
+			if err := cw.WriteRowCount(rows); err != nil {
+				return err
+			}
+			return writer.WriteHeader(header)
+		}
+	} else if err := writer.WriteHeader(header); err != nil {
+		_ = writer.Close()
+		return 0, err
+	}
+
+	rows := 0
+	err = database.ExportSurvey(dataset, period, count, func(columns map[string]interface{}) error {
+		row := make([]interface{}, len(header))
+		for i, h := range header {
+			row[i] = columns[h.VariableName]
+		}
+		rows++
+		return writer.WriteRow(row)
+	})
+	if err != nil {
+		_ = writer.Close()
+		return 0, fmt.Errorf("cannot export survey data: %s", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	log.Debug().
+		Str("audience", string(audience)).
+		Str("dataset", string(dataset)).
+		Str("period", period.String()).
+		Str("format", format.Extension).
+		Int("rows", rows).
+		Int("variables", len(header)).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Exported survey data")
+
+	return rows, nil
+}
+
+/*
+Write the value labels of the variables an audience can be given to a CSV file, a row for each VARIABLE, VALUE
+and LABEL, for formats such as SAS transport that cannot hold them. The number of labels written is returned.
+*/
+func (eh ExportHandler) exportValueLabels(audience types.Audience, fileName string) (int, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return 0, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	header, err := audienceHeader(database, audience)
+	if err != nil {
+		return 0, err
+	}
+
+	valueLabels, err := database.GetAllValueLabelsRows()
+	if err != nil {
+		return 0, fmt.Errorf("cannot get value labels: %s", err)
+	}
+	labels := exportLabels(header, valueLabels)
+
+	writer, err := exportdata.Formats["csv"].NewWriter(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	err = writer.WriteHeader([]types.Header{
+		{VariableName: "VARIABLE", VariableType: types.TypeString},
+		{VariableName: "VALUE", VariableType: types.TypeString},
+		{VariableName: "LABEL", VariableType: types.TypeString},
+	})
+	if err != nil {
+		_ = writer.Close()
+		return 0, err
+	}
+
+	rows := 0
+	for _, h := range header {
+		for _, l := range labels[h.LabelName] {
+			if err := writer.WriteRow([]interface{}{h.VariableName, fmt.Sprint(l.Value), l.Label}); err != nil {
+				_ = writer.Close()
+				return 0, err
+			}
+			rows++
+		}
+	}
+
+	return rows, writer.Close()
+}
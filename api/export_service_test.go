@@ -0,0 +1,42 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"services/types"
+	"testing"
+)
+
+func TestParseExportPeriod(t *testing.T) {
+	tests := []struct {
+		period  string
+		want    types.ExportPeriod
+		wantErr bool
+	}{
+		{period: "2019", want: types.ExportPeriod{Year: 2019}},
+		{period: "2019-Q2", want: types.ExportPeriod{Year: 2019, Quarter: 2}},
+		{period: "2019-q4", want: types.ExportPeriod{Year: 2019, Quarter: 4}},
+		{period: "2019-03", want: types.ExportPeriod{Year: 2019, Month: 3}},
+		{period: "2019-3", want: types.ExportPeriod{Year: 2019, Month: 3}},
+		{period: "2019-12", want: types.ExportPeriod{Year: 2019, Month: 12}},
+		{period: "2019-00", wantErr: true},
+		{period: "2019-0", wantErr: true},
+		{period: "2019-13", wantErr: true},
+		{period: "2019-Q5", wantErr: true},
+		{period: "2019-Q", wantErr: true},
+		{period: "19", wantErr: true},
+		{period: "", wantErr: true},
+		{period: "2019-03-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			got, err := parseExportPeriod(tt.period)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
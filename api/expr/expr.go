@@ -0,0 +1,86 @@
+/*
+Package expr is a small expression language over the columns of a survey row. It is used to write validation
+and row-skip rules in configuration, so that methodology changes do not need a code release.
+
+Column names are used as identifiers and are not case sensitive. Expressions support numbers, quoted strings,
+the comparison operators == (or =), !=, <, <=, > and >=, arithmetic with + - * /, the logical operators
+&& (and), || (or) and ! (not), and the functions:
+
+	missing(COL)          true if COL is NaN, empty or not in the file
+	present(COL)          the opposite of missing
+	in(COL, 11, 12, 20)   true if COL is one of the values listed
+	between(COL, 16, 64)  true if COL is in the inclusive range
+
+A comparison involving a missing value is always false.
+*/
+package expr
+
+import (
+	"fmt"
+	"services/types"
+	"sort"
+	"strings"
+)
+
+type Expr struct {
+	source  string
+	root    node
+	columns []string
+}
+
+// Columns maps upper case column names to their position in a row
+type Columns map[string]int
+
+func NewColumns(header []types.Header) Columns {
+	c := make(Columns, len(header))
+	for i, h := range header {
+		c[strings.ToUpper(h.VariableName)] = i
+	}
+	return c
+}
+
+func Parse(source string) (Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return Expr{}, fmt.Errorf("%s in %q", err, source)
+	}
+
+	p := &parser{tokens: tokens, columns: make(map[string]bool)}
+
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected '%s'", p.peek().text)
+	}
+	if err != nil {
+		return Expr{}, fmt.Errorf("%s in %q", err, source)
+	}
+
+	columns := make([]string, 0, len(p.columns))
+	for c := range p.columns {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	return Expr{source: source, root: root, columns: columns}, nil
+}
+
+func (e Expr) String() string {
+	return e.source
+}
+
+// Columns returns the columns the expression refers to
+func (e Expr) Columns() []string {
+	return e.columns
+}
+
+func (e Expr) Eval(columns Columns, row []string) Value {
+	if e.root == nil {
+		return Value{}
+	}
+	return e.root.eval(columns, row)
+}
+
+// True evaluates the expression for a row and reports whether the result is true
+func (e Expr) True(columns Columns, row []string) bool {
+	return e.Eval(columns, row).Bool()
+}
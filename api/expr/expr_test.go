@@ -0,0 +1,66 @@
+package expr_test
+
+import (
+	"services/api/expr"
+	"services/types"
+	"testing"
+)
+
+var header = []types.Header{
+	{VariableName: "HOUT"}, {VariableName: "LSTHO"}, {VariableName: "AGE"}, {VariableName: "PCODE"},
+}
+
+func TestExpressions(t *testing.T) {
+	columns := expr.NewColumns(header)
+	row := []string{"37", "12", "NaN", "NP10 8XG"}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"HOUT == 37", true},
+		{"hout = 37 and lstho = 12", true},
+		{"in(HOUT, 11, 12, 20) || (HOUT == 37 && in(LSTHO, 11, 12, 20))", true},
+		{"not in(HOUT, 11, 12, 20)", true},
+		{"missing(AGE)", true},
+		{"AGE >= 16", false},
+		{"AGE < 16", false},
+		{"!(AGE < 16)", true},
+		{"missing(NOTACOLUMN)", true},
+		{"PCODE == 'NP10 8XG'", true},
+		{"between(HOUT - LSTHO, 20, 30)", true},
+		{"HOUT * 2 / 4 > 18", true},
+		{"-HOUT < 0", true},
+	}
+
+	for _, test := range tests {
+		e, err := expr.Parse(test.source)
+		if err != nil {
+			t.Errorf("Parse(%q) returned an error: %s", test.source, err)
+			continue
+		}
+		if got := e.True(columns, row); got != test.want {
+			t.Errorf("%q = %t, want %t", test.source, got, test.want)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	e, err := expr.Parse("HOUT == 37 && in(lstho, 11, 12) && missing(AGE)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := e.Columns()
+	if len(columns) != 3 || columns[0] != "AGE" || columns[1] != "HOUT" || columns[2] != "LSTHO" {
+		t.Errorf("Columns() = %v, want [AGE HOUT LSTHO]", columns)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, source := range []string{"", "HOUT ==", "(HOUT == 1", "HOUT == 'abc", "unknown(HOUT)", "in(HOUT)", "HOUT # 1", "HOUT 1"} {
+		if _, err := expr.Parse(source); err == nil {
+			t.Errorf("Parse(%q) did not return an error", source)
+		}
+	}
+}
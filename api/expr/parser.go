@@ -0,0 +1,363 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type node interface {
+	eval(columns Columns, row []string) Value
+}
+
+type literal struct {
+	value Value
+}
+
+func (n literal) eval(Columns, []string) Value {
+	return n.value
+}
+
+type column struct {
+	name string
+}
+
+func (n column) eval(columns Columns, row []string) Value {
+	i, ok := columns[n.name]
+	if !ok || i >= len(row) {
+		return Value{missing: true}
+	}
+	return columnValue(row[i])
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n unary) eval(columns Columns, row []string) Value {
+	v := n.operand.eval(columns, row)
+	if n.op == "!" {
+		return boolean(!v.Bool())
+	}
+	if v.missing || !v.numeric {
+		return Value{missing: true}
+	}
+	return number(-v.number)
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n binary) eval(columns Columns, row []string) Value {
+	l := n.left.eval(columns, row)
+
+	// logical operators short circuit
+	switch n.op {
+	case "&&":
+		return boolean(l.Bool() && n.right.eval(columns, row).Bool())
+	case "||":
+		return boolean(l.Bool() || n.right.eval(columns, row).Bool())
+	}
+
+	r := n.right.eval(columns, row)
+	if l.missing || r.missing {
+		if n.op == "==" || n.op == "!=" || n.op == "<" || n.op == "<=" || n.op == ">" || n.op == ">=" {
+			return boolean(false)
+		}
+		return Value{missing: true}
+	}
+
+	switch n.op {
+	case "==":
+		return boolean(l.compare(r) == 0)
+	case "!=":
+		return boolean(l.compare(r) != 0)
+	case "<":
+		return boolean(l.compare(r) < 0)
+	case "<=":
+		return boolean(l.compare(r) <= 0)
+	case ">":
+		return boolean(l.compare(r) > 0)
+	case ">=":
+		return boolean(l.compare(r) >= 0)
+	}
+
+	if !l.numeric || !r.numeric {
+		return Value{missing: true}
+	}
+
+	switch n.op {
+	case "+":
+		return number(l.number + r.number)
+	case "-":
+		return number(l.number - r.number)
+	case "*":
+		return number(l.number * r.number)
+	case "/":
+		if r.number == 0 {
+			return Value{missing: true}
+		}
+		return number(l.number / r.number)
+	}
+
+	return Value{missing: true}
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+// functions and the number of arguments they take. -1 means at least two.
+var functions = map[string]int{
+	"MISSING": 1,
+	"PRESENT": 1,
+	"IN":      -1,
+	"BETWEEN": 3,
+}
+
+func (n call) eval(columns Columns, row []string) Value {
+	v := n.args[0].eval(columns, row)
+
+	switch n.name {
+	case "MISSING":
+		return boolean(v.missing)
+	case "PRESENT":
+		return boolean(!v.missing)
+	case "IN":
+		if v.missing {
+			return boolean(false)
+		}
+		for _, a := range n.args[1:] {
+			o := a.eval(columns, row)
+			if !o.missing && v.compare(o) == 0 {
+				return boolean(true)
+			}
+		}
+		return boolean(false)
+	case "BETWEEN":
+		lo := n.args[1].eval(columns, row)
+		hi := n.args[2].eval(columns, row)
+		if v.missing || lo.missing || hi.missing {
+			return boolean(false)
+		}
+		return boolean(v.compare(lo) >= 0 && v.compare(hi) <= 0)
+	}
+
+	return Value{missing: true}
+}
+
+// recursive descent parser. In order of increasing precedence: ||, &&, !, comparisons, + -, * /, unary minus
+type parser struct {
+	tokens  []token
+	pos     int
+	columns map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, o := range ops {
+		if t.text == o {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, a...), p.peek().pos)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{"||", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{"&&", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unary{"!", operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		op := p.next().text
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return binary{op, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{"-", operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t.text, t.pos)
+		}
+		return literal{number(f)}, nil
+
+	case tokString:
+		p.next()
+		return literal{text(t.text)}, nil
+
+	case tokLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, p.errorf("expected ')'")
+		}
+		p.next()
+		return n, nil
+
+	case tokIdent:
+		p.next()
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		p.columns[t.text] = true
+		return column{t.text}, nil
+
+	case tokEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	return nil, p.errorf("unexpected '%s'", t.text)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	arity, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name.text, name.pos)
+	}
+
+	p.next() // (
+	var args []node
+	for p.peek().kind != tokRParen {
+		if len(args) > 0 {
+			if p.peek().kind != tokComma {
+				return nil, p.errorf("expected ',' or ')'")
+			}
+			p.next()
+		}
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	p.next() // )
+
+	if (arity > 0 && len(args) != arity) || (arity < 0 && len(args) < 2) {
+		return nil, fmt.Errorf("wrong number of arguments to %s at position %d", name.text, name.pos)
+	}
+
+	return call{name.text, args}, nil
+}
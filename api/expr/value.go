@@ -0,0 +1,83 @@
+package expr
+
+import (
+	"math"
+	"strconv"
+)
+
+// Value is the result of evaluating an expression. Survey values are held as strings so a value keeps its
+// text as well as its number if it has one.
+type Value struct {
+	text    string
+	number  float64
+	numeric bool
+	missing bool
+}
+
+func number(f float64) Value {
+	if math.IsNaN(f) {
+		return Value{missing: true}
+	}
+	return Value{text: strconv.FormatFloat(f, 'f', -1, 64), number: f, numeric: true}
+}
+
+func boolean(b bool) Value {
+	if b {
+		return number(1)
+	}
+	return number(0)
+}
+
+func text(s string) Value {
+	return Value{text: s, missing: s == ""}
+}
+
+func columnValue(s string) Value {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return text(s)
+	}
+	if math.IsNaN(f) {
+		return Value{missing: true}
+	}
+	return Value{text: s, number: f, numeric: true}
+}
+
+func (v Value) Missing() bool {
+	return v.missing
+}
+
+func (v Value) Bool() bool {
+	if v.missing {
+		return false
+	}
+	if v.numeric {
+		return v.number != 0
+	}
+	return v.text != ""
+}
+
+func (v Value) String() string {
+	return v.text
+}
+
+// compare returns -1, 0 or 1. Numbers are compared numerically and anything else as text.
+func (v Value) compare(o Value) int {
+	if v.numeric && o.numeric {
+		switch {
+		case v.number < o.number:
+			return -1
+		case v.number > o.number:
+			return 1
+		}
+		return 0
+	}
+
+	switch {
+	case v.text < o.text:
+		return -1
+	case v.text > o.text:
+		return 1
+	}
+	return 0
+}
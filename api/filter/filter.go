@@ -8,9 +8,11 @@ import (
 
 type Filter interface {
 	DropColumn(string) bool
-	AddVariables(*types.SavImportData) error
-	SkipRowsFilter(*types.SavImportData) error
 	RenameColumns(string) (string, bool)
+
+	// streamed files are filtered a row at a time
+	RowSkipper([]types.Header) (*RowSkipper, error)
+	Variables([]types.Header) ([]Variable, error)
 }
 
 var dropColumns = conf.Config.DropColumns.Survey
@@ -8,107 +8,285 @@ import (
 	"strings"
 )
 
+/*
+Pipeline validates and pre-processes a survey file without holding it in memory. The file is read twice:
+RunPipeline reads it to validate it and work out what will be loaded, keeping only the columns the validation
+rules use, and Rows reads it again passing each row to persistence as it has been filtered.
+*/
 type Pipeline struct {
-	data       *types.SavImportData
-	validation validate.Validation
+	read       types.RowReader
 	filter     Filter
 	audit      *types.Audit
 	surveyType types.FileOrigin
+
+	file      types.SavImportData // the file's header, labels and row count
+	variables []Variable
+	keep      []bool // rows of the file not skipped
+	drop      []bool // columns, including those added, that are dropped
+	header    []types.Header
+
+	renamed []types.RenamedColumn
+	dropped []string
 }
 
-func NewNIPipeLine(data *types.SavImportData, audit *types.Audit) Pipeline {
+func NewNIPipeLine(read types.RowReader, audit *types.Audit) *Pipeline {
 
-	return Pipeline{
-		data:       data,
-		validation: nil,
+	return &Pipeline{
+		read:       read,
 		filter:     NewNISurveyFilter(),
 		audit:      audit,
 		surveyType: types.NI,
 	}
 }
 
-func NewGBPipeLine(data *types.SavImportData, audit *types.Audit) Pipeline {
-	return Pipeline{
-		data:       data,
-		validation: nil,
+func NewGBPipeLine(read types.RowReader, audit *types.Audit) *Pipeline {
+	return &Pipeline{
+		read:       read,
 		filter:     NewGBSurveyFilter(),
 		audit:      audit,
 		surveyType: types.GB,
 	}
 }
 
-func (p Pipeline) RunPipeline() error {
+/*
+The first pass over a file. The columns needed for validation are kept, each row is checked against the skip
+rules and the added variables are worked out for the rows that are kept, so that any problems are found before
+anything is loaded. Problems setting up the filters are held back until the file has been validated, as a
+missing column is better reported by validation.
+*/
+type scan struct {
+	p        *Pipeline
+	columns  map[string]bool
+	keep     []int
+	data     types.SavImportData
+	skipper  *RowSkipper
+	rowCount int
+	err      error
+}
+
+func (s *scan) Header(header []types.Header) error {
+	for i, h := range header {
+		if s.columns[strings.ToUpper(h.VariableName)] {
+			s.keep = append(s.keep, i)
+			s.data.Header = append(s.data.Header, h)
+		}
+	}
+	s.data.HeaderCount = len(s.data.Header)
+
+	var err error
+	if s.skipper, err = s.p.filter.RowSkipper(header); err != nil {
+		s.err = err
+	}
+	if s.p.variables, err = s.p.filter.Variables(header); err != nil && s.err == nil {
+		s.err = err
+	}
+
+	return nil
+}
+
+func (s *scan) Row(row []string) error {
+	s.rowCount++
+
+	values := make([]string, len(s.keep))
+	for i, inx := range s.keep {
+		values[i] = row[inx]
+	}
+	s.data.Rows = append(s.data.Rows, types.Rows{RowData: values})
+
+	keep := s.skipper == nil || !s.skipper.Skip(row)
+	s.p.keep = append(s.p.keep, keep)
+
+	if keep && s.err == nil {
+		for _, v := range s.p.variables {
+			if _, err := v.Value(row); err != nil {
+				s.err = fmt.Errorf("cannot add %s to row %d: %s", v.Header.VariableName, s.rowCount, err)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipeline) RunPipeline() error {
 	var period int
+	var validation validate.Validation
+
+	var columns []string
+	if p.surveyType == types.GB {
+		columns = validate.GBColumns()
+	} else {
+		columns = validate.NIColumns()
+	}
+
+	s := &scan{p: p, columns: make(map[string]bool, len(columns))}
+	for _, c := range columns {
+		s.columns[c] = true
+	}
+
+	file, err := p.read(s)
+	if err != nil {
+		return err
+	}
+	p.file = file
+
+	if file.RowCount == 0 {
+		log.Warn().
+			Str("method", "RunPipeline").
+			Msg("The SAV file is empty")
+		return fmt.Errorf("the spss file: %s is empty", p.audit.FileName)
+	}
+
+	p.audit.NumObFile = file.RowCount
+	p.audit.NumVarFile = file.HeaderCount
+
+	s.data.RowCount = len(s.data.Rows)
 
 	if p.surveyType == types.GB {
 		period = p.audit.Week
-		p.validation = validate.NewGBSurveyValidation(p.data)
+		validation = validate.NewGBSurveyValidation(&s.data)
 	} else {
-		p.validation = validate.NewNISurveyValidation(p.data)
+		validation = validate.NewNISurveyValidation(&s.data)
 		period = p.audit.Month
 	}
 
-	response, err := p.validation.Validate(period, p.audit.Year)
+	response, err := validation.Validate(period, p.audit.Year)
 
+	// the rules could not be run, which is not a problem with the file
 	if err != nil {
 		return err
 	}
 
 	if response.ValidationResult == validate.ValidationFailed {
-		return fmt.Errorf(response.ErrorMessage)
+		return validate.ValidationError{Message: response.ErrorMessage, Failures: response.Failures}
 	}
 
-	// Skip rows filter
-	if err := p.filter.SkipRowsFilter(p.data); err != nil {
-		return err
+	if s.err != nil {
+		log.Error().
+			Err(s.err).
+			Msg("Cannot filter file")
+		return s.err
 	}
 
+	p.audit.SkipCounts = s.skipper.Counts()
+
 	// add variables
-	err = p.filter.AddVariables(p.data)
-	if err != nil {
-		log.Error().Err(err)
-		return err
+	header := make([]types.Header, 0, len(file.Header)+len(p.variables))
+	header = append(header, file.Header...)
+	for _, v := range p.variables {
+		header = append(header, v.Header)
 	}
 
 	// rename variables
-	for k, v := range p.data.Header {
+	for k, v := range header {
 		to, ok := p.filter.RenameColumns(v.VariableName)
 		if ok {
-			p.data.Header[k].VariableName = to
+			header[k].VariableName = to
+			p.renamed = append(p.renamed, types.RenamedColumn{From: v.VariableName, To: to})
 		}
 	}
 
-	// drop unwanted columns
-	headers := make([]types.Header, 0, p.data.HeaderCount)
-	rowsToDrop := make(map[int]bool, p.data.HeaderCount)
+	// mark columns to drop
+	p.drop = make([]bool, len(header))
+	p.header = make([]types.Header, 0, len(header))
 
-	// mark columns of rows to drop
-	for i, j := range p.data.Header {
+	for i, j := range header {
 		if p.filter.DropColumn(strings.ToUpper(j.VariableName)) {
-			rowsToDrop[i] = true
+			p.drop[i] = true
+			p.dropped = append(p.dropped, j.VariableName)
 			continue
 		}
-		headers = append(headers, j)
-		rowsToDrop[i] = false
+		p.header = append(p.header, j)
 	}
 
-	// now drop them
-	for i, j := range p.data.Rows {
-		newRowData := make([]string, 0, p.data.HeaderCount)
-		for col, z := range j.RowData {
-			if rowsToDrop[col] {
-				continue
-			}
-			newRowData = append(newRowData, z)
+	loaded := 0
+	for _, k := range p.keep {
+		if k {
+			loaded++
 		}
-		p.data.Rows[i].RowData = newRowData
 	}
 
-	p.data.Header = headers
-	p.data.HeaderCount = len(headers)
+	p.audit.NumObLoaded = loaded
+	p.audit.NumVarLoaded = len(p.header)
+
+	return nil
+}
 
-	p.audit.NumObLoaded = p.data.RowCount
-	p.audit.NumVarLoaded = p.data.HeaderCount
+/*
+The second pass over a file, passing on the rows that are kept with the variables added and the dropped
+columns removed
+*/
+type rows struct {
+	p    *Pipeline
+	row  int
+	emit func(row []string) error
+}
 
+func (r *rows) Header(header []types.Header) error {
+	if len(header) != r.p.file.HeaderCount {
+		return fmt.Errorf("the file has changed since it was validated")
+	}
 	return nil
 }
+
+func (r *rows) Row(row []string) error {
+	inx := r.row
+	r.row++
+
+	if inx >= len(r.p.keep) {
+		return fmt.Errorf("the file has changed since it was validated")
+	}
+	if !r.p.keep[inx] {
+		return nil
+	}
+
+	for _, v := range r.p.variables {
+		value, err := v.Value(row)
+		if err != nil {
+			return err
+		}
+		row = append(row, value)
+	}
+
+	out := make([]string, 0, len(r.p.header))
+	for col, z := range row {
+		if r.p.drop[col] {
+			continue
+		}
+		out = append(out, z)
+	}
+
+	return r.emit(out)
+}
+
+/*
+Rows reads the file again and passes each row to be loaded to emit. RunPipeline must have been run first.
+*/
+func (p *Pipeline) Rows(emit func(row []string) error) error {
+	if p.header == nil {
+		return fmt.Errorf("the pipeline has not been run")
+	}
+
+	_, err := p.read(&rows{p: p, emit: emit})
+	return err
+}
+
+// Header returns the columns that will be loaded
+func (p *Pipeline) Header() []types.Header {
+	return p.header
+}
+
+// Labels returns the value labels read from the file
+func (p *Pipeline) Labels() map[string][]types.Labels {
+	return p.file.Labels
+}
+
+// Renamed returns the columns renamed by the last run of the pipeline
+func (p *Pipeline) Renamed() []types.RenamedColumn {
+	return p.renamed
+}
+
+// Dropped returns the columns dropped by the last run of the pipeline
+func (p *Pipeline) Dropped() []string {
+	return p.dropped
+}
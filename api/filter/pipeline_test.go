@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"services/api/validate"
+	"services/types"
+	"testing"
+)
+
+// Sunday 6th January 2019 (week 1) at midday as an SPSS date
+const sunday = "13766155200"
+
+// a reader that streams rows held in memory, counting how many times the file is read
+func memoryReader(header []types.Header, rows [][]string, reads *int) types.RowReader {
+	return func(handler types.RowHandler) (types.SavImportData, error) {
+		*reads++
+		if err := handler.Header(header); err != nil {
+			return types.SavImportData{}, err
+		}
+		for _, r := range rows {
+			row := make([]string, len(r))
+			copy(row, r)
+			if err := handler.Row(row); err != nil {
+				return types.SavImportData{}, err
+			}
+		}
+		return types.SavImportData{Header: header, HeaderCount: len(header), RowCount: len(rows)}, nil
+	}
+}
+
+func gbHeader() []types.Header {
+	columns := []string{"REFDTE", "PCODE", "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO",
+		"SEX", "AGE", "INDOUT", "HOUT", "LSTHO", "NAME"}
+
+	var header []types.Header
+	for _, c := range columns {
+		variableType := types.TypeDouble
+		if c == "PCODE" || c == "NAME" {
+			variableType = types.TypeString
+		}
+		header = append(header, types.Header{VariableName: c, VariableType: variableType})
+	}
+	return header
+}
+
+func TestPipelineStreamsRows(t *testing.T) {
+	rows := [][]string{
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "1", "1", "1", "1", "1", "34", "1", "11", "NaN", "Fred"},
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "1", "1", "1", "2", "NaN", "34", "1", "11", "NaN", "Jane"},
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "2", "1", "1", "1", "2", "40", "1", "20", "NaN", "Anne"},
+	}
+
+	var reads int
+	audit := types.Audit{FileName: "test.sav", Week: 1, Year: 2019}
+	p := NewGBPipeLine(memoryReader(gbHeader(), rows, &reads), &audit)
+
+	if err := p.RunPipeline(); err != nil {
+		t.Fatalf("RunPipeline returned an error: %s", err)
+	}
+
+	if audit.NumObFile != 3 || audit.NumObLoaded != 2 {
+		t.Errorf("rows in file = %d, loaded = %d, want 3 and 2", audit.NumObFile, audit.NumObLoaded)
+	}
+
+	// NAME is dropped, CASENO and HSERIAL added and ADDR renamed
+	header := p.Header()
+	if audit.NumVarLoaded != 17 || len(header) != 17 {
+		t.Fatalf("variables loaded = %d, want 17", audit.NumVarLoaded)
+	}
+	if header[6].VariableName != "ADD" || header[15].VariableName != "CASENO" || header[16].VariableName != "HSERIAL" {
+		t.Errorf("header = %+v", header)
+	}
+	if len(p.Dropped()) != 1 || p.Dropped()[0] != "NAME" {
+		t.Errorf("dropped = %v, want [NAME]", p.Dropped())
+	}
+
+	var loaded [][]string
+	err := p.Rows(func(row []string) error {
+		loaded = append(loaded, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rows returned an error: %s", err)
+	}
+
+	if reads != 2 {
+		t.Errorf("file read %d times, want 2", reads)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("rows = %d, want 2", len(loaded))
+	}
+	if loaded[0][15] != "101910110101" || loaded[1][15] != "101910210101" {
+		t.Errorf("CASENO = %s and %s", loaded[0][15], loaded[1][15])
+	}
+	for _, r := range loaded {
+		if len(r) != len(header) {
+			t.Errorf("row has %d values, want %d", len(r), len(header))
+		}
+	}
+}
+
+func TestPipelineValidationFailure(t *testing.T) {
+	rows := [][]string{
+		{sunday, "", "1", "1", "9", "1", "1", "1", "1", "1", "1", "34", "1", "11", "NaN", "Fred"},
+	}
+
+	var reads int
+	audit := types.Audit{FileName: "test.sav", Week: 2, Year: 2019}
+	p := NewGBPipeLine(memoryReader(gbHeader(), rows, &reads), &audit)
+
+	err := p.RunPipeline()
+	verr, ok := err.(validate.ValidationError)
+	if !ok {
+		t.Fatalf("RunPipeline returned %v, want a validation error", err)
+	}
+	if len(verr.Failures) != 2 {
+		t.Errorf("failures = %+v, want missing PCODE and wrong week", verr.Failures)
+	}
+
+	if err := p.Rows(func(row []string) error { return nil }); err == nil {
+		t.Error("Rows did not return an error for a file that failed validation")
+	}
+}
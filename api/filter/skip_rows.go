@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"services/api/expr"
+	conf "services/config"
+	"services/types"
+)
+
+type skipRule struct {
+	name string
+	skip expr.Expr
+}
+
+var gbSkipRules []skipRule
+var niSkipRules []skipRule
+
+/*
+Load and check the skip rules from the configuration
+*/
+func init() {
+	var err error
+
+	if gbSkipRules, err = compileSkipRules(conf.Config.SkipRows.GB); err != nil {
+		panic(fmt.Sprintf("GB skip rows configuration error: %s", err))
+	}
+
+	if niSkipRules, err = compileSkipRules(conf.Config.SkipRows.NI); err != nil {
+		panic(fmt.Sprintf("NI skip rows configuration error: %s", err))
+	}
+}
+
+func compileSkipRules(rules []conf.SkipRule) ([]skipRule, error) {
+	compiled := make([]skipRule, 0, len(rules))
+
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		e, err := expr.Parse(r.Skip)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		compiled = append(compiled, skipRule{name: name, skip: e})
+	}
+
+	return compiled, nil
+}
+
+/*
+RowSkipper applies the skip rules to a file a row at a time, counting the rows each rule drops
+*/
+type RowSkipper struct {
+	rules   []skipRule
+	columns expr.Columns
+	counts  types.SkipCounts
+}
+
+/*
+Set up the skip rules for a file. Every column used by the rules must be in the file's header.
+*/
+func newRowSkipper(rules []skipRule, header []types.Header) (*RowSkipper, error) {
+
+	columns := expr.NewColumns(header)
+
+	for _, r := range rules {
+		for _, col := range r.skip.Columns() {
+			if _, ok := columns[col]; !ok {
+				return nil, fmt.Errorf("column %s not found", col)
+			}
+		}
+	}
+
+	counts := make(types.SkipCounts, len(rules))
+	for i, r := range rules {
+		counts[i] = types.SkipCount{Rule: r.name, Skip: r.skip.String()}
+	}
+
+	return &RowSkipper{rules: rules, columns: columns, counts: counts}, nil
+}
+
+/*
+Skip reports whether a row is dropped. Each row is dropped by the first rule it matches so the counts add up
+to the number of rows removed.
+*/
+func (s *RowSkipper) Skip(row []string) bool {
+	for i, r := range s.rules {
+		if r.skip.True(s.columns, row) {
+			s.counts[i].Rows++
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of rows dropped by each rule so far
+func (s *RowSkipper) Counts() types.SkipCounts {
+	for _, c := range s.counts {
+		log.Debug().
+			Str("rule", c.Rule).
+			Int("rows", c.Rows).
+			Msg("Dropped rows")
+	}
+	return s.counts
+}
@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"services/types"
+	"testing"
+)
+
+func TestGBSkipRows(t *testing.T) {
+	header := []types.Header{
+		{VariableName: "SEX"}, {VariableName: "AGE"}, {VariableName: "INDOUT"},
+		{VariableName: "HOUT"}, {VariableName: "LSTHO"},
+	}
+	rows := [][]string{
+		{"1", "34", "1", "11", "NaN"},   // kept
+		{"NaN", "34", "1", "11", "NaN"}, // sex missing
+		{"2", "NaN", "5", "11", "NaN"},  // age missing, counted once
+		{"2", "40", "5", "20", "NaN"},   // individual outcome
+		{"2", "40", "NaN", "37", "12"},  // kept
+		{"2", "40", "1", "37", "NaN"},   // household outcome
+		{"2", "40", "1", "41", "20"},    // kept
+		{"2", "40", "1", "30", "11"},    // household outcome
+	}
+
+	skipper, err := GBSurveyFilter{}.RowSkipper(header)
+	if err != nil {
+		t.Fatalf("RowSkipper returned an error: %s", err)
+	}
+
+	kept := 0
+	for _, row := range rows {
+		if !skipper.Skip(row) {
+			kept++
+		}
+	}
+	counts := skipper.Counts()
+
+	if kept != 3 {
+		t.Errorf("rows = %d, want 3", kept)
+	}
+
+	want := []int{1, 1, 1, 2}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %+v, want %d rules", counts, len(want))
+	}
+	for i, n := range want {
+		if counts[i].Rows != n {
+			t.Errorf("rule %s dropped %d rows, want %d", counts[i].Rule, counts[i].Rows, n)
+		}
+	}
+}
+
+func TestSkipRowsMissingColumn(t *testing.T) {
+	header := []types.Header{{VariableName: "SEX"}, {VariableName: "AGE"}}
+
+	if _, err := (NISurveyFilter{}).RowSkipper(header); err == nil {
+		t.Error("RowSkipper did not return an error for a file without HOUTCOME")
+	}
+}
@@ -3,6 +3,7 @@ package filter
 import (
 	"fmt"
 	"services/types"
+	"services/util"
 	"strconv"
 )
 
@@ -10,8 +11,16 @@ type UKFilter struct {
 	BaseFilter
 }
 
-func findPosition(data *types.SavImportData, column string) (int, error) {
-	for i, j := range data.Header {
+/*
+Variable is a column added to a survey file. Its value is worked out from the other values in each row.
+*/
+type Variable struct {
+	Header types.Header
+	Value  func(row []string) (string, error)
+}
+
+func findPosition(header []types.Header, column string) (int, error) {
+	for i, j := range header {
 		if j.VariableName == column {
 			return i, nil
 		}
@@ -19,81 +28,53 @@ func findPosition(data *types.SavImportData, column string) (int, error) {
 	return 0, fmt.Errorf("column %s not found", column)
 }
 
-func (sf UKFilter) addHSerial(data *types.SavImportData) error {
-
-	// get indexes of items we are interested in for the calculation
-	quotaInx, err := findPosition(data, "QUOTA")
-	if err != nil {
-		return err
-	}
-	weekInx, err := findPosition(data, "WEEK")
-	if err != nil {
-		return err
-	}
-	w1yrInx, err := findPosition(data, "W1YR")
-	if err != nil {
-		return err
-	}
-	qrtrInx, err := findPosition(data, "QRTR")
-	if err != nil {
-		return err
-	}
-	addrInx, err := findPosition(data, "ADDR")
-	if err != nil {
-		return err
-	}
-	wavfndInx, err := findPosition(data, "WAVFND")
-	if err != nil {
-		return err
-	}
-	hhldInx, err := findPosition(data, "HHLD")
-	if err != nil {
-		return err
-	}
-
-	for i := 0; i < data.RowCount; i++ {
-		var row = data.Rows[i]
-
-		quota, err := strconv.ParseFloat(row.RowData[quotaInx], 64)
-		if err != nil {
-			return err
-		}
-
-		week, err := strconv.ParseFloat(row.RowData[weekInx], 64)
+// get indexes of items we are interested in for the calculation
+func findPositions(header []types.Header, columns ...string) ([]int, error) {
+	positions := make([]int, len(columns))
+	for i, col := range columns {
+		inx, err := findPosition(header, col)
 		if err != nil {
-			return err
-		}
-
-		w1yr, err := strconv.ParseFloat(row.RowData[w1yrInx], 64)
-		if err != nil {
-			return err
+			return nil, err
 		}
+		positions[i] = inx
+	}
+	return positions, nil
+}
 
-		qrtr, err := strconv.ParseFloat(row.RowData[qrtrInx], 64)
+func rowValues(row []string, positions []int) ([]float64, error) {
+	values := make([]float64, len(positions))
+	for i, inx := range positions {
+		f, err := strconv.ParseFloat(row[inx], 64)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		values[i] = f
+	}
+	return values, nil
+}
 
-		addr, err := strconv.ParseFloat(row.RowData[addrInx], 64)
-		if err != nil {
-			return err
-		}
+/*
+The CASENO and HSERIAL variables added to GB and NI files
+*/
+func (sf UKFilter) Variables(header []types.Header) ([]Variable, error) {
+	caseNo, err := sf.casenoVariable(header)
+	if err != nil {
+		return nil, err
+	}
 
-		wavfnd, err := strconv.ParseFloat(row.RowData[wavfndInx], 64)
-		if err != nil {
-			return err
-		}
+	hSerial, err := sf.hSerialVariable(header)
+	if err != nil {
+		return nil, err
+	}
 
-		hhld, err := strconv.ParseFloat(row.RowData[hhldInx], 64)
-		if err != nil {
-			return err
-		}
+	return []Variable{caseNo, hSerial}, nil
+}
 
-		n := (quota * 1000000000) + (week * 10000000) + (w1yr * 1000000) +
-			(qrtr * 100000) + (addr * 1000) + (wavfnd * 100) + (hhld + 1)
+func (sf UKFilter) hSerialVariable(header []types.Header) (Variable, error) {
 
-		row.RowData = append(row.RowData, fmt.Sprintf("%d", int64(n)))
-		data.Rows[i] = row
+	positions, err := findPositions(header, "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD")
+	if err != nil {
+		return Variable{}, err
 	}
 
 	column := types.Header{
@@ -105,96 +86,24 @@ func (sf UKFilter) addHSerial(data *types.SavImportData) error {
 		LabelName:           "",
 		Drop:                false,
 	}
-	data.Header = append(data.Header, column)
-	data.HeaderCount = data.HeaderCount + 1
-
-	return nil
-}
-
-func (sf UKFilter) addCaseno(data *types.SavImportData) error {
-
-	// get indexes of items we are interested in for the calculation
-	quotaInx, err := findPosition(data, "QUOTA")
-	if err != nil {
-		return err
-	}
-	weekInx, err := findPosition(data, "WEEK")
-	if err != nil {
-		return err
-	}
-	w1yrInx, err := findPosition(data, "W1YR")
-	if err != nil {
-		return err
-	}
-	qrtrInx, err := findPosition(data, "QRTR")
-	if err != nil {
-		return err
-	}
-	addrInx, err := findPosition(data, "ADDR")
-	if err != nil {
-		return err
-	}
-	wavfndInx, err := findPosition(data, "WAVFND")
-	if err != nil {
-		return err
-	}
-	hhldInx, err := findPosition(data, "HHLD")
-	if err != nil {
-		return err
-	}
-	persnoInx, err := findPosition(data, "PERSNO")
-	if err != nil {
-		return err
-	}
-
-	for i := 0; i < data.RowCount; i++ {
-		var row = data.Rows[i]
-
-		quota, err := strconv.ParseFloat(row.RowData[quotaInx], 64)
-		if err != nil {
-			return err
-		}
-
-		week, err := strconv.ParseFloat(row.RowData[weekInx], 64)
-		if err != nil {
-			return err
-		}
-
-		w1yr, err := strconv.ParseFloat(row.RowData[w1yrInx], 64)
-		if err != nil {
-			return err
-		}
-
-		qrtr, err := strconv.ParseFloat(row.RowData[qrtrInx], 64)
-		if err != nil {
-			return err
-		}
-
-		addr, err := strconv.ParseFloat(row.RowData[addrInx], 64)
-		if err != nil {
-			return err
-		}
 
-		wavfnd, err := strconv.ParseFloat(row.RowData[wavfndInx], 64)
+	value := func(row []string) (string, error) {
+		v, err := rowValues(row, positions)
 		if err != nil {
-			return err
-		}
-
-		hhld, err := strconv.ParseFloat(row.RowData[hhldInx], 64)
-		if err != nil {
-			return err
+			return "", err
 		}
+		n := util.HSerial(v[0], v[1], v[2], v[3], v[4], v[5], v[6])
+		return fmt.Sprintf("%d", n), nil
+	}
 
-		persno, err := strconv.ParseFloat(row.RowData[persnoInx], 64)
-		if err != nil {
-			return err
-		}
+	return Variable{Header: column, Value: value}, nil
+}
 
-		n := (quota * 100000000000) + (week * 1000000000) + (w1yr * 100000000) +
-			(qrtr * 10000000) + (addr * 100000) + (wavfnd * 10000) + (hhld * 100) + persno
+func (sf UKFilter) casenoVariable(header []types.Header) (Variable, error) {
 
-		row.RowData = append(row.RowData, fmt.Sprintf("%d", int64(n)))
-		data.Rows[i] = row
+	positions, err := findPositions(header, "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO")
+	if err != nil {
+		return Variable{}, err
 	}
 
 	column := types.Header{
@@ -207,8 +116,14 @@ func (sf UKFilter) addCaseno(data *types.SavImportData) error {
 		Drop:                false,
 	}
 
-	data.Header = append(data.Header, column)
-	data.HeaderCount = data.HeaderCount + 1
+	value := func(row []string) (string, error) {
+		v, err := rowValues(row, positions)
+		if err != nil {
+			return "", err
+		}
+		n := util.CaseNo(v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7])
+		return fmt.Sprintf("%d", n), nil
+	}
 
-	return nil
+	return Variable{Header: column, Value: value}, nil
 }
@@ -0,0 +1,345 @@
+package jobs
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"services/api/ws"
+	"services/config"
+	"services/db"
+	"services/types"
+	"services/util"
+	"sync"
+	"time"
+)
+
+// Processor does the work for one type of upload job. Progress is reported through status; returning
+// an error marks the job as failed so that it can be retried.
+type Processor func(job types.UploadJob, status *types.WSMessage) error
+
+// Queue is a database backed queue of upload jobs worked by a fixed size pool of workers. As the queue
+// lives in the database, jobs survive a restart of the service and can be shared between instances.
+type Queue struct {
+	workers     int
+	poll        time.Duration
+	staleAfter  time.Duration
+	maxAttempts int
+	processors  map[types.JobType]Processor
+	wake        chan struct{}
+	mux         *sync.Mutex
+}
+
+func NewQueue() *Queue {
+	poll, err := time.ParseDuration(config.Config.Jobs.PollInterval)
+	if err != nil || poll <= 0 {
+		log.Fatal().
+			Err(err).
+			Str("service", "LFS").
+			Msgf("jobs pollInterval configuration error")
+	}
+
+	staleAfter, err := time.ParseDuration(config.Config.Jobs.StaleAfter)
+	if err != nil || staleAfter <= 0 {
+		log.Fatal().
+			Err(err).
+			Str("service", "LFS").
+			Msgf("jobs staleAfter configuration error")
+	}
+
+	workers := config.Config.Jobs.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	maxAttempts := config.Config.Jobs.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &Queue{
+		workers:     workers,
+		poll:        poll,
+		staleAfter:  staleAfter,
+		maxAttempts: maxAttempts,
+		processors:  make(map[types.JobType]Processor),
+		wake:        make(chan struct{}, 1),
+		mux:         &sync.Mutex{},
+	}
+}
+
+func (q *Queue) Register(jobType types.JobType, processor Processor) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.processors[jobType] = processor
+}
+
+func (q *Queue) processor(jobType types.JobType) (Processor, bool) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	p, ok := q.processors[jobType]
+	return p, ok
+}
+
+// Start launches the worker pool and the monitor that requeues jobs abandoned by a previous run.
+func (q *Queue) Start() {
+	log.Info().
+		Int("workers", q.workers).
+		Str("pollInterval", q.poll.String()).
+		Msg("Starting upload job workers")
+
+	go q.monitor()
+
+	for i := 0; i < q.workers; i++ {
+		go q.worker(i)
+	}
+}
+
+func (q *Queue) Enqueue(job types.UploadJob) (types.UploadJob, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return types.UploadJob{}, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	job, err = database.CreateUploadJob(job)
+	if err != nil {
+		return types.UploadJob{}, err
+	}
+
+	// create the status entry now so that clients can start watching before a worker picks the job up
+	uploads := ws.NewFileUploads()
+	uploads.Add(job.Id, job.FileName)
+
+	log.Debug().
+		Int("jobId", job.Id).
+		Str("jobType", string(job.JobType)).
+		Str("fileName", job.FileName).
+		Msg("Upload job queued")
+
+	q.notify()
+	return job, nil
+}
+
+// Cancel removes a queued or failed job from the queue. Jobs that are already running cannot be cancelled.
+func (q *Queue) Cancel(id int) (types.UploadJob, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return types.UploadJob{}, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	job, err := database.GetUploadJob(id)
+	if err != nil {
+		return types.UploadJob{}, err
+	}
+
+	ok, err := database.ChangeUploadJobStatus(id, types.JobCancelled, types.JobQueued, types.JobFailed)
+	if err != nil {
+		return types.UploadJob{}, err
+	}
+	if !ok {
+		return types.UploadJob{}, fmt.Errorf("job %d is %s and cannot be cancelled", id, job.Status)
+	}
+
+	_ = os.Remove(job.FilePath)
+	uploads := ws.NewFileUploads()
+	uploads.Add(job.Id, job.FileName).SetUploadCancelled()
+
+	log.Info().
+		Int("jobId", id).
+		Str("fileName", job.FileName).
+		Msg("Upload job cancelled")
+
+	return database.GetUploadJob(id)
+}
+
+// Retry puts a failed job back on the queue, with all of its attempts to run again.
+func (q *Queue) Retry(id int) (types.UploadJob, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return types.UploadJob{}, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	job, err := database.GetUploadJob(id)
+	if err != nil {
+		return types.UploadJob{}, err
+	}
+
+	if _, err := os.Stat(job.FilePath); err != nil {
+		return types.UploadJob{}, fmt.Errorf("the uploaded file for job %d is no longer available", id)
+	}
+
+	ok, err := database.RetryUploadJob(id)
+	if err != nil {
+		return types.UploadJob{}, err
+	}
+	if !ok {
+		return types.UploadJob{}, fmt.Errorf("job %d is %s and cannot be retried", id, job.Status)
+	}
+
+	uploads := ws.NewFileUploads()
+	uploads.Add(job.Id, job.FileName)
+
+	log.Info().
+		Int("jobId", id).
+		Str("fileName", job.FileName).
+		Msg("Upload job requeued")
+
+	q.notify()
+	return database.GetUploadJob(id)
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) wait() {
+	select {
+	case <-q.wake:
+	case <-time.After(q.poll):
+	}
+}
+
+func (q *Queue) worker(n int) {
+	for {
+		database, err := db.GetDefaultPersistenceImpl()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int("worker", n).
+				Msg("Cannot connect to database")
+			q.wait()
+			continue
+		}
+
+		job, found, err := database.ClaimUploadJob()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int("worker", n).
+				Msg("Cannot claim upload job")
+			q.wait()
+			continue
+		}
+
+		if !found {
+			q.wait()
+			continue
+		}
+
+		q.run(database, job)
+	}
+}
+
+func (q *Queue) run(database db.Persistence, job types.UploadJob) {
+	startTime := time.Now()
+
+	log.Info().
+		Int("jobId", job.Id).
+		Str("jobType", string(job.JobType)).
+		Str("fileName", job.FileName).
+		Int("attempt", job.Attempts).
+		Msg("Upload job started")
+
+	uploads := ws.NewFileUploads()
+	status := uploads.Add(job.Id, job.FileName)
+	status.SetUploadStarted()
+
+	done := make(chan struct{})
+	go q.heartbeat(database, job.Id, done)
+
+	err := q.process(job, status)
+	close(done)
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("jobId", job.Id).
+			Str("fileName", job.FileName).
+			Msg("Upload job failed")
+		status.SetUploadError(err.Error())
+		if err := database.FinishUploadJob(job.Id, types.JobFailed, err.Error()); err != nil {
+			log.Error().
+				Err(err).
+				Int("jobId", job.Id).
+				Msg("Cannot update upload job")
+		}
+		return
+	}
+
+	status.SetUploadFinished()
+	if err := database.FinishUploadJob(job.Id, types.JobFinished, ""); err != nil {
+		log.Error().
+			Err(err).
+			Int("jobId", job.Id).
+			Msg("Cannot update upload job")
+	}
+
+	_ = os.Remove(job.FilePath)
+
+	log.Info().
+		Int("jobId", job.Id).
+		Str("fileName", job.FileName).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Upload job finished")
+}
+
+func (q *Queue) process(job types.UploadJob, status *types.WSMessage) (err error) {
+	p, ok := q.processor(job.JobType)
+	if !ok {
+		return fmt.Errorf("no processor registered for job type %s", job.JobType)
+	}
+
+	// a bad file must not take the worker down with it
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("upload job failed unexpectedly: %v", r)
+		}
+	}()
+
+	return p(job, status)
+}
+
+func (q *Queue) heartbeat(database db.Persistence, id int, done chan struct{}) {
+	ticker := time.NewTicker(q.staleAfter / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := database.TouchUploadJob(id); err != nil {
+				log.Warn().
+					Err(err).
+					Int("jobId", id).
+					Msg("Cannot update upload job heartbeat")
+			}
+		}
+	}
+}
+
+func (q *Queue) monitor() {
+	for {
+		database, err := db.GetDefaultPersistenceImpl()
+		if err == nil {
+			n, err := database.RequeueStaleUploadJobs(q.staleAfter, q.maxAttempts)
+			if err != nil {
+				log.Error().
+					Err(err).
+					Msg("Cannot requeue stale upload jobs")
+			}
+			if n > 0 {
+				log.Info().
+					Int("jobs", n).
+					Msg("Requeued stale upload jobs")
+				q.notify()
+			}
+		}
+		time.Sleep(q.staleAfter / 2)
+	}
+}
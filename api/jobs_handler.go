@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/api/jobs"
+	"strconv"
+)
+
+type JobsHandler struct {
+	queue *jobs.Queue
+}
+
+func NewJobsHandler(queue *jobs.Queue) *JobsHandler {
+	return &JobsHandler{queue: queue}
+}
+
+func (j JobsHandler) HandleAllJobsRequest(w http.ResponseWriter, r *http.Request) {
+
+	res, err := j.getAllJobs()
+	if err != nil {
+		log.Error().Err(err).Msg("Get all upload jobs failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (j JobsHandler) HandleJobRequest(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := j.jobId(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := j.getJob(id)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (j JobsHandler) HandleRetryJobRequest(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := j.jobId(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := j.queue.Retry(id)
+	if err != nil {
+		log.Warn().Err(err).Int("jobId", id).Msg("Cannot retry upload job")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (j JobsHandler) HandleCancelJobRequest(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := j.jobId(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := j.queue.Cancel(id)
+	if err != nil {
+		log.Warn().Err(err).Int("jobId", id).Msg("Cannot cancel upload job")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (j JobsHandler) jobId(w http.ResponseWriter, r *http.Request) (int, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	idNo, err := strconv.Atoi(id)
+	if err != nil {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid job id: %s, expected an integer", id)}.sendResponse(w, r)
+		return 0, false
+	}
+
+	return idNo, true
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/rs/zerolog/log"
+	"services/db"
+	"services/types"
+)
+
+func (j JobsHandler) getAllJobs() ([]types.UploadJob, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return nil, err
+	}
+
+	return dbase.GetUploadJobs()
+}
+
+func (j JobsHandler) getJob(id int) (types.UploadJob, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.UploadJob{}, err
+	}
+
+	return dbase.GetUploadJob(id)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/util"
+	"time"
+)
+
+/*
+The URI of a request as it is logged. A web socket upgrade can carry its session token in the token query
+parameter, which must never reach the logs.
+*/
+func logURI(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+
+	u := *r.URL
+	if q := u.Query(); q.Has("token") {
+		q.Del("token")
+		u.RawQuery = q.Encode()
+	}
+	return u.RequestURI()
+}
+
+// LoggingMiddleware logs each request as it is received and once it has completed
+func LoggingMiddleware(next http.Handler) http.Handler {
+
+	log.Info().Msg("Logging middleware registered")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug().
+			Str("URI:", logURI(r)).
+			Str("client", r.RemoteAddr).
+			Msg("-> Received request")
+		startTime := time.Now()
+		next.ServeHTTP(w, r)
+
+		log.Debug().
+			Str("URI:", logURI(r)).
+			Str("elapsedTime", util.FmtDuration(startTime)).
+			Msg("<- Request Completed")
+	})
+}
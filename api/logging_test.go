@@ -0,0 +1,42 @@
+package api
+
+import (
+	"bytes"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogURI(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws?token=secret&fileName=a.sav", nil)
+	assert.Equal(t, "/ws?fileName=a.sav", logURI(r))
+
+	r = httptest.NewRequest("GET", "/survey/2019?limit=10", nil)
+	assert.Equal(t, "/survey/2019?limit=10", logURI(r))
+}
+
+func TestTokenNotLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	defer func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	unauthorised := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UnauthorizedResponse{ErrorMessage: "session not found or expired"}.sendResponse(w, r)
+	})
+
+	r := httptest.NewRequest("GET", "/ws?token=secret", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	LoggingMiddleware(unauthorised).ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Contains(t, buf.String(), "/ws", "request not logged")
+	assert.NotContains(t, buf.String(), "secret", "token written to the log")
+}
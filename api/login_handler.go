@@ -4,14 +4,61 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 	"net/http"
+	"services/config"
+	"time"
 )
 
-type LoginHandler struct{}
+type LoginHandler struct {
+	tokenLifetime      time.Duration
+	sessionLifetime    time.Duration
+	maxFailedLogins    int
+	loginBackoff       time.Duration
+	maxLoginBackoff    time.Duration
+	loginBackoffWindow time.Duration
+}
+
+// a duration from the auth configuration, which the service cannot start without
+func authDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatal().
+			Err(err).
+			Str("service", "LFS").
+			Msgf("auth %s configuration error", name)
+	}
+	return d
+}
 
 func NewLoginHandler() *LoginHandler {
-	return &LoginHandler{}
+	tokenLifetime := authDuration("tokenLifetime", config.Config.Auth.TokenLifetime)
+
+	sessionLifetime := authDuration("sessionLifetime", config.Config.Auth.SessionLifetime)
+	if sessionLifetime < tokenLifetime {
+		log.Fatal().
+			Str("service", "LFS").
+			Msgf("auth sessionLifetime configuration error, shorter than tokenLifetime")
+	}
+
+	maxFailedLogins := config.Config.Auth.MaxFailedLogins
+	if maxFailedLogins < 1 {
+		maxFailedLogins = 1
+	}
+
+	return &LoginHandler{
+		tokenLifetime:      tokenLifetime,
+		sessionLifetime:    sessionLifetime,
+		maxFailedLogins:    maxFailedLogins,
+		loginBackoff:       authDuration("loginBackoff", config.Config.Auth.LoginBackoff),
+		maxLoginBackoff:    authDuration("maxLoginBackoff", config.Config.Auth.MaxLoginBackoff),
+		loginBackoffWindow: authDuration("loginBackoffWindow", config.Config.Auth.LoginBackoffWindow),
+	}
 }
 
+/*
+Log in with the password in the password header, returning the token that every other request must carry in its
+Authorization header as a bearer token. After a failed login, further logins by the same user or from the same
+client are refused for a time that doubles with each failure.
+*/
 func (l LoginHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Assign username and password variables
@@ -20,11 +67,37 @@ func (l LoginHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.Header.Get("password")
 
 	// Call login service to validate
-	res := l.login(username, password)
-
-	if res != nil {
+	session, err := l.login(username, password, clientAddress(r))
+	if err != nil {
 		log.Debug().Msg("Login request failed")
-		ErrorResponse{Status: Error, ErrorMessage: res.Error()}.sendResponse(w, r)
+		if throttled, ok := err.(loginThrottled); ok {
+			TooManyRequestsResponse{ErrorMessage: err.Error(), RetryAfter: throttled.retryAfter}.sendResponse(w, r)
+			return
+		}
+		UnauthorizedResponse{ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, session)
+}
+
+/*
+Replace the token of a request with a new one that expires later. The old token cannot be used again.
+*/
+func (l LoginHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := l.refresh(r)
+	if err != nil {
+		UnauthorizedResponse{ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, session)
+}
+
+// End the session of the token of a request
+func (l LoginHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if err := l.logout(r); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
 	}
 
@@ -1,17 +1,134 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/validator.v2"
+	"net"
+	"net/http"
 	_ "services/api/validate"
 	"services/db"
 	"services/types"
 	"strings"
+	"time"
 )
 
-func (l LoginHandler) login(username string, password string) error {
+// a login refused because of recent failures, to be tried again after retryAfter
+type loginThrottled struct {
+	retryAfter time.Duration
+}
+
+func (e loginThrottled) Error() string {
+	return fmt.Sprintf("too many failed logins, try again in %s", e.retryAfter.Round(time.Second))
+}
+
+/*
+The address of the client making a request. X-Forwarded-For is not used, as a client could set it to anything.
+*/
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/*
+How long to wait before another login after failures, the last at last. The wait doubles with each failure, up
+to the maximum.
+*/
+func (l LoginHandler) backoff(failures int, last *time.Time, now time.Time) time.Duration {
+	if failures == 0 || last == nil {
+		return 0
+	}
+
+	delay := l.loginBackoff
+	for i := 1; i < failures && delay < l.maxLoginBackoff; i++ {
+		delay *= 2
+	}
+	if delay > l.maxLoginBackoff {
+		delay = l.maxLoginBackoff
+	}
+
+	if wait := last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// record an authentication event. A failure to do so is logged rather than failing the request
+func auditAuthEvent(dbase db.Persistence, username, client string, event types.AuthEvent, message string) {
+	e := types.AuthAudit{
+		Username:   username,
+		Client:     client,
+		Event:      event,
+		Message:    message,
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := dbase.AuditAuthEvent(e); err != nil {
+		log.Error().
+			Err(err).
+			Str("user", username).
+			Str("event", string(event)).
+			Msg("Cannot record authentication event")
+	}
+}
+
+// back off from a username or client that has recently failed to log in
+func (l LoginHandler) throttle(dbase db.Persistence, username, client string, now time.Time) error {
+	failures, err := dbase.GetLoginFailures(username, client, now.Add(-l.loginBackoffWindow))
+	if err != nil {
+		return err
+	}
+	wait := l.backoff(failures.User, failures.UserLast, now)
+	if w := l.backoff(failures.Client, failures.ClientLast, now); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		auditAuthEvent(dbase, username, client, types.LoginThrottled, fmt.Sprintf("retry after %s", wait.Round(time.Second)))
+		return loginThrottled{retryAfter: wait}
+	}
+	return nil
+}
+
+/*
+A failed login is recorded and counted against the user, locking their account once too many have failed in a row
+*/
+func (l LoginHandler) loginFailed(dbase db.Persistence, username, client, reason string, known bool) error {
+	log.Warn().
+		Str("user", username).
+		Str("client", client).
+		Str("reason", reason).
+		Msg("Login failed")
+
+	auditAuthEvent(dbase, username, client, types.LoginFailed, reason)
+
+	if known {
+		locked, err := dbase.RecordLoginFailure(username, l.maxFailedLogins)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("user", username).
+				Msg("Cannot record failed login")
+		}
+		if locked {
+			auditAuthEvent(dbase, username, client, types.AccountLocked,
+				fmt.Sprintf("%d failed logins", l.maxFailedLogins))
+			log.Warn().
+				Str("user", username).
+				Msg("Account locked")
+		}
+	}
+
+	return fmt.Errorf("invalid username or password")
+}
+
+func (l LoginHandler) login(username, password, client string) (types.Session, error) {
 	log.Debug().Msg("Validating login input")
 
 	// Validate user input
@@ -19,7 +136,7 @@ func (l LoginHandler) login(username string, password string) error {
 
 	if errs := validator.Validate(userCreds); errs != nil {
 		log.Error().Msg("Invalid Username or Password.")
-		return fmt.Errorf("invalid username or password")
+		return types.Session{}, fmt.Errorf("invalid username or password")
 	}
 
 	log.Debug().Msg("Retrieving user credentials from database")
@@ -28,11 +145,17 @@ func (l LoginHandler) login(username string, password string) error {
 	creds, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().Err(err)
-		return err
+		return types.Session{}, err
 	}
+
+	now := time.Now().UTC()
+	if err := l.throttle(creds, username, client, now); err != nil {
+		return types.Session{}, err
+	}
+
 	user, err := creds.GetUserID(username)
 	if err != nil {
-		return err
+		return types.Session{}, l.loginFailed(creds, username, client, "unknown user", false)
 	}
 
 	log.Debug().Msg("Assert user credentials match")
@@ -41,11 +164,49 @@ func (l LoginHandler) login(username string, password string) error {
 	matchErr := l.comparePasswords(user.Password, password)
 
 	if strings.Compare(username, user.Username) != 0 || matchErr == false {
-		log.Error().Msg("Invalid username or password")
-		return fmt.Errorf("invalid username or password")
+		return types.Session{}, l.loginFailed(creds, username, client, "wrong password", true)
 	}
 
-	return nil
+	// a lock is only reported to someone who knows the password, so it cannot be used to find accounts
+	if user.LockedAt != nil {
+		auditAuthEvent(creds, username, client, types.LoginFailed, "account locked")
+		return types.Session{}, fmt.Errorf("account %s is locked, ask an admin to unlock it", username)
+	}
+
+	if user.Disabled {
+		auditAuthEvent(creds, username, client, types.LoginFailed, "account disabled")
+		log.Warn().
+			Str("user", username).
+			Msg("Login to disabled account")
+		return types.Session{}, fmt.Errorf("account %s is disabled", username)
+	}
+
+	roles, err := creds.GetUserRoles(user.Username)
+	if err != nil {
+		return types.Session{}, err
+	}
+
+	session := types.Session{Username: user.Username, CreatedAt: now, Roles: roles}
+	if err := l.issueToken(&session, now); err != nil {
+		return types.Session{}, err
+	}
+
+	if err := creds.CreateSession(session); err != nil {
+		log.Error().
+			Err(err).
+			Str("user", username).
+			Msg("Cannot create session")
+		return types.Session{}, fmt.Errorf("cannot create session: %s", err)
+	}
+
+	auditAuthEvent(creds, session.Username, client, types.LoginSucceeded, "")
+
+	log.Info().
+		Str("user", session.Username).
+		Str("client", client).
+		Msg("User logged in")
+
+	return session, nil
 }
 
 func (l LoginHandler) comparePasswords(hashedPwd string, plainPwd string) bool {
@@ -57,3 +218,87 @@ func (l LoginHandler) comparePasswords(hashedPwd string, plainPwd string) bool {
 	}
 	return true
 }
+
+/*
+Give a session a new random token, expiring after the token lifetime but never beyond the session lifetime
+*/
+func (l LoginHandler) issueToken(session *types.Session, now time.Time) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("cannot generate token: %s", err)
+	}
+
+	session.Token = base64.RawURLEncoding.EncodeToString(b)
+	session.TokenHash = hashToken(session.Token)
+
+	session.ExpiresAt = now.Add(l.tokenLifetime)
+	if end := session.CreatedAt.Add(l.sessionLifetime); session.ExpiresAt.After(end) {
+		session.ExpiresAt = end
+	}
+
+	return nil
+}
+
+// tokens are stored hashed so that a copy of the sessions table cannot be used to make requests
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (l LoginHandler) refresh(r *http.Request) (types.Session, error) {
+	session, ok := requestSession(r)
+	if !ok {
+		return types.Session{}, fmt.Errorf("not logged in")
+	}
+
+	now := time.Now().UTC()
+	if !session.CreatedAt.Add(l.sessionLifetime).After(now) {
+		return types.Session{}, fmt.Errorf("session has expired, log in again")
+	}
+
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.Session{}, err
+	}
+
+	tokenHash := session.TokenHash
+	if err := l.issueToken(&session, now); err != nil {
+		return types.Session{}, err
+	}
+
+	if err := dbase.RefreshSession(tokenHash, session); err != nil {
+		return types.Session{}, err
+	}
+
+	log.Debug().
+		Str("user", session.Username).
+		Msg("Session refreshed")
+
+	return session, nil
+}
+
+func (l LoginHandler) logout(r *http.Request) error {
+	session, ok := requestSession(r)
+	if !ok {
+		return fmt.Errorf("not logged in")
+	}
+
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return err
+	}
+
+	if err := dbase.DeleteSession(session.TokenHash); err != nil {
+		return err
+	}
+
+	auditAuthEvent(dbase, session.Username, clientAddress(r), types.Logout, "")
+
+	log.Info().
+		Str("user", session.Username).
+		Msg("User logged out")
+
+	return nil
+}
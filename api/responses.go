@@ -2,8 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/gocarina/gocsv"
 	"github.com/rs/zerolog/log"
+	"io"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +31,22 @@ type ErrorResponse struct {
 	ErrorMessage string `json:"errorMessage"`
 }
 
+type UnauthorizedResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type TooManyRequestsResponse struct {
+	Status       string        `json:"status"`
+	ErrorMessage string        `json:"errorMessage"`
+	RetryAfter   time.Duration `json:"-"`
+}
+
+type ForbiddenResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
 type BadDataResponse struct {
 	Status       string `json:"status"`
 	ErrorMessage string `json:"errorMessage"`
@@ -37,6 +59,15 @@ type OkayResponse struct {
 
 type SendDataResponse struct{}
 
+type SendCSVResponse struct {
+	FileName string
+}
+
+type SendFileResponse struct {
+	FileName    string
+	ContentType string
+}
+
 type NoRecordsFoundStatus struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
@@ -46,6 +77,7 @@ type InProgressResponse struct {
 	Status  string `json:"status"`
 	When    string `json:"time"`
 	Message string `json:"message"`
+	JobId   int    `json:"jobId,omitempty"`
 }
 
 func (re NoRecordsFoundStatus) sendResponse(w http.ResponseWriter, r *http.Request) {
@@ -61,11 +93,48 @@ func (re SendDataResponse) sendResponse(w http.ResponseWriter, r *http.Request,
 	if err := json.NewEncoder(w).Encode(d); err != nil {
 		log.Error().
 			Str("client", r.RemoteAddr).
-			Str("uri", r.RequestURI).
+			Str("uri", logURI(r)).
 			Msg("json.NewEncoder() failed in sendDataResponse")
 	}
 }
 
+func (re SendCSVResponse) sendResponse(w http.ResponseWriter, r *http.Request, d interface{}) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", re.FileName))
+	w.WriteHeader(http.StatusOK)
+	if err := gocsv.Marshal(d, w); err != nil {
+		log.Error().
+			Err(err).
+			Str("client", r.RemoteAddr).
+			Str("uri", logURI(r)).
+			Msg("gocsv.Marshal() failed in SendCSVResponse")
+	}
+}
+
+func (re SendFileResponse) sendResponse(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Type", re.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", re.FileName))
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, f); err != nil {
+		log.Error().
+			Err(err).
+			Str("client", r.RemoteAddr).
+			Str("uri", logURI(r)).
+			Msg("io.Copy() failed in SendFileResponse")
+	}
+}
+
 func (response InProgressResponse) sendResponse(w http.ResponseWriter, r *http.Request) {
 	response.Status = OK
 	response.When = time.Now().String()
@@ -97,7 +166,7 @@ func (response BadDataResponse) sendResponse(w http.ResponseWriter, r *http.Requ
 	if err := json.NewEncoder(w).Encode(s); err != nil {
 		log.Error().
 			Str("client", r.RemoteAddr).
-			Str("uri", r.RequestURI).
+			Str("uri", logURI(r)).
 			Msg("json.NewEncoder() failed in sendBadDataResponse")
 	}
 }
@@ -108,6 +177,26 @@ func (response ErrorResponse) sendResponse(w http.ResponseWriter, r *http.Reques
 	sendResponse(w, r, response)
 }
 
+func (response UnauthorizedResponse) sendResponse(w http.ResponseWriter, r *http.Request) {
+	response.Status = Error
+	w.Header().Set("WWW-Authenticate", `Bearer realm="lfs"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	sendResponse(w, r, response)
+}
+
+func (response TooManyRequestsResponse) sendResponse(w http.ResponseWriter, r *http.Request) {
+	response.Status = Error
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(response.RetryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	sendResponse(w, r, response)
+}
+
+func (response ForbiddenResponse) sendResponse(w http.ResponseWriter, r *http.Request) {
+	response.Status = Error
+	w.WriteHeader(http.StatusForbidden)
+	sendResponse(w, r, response)
+}
+
 func (response UnknownFileType) sendResponse(w http.ResponseWriter, r *http.Request) {
 	response.Status = Error
 	w.WriteHeader(http.StatusBadRequest)
@@ -119,7 +208,7 @@ func sendResponse(w http.ResponseWriter, r *http.Request, response Response) {
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Error().
 			Str("client", r.RemoteAddr).
-			Str("uri", r.RequestURI).
+			Str("uri", logURI(r)).
 			Msg("json.NewEncoder() failed in FileUploadHandler")
 	}
 }
@@ -5,49 +5,30 @@ import (
 	"github.com/rs/zerolog/log"
 	"net/http"
 	"os"
-	"services/api/ws"
+	"services/api/jobs"
 	"services/types"
 	"strconv"
-	"sync"
-	"time"
 )
 
 type SurveyImportHandler struct {
-	Audit            types.Audit
-	fileUploads      *types.WSMessage
-	uploadInProgress bool // we can only handle a single survey upload at a time
-	mutux            *sync.Mutex
+	queue *jobs.Queue
 }
 
-func NewSurveyHandler() *SurveyImportHandler {
-	return &SurveyImportHandler{
-		Audit:            types.Audit{},
-		fileUploads:      nil,
-		uploadInProgress: false,
-		mutux:            &sync.Mutex{},
-	}
-}
-
-func (si *SurveyImportHandler) setUpload(val bool) {
-	si.mutux.Lock()
-	defer si.mutux.Unlock()
-	si.uploadInProgress = val
+func NewSurveyHandler(queue *jobs.Queue) *SurveyImportHandler {
+	si := &SurveyImportHandler{queue: queue}
+	queue.Register(types.GBSurveyJob, si.processGBSurveyJob)
+	queue.Register(types.NISurveyJob, si.processNISurveyJob)
+	queue.Register(types.SurveyDryRunJob, si.processDryRunJob)
+	return si
 }
 
 /*
 Upload GB survey file.
-The mutux lock is a little awkward to ensure it is locked as soon as possible to avoid race conditions
+The file is saved and queued; it is processed when a worker becomes free. With ?dryRun=true the file is
+queued to be checked instead, leaving the survey table untouched, and its summary is the result of the job.
 */
 func (si *SurveyImportHandler) SurveyUploadGBHandler(w http.ResponseWriter, r *http.Request) {
 
-	if si.uploadInProgress {
-		log.Error().Msg("Survey file is currently being uploaded")
-		ErrorResponse{Status: Error, ErrorMessage: "survey file is currently being uploaded"}.sendResponse(w, r)
-		return
-	}
-
-	si.setUpload(true)
-
 	vars := mux.Vars(r)
 	week := vars["week"]
 	year := vars["year"]
@@ -56,7 +37,6 @@ func (si *SurveyImportHandler) SurveyUploadGBHandler(w http.ResponseWriter, r *h
 	if fileName == "" {
 		log.Error().Msg("File name not set")
 		ErrorResponse{Status: Error, ErrorMessage: "fileName not set"}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
@@ -64,7 +44,6 @@ func (si *SurveyImportHandler) SurveyUploadGBHandler(w http.ResponseWriter, r *h
 	if err != nil {
 		log.Error().Msg("Week is not an integer")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
@@ -72,47 +51,47 @@ func (si *SurveyImportHandler) SurveyUploadGBHandler(w http.ResponseWriter, r *h
 	if err != nil {
 		log.Error().Msg("Year is not an integer")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
 	gbInfo, err := FindGBBatch(weekNo, yearNo)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
 	tmpfile, err := SaveStreamToTempFile(w, r)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
-	a := ws.NewFileUploads()
-	si.fileUploads = a.Add(fileName)
-
-	go func() {
-		defer func() {
-			si.setUpload(false)
-			_ = os.Remove(tmpfile)
-		}()
-		si.parseGBSurveyFile(tmpfile, fileName, weekNo, yearNo, gbInfo.Id)
-	}()
-
-	InProgressResponse{OK, time.Now().String(), "request submitted"}.sendResponse(w, r)
-}
+	job := types.UploadJob{
+		JobType:     types.GBSurveyJob,
+		FileName:    fileName,
+		FilePath:    tmpfile,
+		FileSource:  types.GBSource,
+		BatchId:     gbInfo.Id,
+		Week:        weekNo,
+		Year:        yearNo,
+		SubmittedBy: actingUser(r),
+	}
 
-func (si *SurveyImportHandler) SurveyUploadNIHandler(w http.ResponseWriter, r *http.Request) {
+	if isDryRun(r) {
+		job.JobType = types.SurveyDryRunJob
+	}
 
-	if si.uploadInProgress {
-		log.Error().Msg("Survey file is currently being uploaded")
-		ErrorResponse{Status: Error, ErrorMessage: "survey file is currently being uploaded"}.sendResponse(w, r)
+	job, err = si.queue.Enqueue(job)
+	if err != nil {
+		_ = os.Remove(tmpfile)
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
 	}
 
-	si.setUpload(true)
+	InProgressResponse{JobId: job.Id}.sendResponse(w, r)
+}
+
+func (si *SurveyImportHandler) SurveyUploadNIHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	month := vars["month"]
@@ -122,7 +101,6 @@ func (si *SurveyImportHandler) SurveyUploadNIHandler(w http.ResponseWriter, r *h
 	if fileName == "" {
 		log.Error().Msg("File name not set")
 		ErrorResponse{Status: Error, ErrorMessage: "fileName not set"}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
@@ -130,7 +108,6 @@ func (si *SurveyImportHandler) SurveyUploadNIHandler(w http.ResponseWriter, r *h
 	if err != nil {
 		log.Error().Msg("Month is not an integer")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
@@ -138,35 +115,48 @@ func (si *SurveyImportHandler) SurveyUploadNIHandler(w http.ResponseWriter, r *h
 	if err != nil {
 		log.Error().Msg("Year is not an integer")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
 	niInfo, err := FindNIBatch(monthNo, yearNo)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
 	tmpfile, err := SaveStreamToTempFile(w, r)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		si.setUpload(false)
 		return
 	}
 
-	a := ws.NewFileUploads()
-	si.fileUploads = a.Add(fileName)
+	job := types.UploadJob{
+		JobType:     types.NISurveyJob,
+		FileName:    fileName,
+		FilePath:    tmpfile,
+		FileSource:  types.NISource,
+		BatchId:     niInfo.Id,
+		Month:       monthNo,
+		Year:        yearNo,
+		SubmittedBy: actingUser(r),
+	}
 
-	go func() {
-		defer func() {
-			si.setUpload(false)
-			_ = os.Remove(tmpfile)
-		}()
-		si.parseNISurveyFile(tmpfile, fileName, monthNo, yearNo, niInfo.Id)
-	}()
+	if isDryRun(r) {
+		job.JobType = types.SurveyDryRunJob
+	}
 
-	InProgressResponse{OK, time.Now().String(), "request submitted"}.sendResponse(w, r)
+	job, err = si.queue.Enqueue(job)
+	if err != nil {
+		_ = os.Remove(tmpfile)
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	InProgressResponse{JobId: job.Id}.sendResponse(w, r)
+
+}
 
+func isDryRun(r *http.Request) bool {
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	return err == nil && dryRun
 }
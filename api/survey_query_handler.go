@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/db"
+	"services/util"
+	"strings"
+	"time"
+)
+
+type SurveyQueryHandler struct{}
+
+func NewSurveyQueryHandler() *SurveyQueryHandler {
+	return &SurveyQueryHandler{}
+}
+
+/*
+Query the rows of a dataset for a year (2019), month (2019/1) or quarter (2019/Q1), the current survey rows unless
+the dataset parameter is set, or only those of one of its batches with batch. The rows are streamed as JSON, or as
+CSV with format=csv, a page at a time.
+*/
+func (sq SurveyQueryHandler) HandleQueryRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	period, err := parseSurveyPeriod(vars)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid format: %s, expected json or csv", format)}.sendResponse(w, r)
+		return
+	}
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("cannot connect to database: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	gb, err := database.GetAllGBDefinitions()
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("cannot get variable definitions: %s", err)}.sendResponse(w, r)
+		return
+	}
+	ni, err := database.GetAllNIDefinitions()
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("cannot get variable definitions: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	query, err := parseSurveyQuery(period, r.URL.Query(), gb, ni)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	var writer surveyWriter
+	if format == "csv" {
+		// a CSV file needs the same columns in every row
+		if len(query.Variables) == 0 {
+			query.Variables = definedVariables(gb, ni)
+		}
+		writer = newSurveyCSVWriter(w, fmt.Sprintf("%s_%s.csv", query.Dataset, period), query.Variables)
+	} else {
+		writer = &surveyJSONWriter{w: w, query: query}
+	}
+
+	startTime := time.Now()
+	rows := 0
+
+	last, err := database.QuerySurvey(query, func(columns map[string]interface{}) error {
+		rows++
+		return writer.row(columns)
+	})
+	if err == nil {
+		// a short page is the last
+		var next int64
+		if rows == query.Limit {
+			next = last
+		}
+		err = writer.end(next)
+	}
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("period", period.String()).
+			Msg("Survey query failed")
+		// once rows have been sent the response can only be cut short
+		if !writer.started() {
+			ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		}
+		return
+	}
+
+	log.Debug().
+		Str("period", period.String()).
+		Int("conditions", len(query.Conditions)).
+		Int("rows", rows).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Queried survey data")
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"services/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSurveyQueryLimit = 1000
+	maxSurveyQueryLimit     = 100000
+)
+
+var (
+	variablePattern  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	conditionPattern = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9_]*)\s*(>=|<=|!=|<>|==|=|>|<)\s*(.*?)\s*$`)
+)
+
+// the variables asked for, one to each variables parameter, upper cased as they are stored
+func parseSurveyVariables(values []string) ([]string, error) {
+	var variables []string
+	seen := make(map[string]bool)
+
+	for _, v := range values {
+		name := strings.ToUpper(strings.TrimSpace(v))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !variablePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable: %s", v)
+		}
+		seen[name] = true
+		variables = append(variables, name)
+	}
+
+	return variables, nil
+}
+
+/*
+A condition such as AGE>=16 or SEX=1. The value of a string variable can be quoted and is compared as text; the
+value of any other variable must be a number. The type of a variable comes from its definition.
+*/
+func parseSurveyCondition(condition string, header map[string]types.Header) (types.SurveyCondition, error) {
+	m := conditionPattern.FindStringSubmatch(condition)
+	if m == nil {
+		return types.SurveyCondition{}, fmt.Errorf("invalid filter: %s, expected a variable, an operator and a value", condition)
+	}
+
+	c := types.SurveyCondition{Variable: strings.ToUpper(m[1]), Operator: types.Operator(m[2])}
+	switch m[2] {
+	case "==":
+		c.Operator = types.Equal
+	case "<>":
+		c.Operator = types.NotEqual
+	}
+
+	value := m[3]
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+
+	h, ok := header[c.Variable]
+	if !ok {
+		return types.SurveyCondition{}, fmt.Errorf("invalid filter: %s, %s is not a variable", condition, c.Variable)
+	}
+
+	if h.VariableType == types.TypeString {
+		c.Value = value
+		return c, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return types.SurveyCondition{}, fmt.Errorf("invalid filter: %s, %s is numeric", condition, c.Variable)
+	}
+	c.Value = f
+
+	return c, nil
+}
+
+func parsePageParameter(query url.Values, name string, defaultValue, maxValue int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 || (maxValue > 0 && i > maxValue) {
+		if maxValue > 0 {
+			return 0, fmt.Errorf("invalid %s: %s, expected an integer from 0 to %d", name, value, maxValue)
+		}
+		return 0, fmt.Errorf("invalid %s: %s, expected a positive integer", name, value)
+	}
+
+	return i, nil
+}
+
+// the key of the last row of the page before, 0 for the first page
+func parseAfterParameter(query url.Values) (int64, error) {
+	value := query.Get("after")
+	if value == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid after: %s, expected the next value of the page before", value)
+	}
+
+	return i, nil
+}
+
+// the year of a request, with its month or quarter if given
+func parseSurveyPeriod(vars map[string]string) (types.ExportPeriod, error) {
+	if vars["period"] == "" {
+		return parseExportPeriod(vars["year"])
+	}
+	return parseExportPeriod(fmt.Sprintf("%s-%s", vars["year"], vars["period"]))
+}
+
+// build a survey query from the request
+func parseSurveyQuery(period types.ExportPeriod, query url.Values, definitions ...[]types.VariableDefinitions) (types.SurveyQuery, error) {
+	q := types.SurveyQuery{Period: period}
+
+	var err error
+	if q.Dataset, err = parseDataset(query.Get("dataset")); err != nil {
+		return q, err
+	}
+
+	if q.Variables, err = parseSurveyVariables(query["variables"]); err != nil {
+		return q, err
+	}
+
+	if q.Conditions, err = parseSurveyConditions(query["filter"], surveyHeader(definitions...)); err != nil {
+		return q, err
+	}
+
+	if q.BatchId, err = parsePageParameter(query, "batch", 0, 0); err != nil {
+		return q, err
+	}
+
+	if q.Limit, err = parsePageParameter(query, "limit", defaultSurveyQueryLimit, maxSurveyQueryLimit); err != nil {
+		return q, err
+	}
+	if q.After, err = parseAfterParameter(query); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+/*
+The filters of a request, one to each filter parameter, so that a quoted value can hold any character.
+*/
+func parseSurveyConditions(filters []string, header map[string]types.Header) ([]types.SurveyCondition, error) {
+	var conditions []types.SurveyCondition
+
+	for _, condition := range filters {
+		if strings.TrimSpace(condition) == "" {
+			continue
+		}
+		c, err := parseSurveyCondition(condition, header)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+// every defined variable by name
+func surveyHeader(definitions ...[]types.VariableDefinitions) map[string]types.Header {
+	header := make(map[string]types.Header)
+	for _, h := range exportHeader(definedVariables(definitions...), definitions...) {
+		header[h.VariableName] = h
+	}
+	return header
+}
+
+// the names of every defined variable, in name order
+func definedVariables(definitions ...[]types.VariableDefinitions) []string {
+	seen := make(map[string]bool)
+	var variables []string
+
+	for _, defs := range definitions {
+		for _, d := range defs {
+			name := strings.ToUpper(d.Variable)
+			if !seen[name] {
+				seen[name] = true
+				variables = append(variables, name)
+			}
+		}
+	}
+
+	sort.Strings(variables)
+	return variables
+}
+
+/*
+surveyWriter streams the rows of a survey query to the client. Nothing is sent until the first row, so that an
+error running the query can still be reported. The page ends with the key to ask for the next page after, or 0
+if this is the last page.
+*/
+type surveyWriter interface {
+	row(columns map[string]interface{}) error
+	end(next int64) error
+	started() bool
+}
+
+type surveyJSONWriter struct {
+	w     http.ResponseWriter
+	query types.SurveyQuery
+	rows  int
+	begun bool
+}
+
+func (s *surveyJSONWriter) begin() error {
+	s.begun = true
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+	_, err := fmt.Fprintf(s.w, `{"status":%q,"dataset":%q,"period":%q,"batch":%d,"after":%d,"limit":%d,"rows":[`,
+		OK, s.query.Dataset, s.query.Period.String(), s.query.BatchId, s.query.After, s.query.Limit)
+	return err
+}
+
+func (s *surveyJSONWriter) row(columns map[string]interface{}) error {
+	if !s.begun {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+	if s.rows > 0 {
+		b = append([]byte(","), b...)
+	}
+	s.rows++
+
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *surveyJSONWriter) end(next int64) error {
+	if !s.begun {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(s.w, "],\"count\":%d,\"next\":%d}\n", s.rows, next)
+	return err
+}
+
+func (s *surveyJSONWriter) started() bool {
+	return s.begun
+}
+
+type surveyCSVWriter struct {
+	w         http.ResponseWriter
+	csv       *csv.Writer
+	fileName  string
+	variables []string
+	record    []string
+	begun     bool
+}
+
+func newSurveyCSVWriter(w http.ResponseWriter, fileName string, variables []string) *surveyCSVWriter {
+	return &surveyCSVWriter{w: w, csv: csv.NewWriter(w), fileName: fileName, variables: variables,
+		record: make([]string, len(variables))}
+}
+
+// the key of the next page follows the file as a trailer
+const nextPageTrailer = "X-Next-After"
+
+func (s *surveyCSVWriter) begin() error {
+	s.begun = true
+	s.w.Header().Set("Trailer", nextPageTrailer)
+	s.w.Header().Set("Content-Type", "text/csv")
+	s.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.fileName))
+	s.w.WriteHeader(http.StatusOK)
+	return s.csv.Write(s.variables)
+}
+
+// a missing value is an empty field
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func (s *surveyCSVWriter) row(columns map[string]interface{}) error {
+	if !s.begun {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+
+	for i, v := range s.variables {
+		s.record[i] = csvValue(columns[v])
+	}
+	return s.csv.Write(s.record)
+}
+
+func (s *surveyCSVWriter) end(next int64) error {
+	if !s.begun {
+		if err := s.begin(); err != nil {
+			return err
+		}
+	}
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		return err
+	}
+	s.w.Header().Set(nextPageTrailer, strconv.FormatInt(next, 10))
+	return nil
+}
+
+func (s *surveyCSVWriter) started() bool {
+	return s.begun
+}
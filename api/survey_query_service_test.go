@@ -0,0 +1,91 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"services/types"
+	"testing"
+)
+
+func testDefinitions() []types.VariableDefinitions {
+	return []types.VariableDefinitions{
+		{Variable: "AGE", VariableType: types.TypeInt8},
+		{Variable: "Sex", VariableType: types.TypeInt8},
+		{Variable: "POSTCODE", VariableType: types.TypeString, VariableLength: 8},
+	}
+}
+
+func TestParseSurveyVariables(t *testing.T) {
+	variables, err := parseSurveyVariables([]string{"age", " SEX ", "", "AGE"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AGE", "SEX"}, variables)
+
+	_, err = parseSurveyVariables([]string{"AGE,SEX"})
+	assert.Error(t, err, "a comma separated list is a single variable")
+}
+
+func TestParseSurveyCondition(t *testing.T) {
+	header := surveyHeader(testDefinitions())
+
+	c, err := parseSurveyCondition("age >= 16", header)
+	assert.NoError(t, err)
+	assert.Equal(t, types.SurveyCondition{Variable: "AGE", Operator: types.GreaterOrEqual, Value: 16.0}, c)
+
+	c, err = parseSurveyCondition("SEX==1", header)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Equal, c.Operator)
+
+	c, err = parseSurveyCondition("SEX<>1", header)
+	assert.NoError(t, err)
+	assert.Equal(t, types.NotEqual, c.Operator)
+
+	c, err = parseSurveyCondition(`POSTCODE='NP10, 8XG'`, header)
+	assert.NoError(t, err)
+	assert.Equal(t, "NP10, 8XG", c.Value)
+
+	_, err = parseSurveyCondition("AGE>=sixteen", header)
+	assert.Error(t, err, "a numeric variable compared with text")
+
+	_, err = parseSurveyCondition("HEIGHT>1", header)
+	assert.Error(t, err, "an undefined variable")
+
+	_, err = parseSurveyCondition("AGE", header)
+	assert.Error(t, err, "a condition without an operator")
+}
+
+func TestParseSurveyConditions(t *testing.T) {
+	header := surveyHeader(testDefinitions())
+
+	conditions, err := parseSurveyConditions([]string{"AGE>=16", " ", `POSTCODE="NP10,8XG"`}, header)
+	assert.NoError(t, err)
+	assert.Len(t, conditions, 2)
+	assert.Equal(t, "NP10,8XG", conditions[1].Value)
+}
+
+func TestParseSurveyQuery(t *testing.T) {
+	period := types.ExportPeriod{Year: 2019, Month: 1}
+
+	query := url.Values{
+		"variables": {"AGE", "SEX"},
+		"filter":    {"AGE>=16", "SEX=1"},
+		"batch":     {"12"},
+		"after":     {"5000"},
+	}
+	q, err := parseSurveyQuery(period, query, testDefinitions())
+	assert.NoError(t, err)
+	assert.Equal(t, types.SurveyDataset, q.Dataset)
+	assert.Equal(t, []string{"AGE", "SEX"}, q.Variables)
+	assert.Len(t, q.Conditions, 2)
+	assert.Equal(t, 12, q.BatchId)
+	assert.Equal(t, int64(5000), q.After)
+	assert.Equal(t, defaultSurveyQueryLimit, q.Limit)
+
+	for name, value := range map[string]string{
+		"limit": "100001",
+		"after": "-1",
+		"batch": "one",
+	} {
+		_, err = parseSurveyQuery(period, url.Values{name: {value}}, testDefinitions())
+		assert.Error(t, err, "%s=%s", name, value)
+	}
+}
@@ -3,110 +3,167 @@ package api
 import (
 	"fmt"
 	"github.com/rs/zerolog/log"
-	"reflect"
+	"path/filepath"
 	"services/api/filter"
+	"services/api/validate"
 	"services/db"
 	"services/importdata/sav"
+	"services/importdata/tabular"
 	"services/types"
 	"services/util"
+	"sort"
+	"strings"
 	"time"
 )
 
-func loadSav(in string, out interface{}) (types.SavImportData, error) {
-
-	// ensure out is a struct
-	if reflect.ValueOf(out).Kind() != reflect.Struct {
-		log.Error().
-			Str("method", "readSav").
-			Msg("The output interface is not a struct")
-		return types.SavImportData{}, fmt.Errorf("%T is not a struct type", out)
+// stream a SAV file rather than reading it into memory
+func savReader(fileName string) types.RowReader {
+	return func(handler types.RowHandler) (types.SavImportData, error) {
+		return sav.StreamSav(fileName, handler)
 	}
+}
 
-	start := time.Now()
+/*
+A CSV or Excel file has no dictionary of its own, its columns being typed from the variable definitions, so
+loading one leaves the definitions and value labels as they are
+*/
+func tabularFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".csv" || ext == ".xlsx"
+}
 
-	records, err := sav.ImportSav(in)
+/*
+The reader for an uploaded survey file, chosen by the extension of its name. CSV and Excel files have their
+columns typed by the variable definitions of their source; anything else is read as a SAV file.
+*/
+func surveyReader(job types.UploadJob) (types.RowReader, error) {
+	if !tabularFile(job.FileName) {
+		return savReader(job.FilePath), nil
+	}
 
+	database, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
-		return types.SavImportData{}, err
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return nil, fmt.Errorf("cannot connect to database: %s", err)
 	}
 
-	if records.RowCount == 0 {
-		log.Warn().
-			Str("method", "readSav").
-			Msg("The SAV file is empty")
-		return types.SavImportData{}, fmt.Errorf("the spss file: %s is empty", in)
+	var definitions []types.VariableDefinitions
+	if job.FileSource == types.GBSource {
+		definitions, err = database.GetAllGBDefinitions()
+	} else {
+		definitions, err = database.GetAllNIDefinitions()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get variable definitions: %s", err)
 	}
 
-	log.Debug().
-		Str("file", in).
-		Int("records", records.RowCount-1).
-		Str("elapsedTime", util.FmtDuration(start)).
-		Msg("Read Sav file")
-
-	return records, nil
+	schema := tabular.NewSchema(definitions)
+	if strings.ToLower(filepath.Ext(job.FileName)) == ".csv" {
+		return tabular.CSVReader(job.FilePath, schema), nil
+	}
+	return tabular.XLSXReader(job.FilePath, schema), nil
 }
 
-func (si SurveyImportHandler) parseGBSurveyFile(tmpfile, datasetName string, week, year, id int) {
-	startTime := time.Now()
+/*
+Record a file that has failed validation in the audit table, with the full validation report, and tell
+anyone watching the upload where the report can be found
+*/
+func (si SurveyImportHandler) auditValidationFailure(audit types.Audit, verr validate.ValidationError, status *types.WSMessage) {
+	audit.Status = types.UploadFailed
+	audit.Message = verr.Message
+	audit.NumObLoaded = 0
+	audit.NumVarLoaded = 0
 
-	si.fileUploads.SetUploadStarted()
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("datasetName", audit.FileName).
+			Msg("Cannot connect to database")
+		status.SetValidationFailed(verr.Message)
+		return
+	}
 
-	spssData, err := loadSav(tmpfile, types.GBSurveyInput{})
+	auditId, err := database.AuditValidationFailure(audit, verr.Failures)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Str("method", "parseGBSurveyFile").
-			Str("file", datasetName).
-			Msg("Cannot import GB SAV file")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot import GB SAV file %s", err))
+			Str("datasetName", audit.FileName).
+			Msg("Cannot store validation report")
+		status.SetValidationFailed(verr.Message)
 		return
 	}
 
-	si.Audit.ReferenceDate = time.Now()
-	si.Audit.NumObFile = spssData.RowCount
-	si.Audit.NumObLoaded = spssData.RowCount
-	si.Audit.NumVarFile = spssData.HeaderCount
-	si.Audit.NumVarLoaded = spssData.HeaderCount
-	si.Audit.FileName = datasetName
-	si.Audit.Id = id
-	si.Audit.Year = year
-	si.Audit.Week = week
-	si.Audit.FileSource = types.GBSource
+	log.Debug().
+		Str("datasetName", audit.FileName).
+		Int("auditId", auditId).
+		Int("failures", len(verr.Failures)).
+		Msg("Validation report stored")
+
+	status.SetValidationFailed(fmt.Sprintf("%s - the validation report is at /audits/%d/validation",
+		verr.Message, auditId))
+}
+
+func (si SurveyImportHandler) processGBSurveyJob(job types.UploadJob, status *types.WSMessage) error {
+	startTime := time.Now()
+	datasetName := job.FileName
+
+	audit := types.Audit{
+		ReferenceDate: time.Now(),
+		FileName:      datasetName,
+		Id:            job.BatchId,
+		Year:          job.Year,
+		Week:          job.Week,
+		FileSource:    types.GBSource,
+		Username:      job.SubmittedBy,
+	}
+
+	reader, err := surveyReader(job)
+	if err != nil {
+		return err
+	}
 
-	pipeline := filter.NewGBPipeLine(&spssData, &si.Audit)
+	pipeline := filter.NewGBPipeLine(reader, &audit)
 
 	if err := pipeline.RunPipeline(); err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("preProcessing failed")
-		si.fileUploads.SetUploadError(fmt.Sprintf("pre-processing failed %s", err))
-		return
+		if verr, ok := err.(validate.ValidationError); ok {
+			si.auditValidationFailure(audit, verr, status)
+		}
+		return fmt.Errorf("pre-processing failed %s", err)
 	}
 
 	log.Debug().
 		Str("datasetName", datasetName).
-		Int("numObservationsFile", si.Audit.NumObFile).
-		Int("numObservationsLoaded", si.Audit.NumObLoaded).
-		Int("numVarFile", si.Audit.NumVarFile).
-		Int("numVarLoaded", si.Audit.NumVarLoaded).
+		Int("numObservationsFile", audit.NumObFile).
+		Int("numObservationsLoaded", audit.NumObLoaded).
+		Int("numVarFile", audit.NumVarFile).
+		Int("numVarLoaded", audit.NumVarLoaded).
 		Str("status", "Successful").
 		Msg("preProcessing complete")
 
+	status.SetRowCounts(audit.NumObFile, audit.NumObLoaded)
+
 	database, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot connect to database")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot connect to database %s", err))
-		return
+		return fmt.Errorf("cannot connect to database %s", err)
 	}
 
 	surveyVo := types.SurveyVO{
-		Audit:  &si.Audit,
-		Status: si.fileUploads,
-		Data:   spssData,
+		Audit:    &audit,
+		Status:   status,
+		Header:   pipeline.Header(),
+		RowCount: audit.NumObLoaded,
+		Rows:     pipeline.Rows,
 	}
 
 	if err := database.PersistSurvey(surveyVo); err != nil {
@@ -114,26 +171,25 @@ func (si SurveyImportHandler) parseGBSurveyFile(tmpfile, datasetName string, wee
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot persist GB survey data")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist GB survey data: %s", err))
-		return
+		return fmt.Errorf("cannot persist GB survey data: %s", err)
 	}
 
-	if err := database.PersistSavValueLabels(spssData.Labels, types.GBSource); err != nil {
-		log.Error().
-			Err(err).
-			Str("datasetName", datasetName).
-			Msg("Cannot persist sav value labels (GB)")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist variable definitions (GB): %s", err))
-		return
-	}
+	if !tabularFile(job.FileName) {
+		if err := database.PersistSavValueLabels(pipeline.Labels(), types.GBSource, job.SubmittedBy); err != nil {
+			log.Error().
+				Err(err).
+				Str("datasetName", datasetName).
+				Msg("Cannot persist sav value labels (GB)")
+			return fmt.Errorf("cannot persist variable definitions (GB): %s", err)
+		}
 
-	if err := database.PersistVariableDefinitions(spssData.Header, types.GBSource); err != nil {
-		log.Error().
-			Err(err).
-			Str("datasetName", datasetName).
-			Msg("Cannot persist variable definitions (GB)")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist variable definitions (GB): %s", err))
-		return
+		if err := database.PersistVariableDefinitions(pipeline.Header(), types.GBSource, job.SubmittedBy); err != nil {
+			log.Error().
+				Err(err).
+				Str("datasetName", datasetName).
+				Msg("Cannot persist variable definitions (GB)")
+			return fmt.Errorf("cannot persist variable definitions (GB): %s", err)
+		}
 	}
 
 	log.Debug().
@@ -141,99 +197,88 @@ func (si SurveyImportHandler) parseGBSurveyFile(tmpfile, datasetName string, wee
 		Str("elapsedTime", util.FmtDuration(startTime)).
 		Msg("Imported and persisted GB survey data")
 
-	return
+	return nil
 }
 
-func (si SurveyImportHandler) parseNISurveyFile(tmpfile, datasetName string, month, year, id int) {
+func (si SurveyImportHandler) processNISurveyJob(job types.UploadJob, status *types.WSMessage) error {
 	startTime := time.Now()
-
-	si.fileUploads.SetUploadStarted()
-
-	spssData, err := loadSav(tmpfile, types.NISurveyInput{})
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("method", "parseNISurveyFile").
-			Str("file", datasetName).
-			Msg("Cannot import NI SAV file")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot import NI SAV file %s", err))
-		return
+	datasetName := job.FileName
+
+	weekNo := niStartWeek(job.Month)
+
+	audit := types.Audit{
+		ReferenceDate: time.Now(),
+		FileName:      datasetName,
+		Id:            job.BatchId,
+		Year:          job.Year,
+		Month:         job.Month,
+		Week:          weekNo,
+		FileSource:    types.NISource,
+		Username:      job.SubmittedBy,
 	}
 
-	// calculate starting week for the month
-	weekNo := 1
-	for i := 1; i < si.Audit.Month; i++ {
-		if i == 2 || i == 5 || i == 8 || i == 11 {
-			weekNo = weekNo + 5
-		} else {
-			weekNo = weekNo + 4
-		}
+	reader, err := surveyReader(job)
+	if err != nil {
+		return err
 	}
 
-	si.Audit.ReferenceDate = time.Now()
-	si.Audit.NumObFile = spssData.RowCount
-	si.Audit.NumObLoaded = spssData.RowCount
-	si.Audit.NumVarFile = spssData.HeaderCount
-	si.Audit.NumVarLoaded = spssData.HeaderCount
-	si.Audit.FileName = datasetName
-	si.Audit.Id = id
-	si.Audit.Year = year
-	si.Audit.Month = month
-	si.Audit.Week = weekNo
-	si.Audit.FileSource = types.NISource
-
-	pipeline := filter.NewNIPipeLine(&spssData, &si.Audit)
+	pipeline := filter.NewNIPipeLine(reader, &audit)
 
 	if err := pipeline.RunPipeline(); err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("preProcessing failed")
-		si.fileUploads.SetUploadError(fmt.Sprintf("pre-processing failed: %s", err))
-		return
+		if verr, ok := err.(validate.ValidationError); ok {
+			si.auditValidationFailure(audit, verr, status)
+		}
+		return fmt.Errorf("pre-processing failed: %s", err)
 	}
 
 	log.Debug().
 		Str("datasetName", datasetName).
-		Int("numObservationsFile", si.Audit.NumObFile).
-		Int("numObservationsLoaded", si.Audit.NumObLoaded).
-		Int("numVarFile", si.Audit.NumVarFile).
-		Int("numVarLoaded", si.Audit.NumVarLoaded).
+		Int("numObservationsFile", audit.NumObFile).
+		Int("numObservationsLoaded", audit.NumObLoaded).
+		Int("numVarFile", audit.NumVarFile).
+		Int("numVarLoaded", audit.NumVarLoaded).
 		Str("status", "Successful").
 		Msg("preProcessing complete")
 
+	status.SetRowCounts(audit.NumObFile, audit.NumObLoaded)
+
 	database, err := db.GetDefaultPersistenceImpl()
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot connect to database")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot connect to database: %s", err))
-		return
+		return fmt.Errorf("cannot connect to database: %s", err)
 	}
 
-	if err := database.PersistVariableDefinitions(spssData.Header, types.NISource); err != nil {
-		log.Error().
-			Err(err).
-			Str("datasetName", datasetName).
-			Msg("Cannot persist variable definitions (NI)")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist variable definitions (NI): %s", err))
-		return
-	}
+	if !tabularFile(job.FileName) {
+		if err := database.PersistVariableDefinitions(pipeline.Header(), types.NISource, job.SubmittedBy); err != nil {
+			log.Error().
+				Err(err).
+				Str("datasetName", datasetName).
+				Msg("Cannot persist variable definitions (NI)")
+			return fmt.Errorf("cannot persist variable definitions (NI): %s", err)
+		}
 
-	if err := database.PersistSavValueLabels(spssData.Labels, types.NISource); err != nil {
-		log.Error().
-			Err(err).
-			Str("datasetName", datasetName).
-			Msg("Cannot persist sav value labels (NI)")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist sav value labels (NI): %s", err))
-		return
+		if err := database.PersistSavValueLabels(pipeline.Labels(), types.NISource, job.SubmittedBy); err != nil {
+			log.Error().
+				Err(err).
+				Str("datasetName", datasetName).
+				Msg("Cannot persist sav value labels (NI)")
+			return fmt.Errorf("cannot persist sav value labels (NI): %s", err)
+		}
 	}
 
 	surveyVo := types.SurveyVO{
-		Audit:  &si.Audit,
-		Status: si.fileUploads,
-		Data:   spssData,
+		Audit:    &audit,
+		Status:   status,
+		Header:   pipeline.Header(),
+		RowCount: audit.NumObLoaded,
+		Rows:     pipeline.Rows,
 	}
 
 	if err := database.PersistSurvey(surveyVo); err != nil {
@@ -241,8 +286,7 @@ func (si SurveyImportHandler) parseNISurveyFile(tmpfile, datasetName string, mon
 			Err(err).
 			Str("datasetName", datasetName).
 			Msg("Cannot persist NI survey data")
-		si.fileUploads.SetUploadError(fmt.Sprintf("cannot persist NI survey data: %s", err))
-		return
+		return fmt.Errorf("cannot persist NI survey data: %s", err)
 	}
 
 	log.Debug().
@@ -250,5 +294,156 @@ func (si SurveyImportHandler) parseNISurveyFile(tmpfile, datasetName string, mon
 		Str("elapsedTime", util.FmtDuration(startTime)).
 		Msg("Imported and persisted NI survey data")
 
-	return
+	return nil
+}
+
+// calculate starting week for the month
+func niStartWeek(month int) int {
+	weekNo := 1
+	for i := 1; i < month; i++ {
+		if i == 2 || i == 5 || i == 8 || i == 11 {
+			weekNo = weekNo + 5
+		} else {
+			weekNo = weekNo + 4
+		}
+	}
+	return weekNo
+}
+
+// a dry run is queued like an upload, its summary being kept as the result of the job
+func (si SurveyImportHandler) processDryRunJob(job types.UploadJob, _ *types.WSMessage) error {
+	summary, err := si.dryRunSurvey(job)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	return database.SetUploadJobResult(job.Id, summary)
+}
+
+/*
+Run a survey file through validation and the pre-processing pipeline without loading it and describe what
+loading it would do. A file that fails validation is not an error; its validation report is returned in the
+summary instead.
+*/
+func (si SurveyImportHandler) dryRunSurvey(job types.UploadJob) (types.DryRunSummary, error) {
+	startTime := time.Now()
+
+	summary := types.DryRunSummary{
+		FileName:   job.FileName,
+		FileSource: job.FileSource,
+		Week:       job.Week,
+		Month:      job.Month,
+		Year:       job.Year,
+	}
+
+	audit := types.Audit{
+		FileName:   job.FileName,
+		FileSource: job.FileSource,
+		Id:         job.BatchId,
+		Year:       job.Year,
+		Week:       job.Week,
+		Month:      job.Month,
+		Username:   job.SubmittedBy,
+	}
+
+	reader, err := surveyReader(job)
+	if err != nil {
+		return summary, err
+	}
+
+	var pipeline *filter.Pipeline
+	if job.FileSource == types.GBSource {
+		pipeline = filter.NewGBPipeLine(reader, &audit)
+	} else {
+		audit.Week = niStartWeek(job.Month)
+		pipeline = filter.NewNIPipeLine(reader, &audit)
+	}
+
+	// only the first pass over the file is needed as nothing is loaded
+	err = pipeline.RunPipeline()
+
+	summary.RowsIn = audit.NumObFile
+	summary.VariablesIn = audit.NumVarFile
+
+	if err != nil {
+		verr, ok := err.(validate.ValidationError)
+		if !ok {
+			return summary, fmt.Errorf("pre-processing failed: %s", err)
+		}
+		summary.ValidationMessage = verr.Message
+		summary.ValidationReport = verr.Failures
+		return summary, nil
+	}
+
+	summary.Valid = true
+	summary.ValidationMessage = "Successful"
+	summary.RowsOut = audit.NumObLoaded
+	summary.VariablesOut = audit.NumVarLoaded
+	summary.SkipCounts = audit.SkipCounts
+	summary.Renamed = pipeline.Renamed()
+	summary.Dropped = pipeline.Dropped()
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return summary, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	var definitions []types.VariableDefinitions
+	if job.FileSource == types.GBSource {
+		definitions, err = database.GetAllGBDefinitions()
+	} else {
+		definitions, err = database.GetAllNIDefinitions()
+	}
+	if err != nil {
+		return summary, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+
+	summary.NewVariables, summary.MissingVariables = compareVariables(pipeline.Header(), definitions)
+
+	log.Debug().
+		Str("datasetName", job.FileName).
+		Int("rowsIn", summary.RowsIn).
+		Int("rowsOut", summary.RowsOut).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Dry run complete")
+
+	return summary, nil
+}
+
+// the variables in the file but not defined, and those defined but not in the file
+func compareVariables(header []types.Header, definitions []types.VariableDefinitions) ([]string, []string) {
+	inFile := make(map[string]bool, len(header))
+	for _, h := range header {
+		inFile[strings.ToUpper(h.VariableName)] = true
+	}
+
+	defined := make(map[string]bool, len(definitions))
+	for _, d := range definitions {
+		defined[strings.ToUpper(d.Variable)] = true
+	}
+
+	newVariables := make([]string, 0)
+	for _, h := range header {
+		if !defined[strings.ToUpper(h.VariableName)] {
+			newVariables = append(newVariables, h.VariableName)
+		}
+	}
+
+	missingVariables := make([]string, 0)
+	for v := range defined {
+		if !inFile[v] {
+			missingVariables = append(missingVariables, v)
+		}
+	}
+	sort.Strings(missingVariables)
+
+	return newVariables, missingVariables
 }
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type SurveyTabulationHandler struct{}
+
+func NewSurveyTabulationHandler() *SurveyTabulationHandler {
+	return &SurveyTabulationHandler{}
+}
+
+/*
+Cross-tabulate the rows of a dataset for a year (2019), month (2019/1) or quarter (2019/Q1) by the variable set by
+rows and, optionally, by columns. Counts are weighted by the weight variable, if set, and value sets the variable whose
+weighted sum and mean are given for each cell.
+*/
+func (st SurveyTabulationHandler) HandleTabulationRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	period, err := parseSurveyPeriod(vars)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	res, err := st.tabulateSurvey(period, r.URL.Query())
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if res.Count == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
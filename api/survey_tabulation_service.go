@@ -0,0 +1,177 @@
+package api
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"services/db"
+	"services/types"
+	"services/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a variable a tabulation is by or over, which must be defined and, for a weight or value, numeric
+func parseTabulationVariable(query url.Values, name string, required, numeric bool, header map[string]types.Header) (string, error) {
+	variable := strings.ToUpper(strings.TrimSpace(query.Get(name)))
+	if variable == "" {
+		if required {
+			return "", fmt.Errorf("%s must be set to a variable", name)
+		}
+		return "", nil
+	}
+
+	h, ok := header[variable]
+	if !ok {
+		return "", fmt.Errorf("invalid %s: %s is not a variable", name, variable)
+	}
+	if numeric && h.VariableType == types.TypeString {
+		return "", fmt.Errorf("invalid %s: %s is not numeric", name, variable)
+	}
+
+	return variable, nil
+}
+
+// build a tabulation from the request
+func parseSurveyTabulation(period types.ExportPeriod, query url.Values, definitions ...[]types.VariableDefinitions) (types.SurveyTabulation, error) {
+	t := types.SurveyTabulation{Period: period}
+	header := surveyHeader(definitions...)
+
+	var err error
+	if t.Dataset, err = parseDataset(query.Get("dataset")); err != nil {
+		return t, err
+	}
+	if t.Rows, err = parseTabulationVariable(query, "rows", true, false, header); err != nil {
+		return t, err
+	}
+	if t.Columns, err = parseTabulationVariable(query, "columns", false, false, header); err != nil {
+		return t, err
+	}
+	if t.Weight, err = parseTabulationVariable(query, "weight", false, true, header); err != nil {
+		return t, err
+	}
+	if t.Value, err = parseTabulationVariable(query, "value", false, true, header); err != nil {
+		return t, err
+	}
+
+	if t.Conditions, err = parseSurveyConditions(query["filter"], header); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// the name a variable is defined with, which value_labels_v is keyed on
+func definedName(variable string, definitions ...[]types.VariableDefinitions) string {
+	for _, defs := range definitions {
+		for _, d := range defs {
+			if strings.EqualFold(d.Variable, variable) {
+				return d.Variable
+			}
+		}
+	}
+	return variable
+}
+
+/*
+The value labels of a variable by value. The view joins label sets by name alone, so GB labels are used before
+NI ones when both sources have a set of that name.
+*/
+func tabulationLabels(database db.Persistence, variable string) (map[string]string, error) {
+	values, err := database.GetLabelsForValue(variable)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get value labels for %s: %s", variable, err)
+	}
+
+	labels := make(map[string]string, len(values))
+	for _, v := range values {
+		key := strconv.Itoa(v.LabelValue)
+		if _, ok := labels[key]; ok && v.Source != string(types.GBSource) {
+			continue
+		}
+		labels[key] = string(v.LabelDescription)
+	}
+
+	return labels, nil
+}
+
+/*
+Tabulate the survey rows of a period as the request asks, with the value labels of the row and column values
+*/
+func (st SurveyTabulationHandler) tabulateSurvey(period types.ExportPeriod, query url.Values) (types.Tabulation, error) {
+	startTime := time.Now()
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot connect to database")
+		return types.Tabulation{}, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	gb, err := database.GetAllGBDefinitions()
+	if err != nil {
+		return types.Tabulation{}, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+	ni, err := database.GetAllNIDefinitions()
+	if err != nil {
+		return types.Tabulation{}, fmt.Errorf("cannot get variable definitions: %s", err)
+	}
+
+	t, err := parseSurveyTabulation(period, query, gb, ni)
+	if err != nil {
+		return types.Tabulation{}, err
+	}
+
+	cells, err := database.TabulateSurvey(t)
+	if err != nil {
+		return types.Tabulation{}, fmt.Errorf("cannot tabulate survey data: %s", err)
+	}
+
+	rowLabels, err := tabulationLabels(database, definedName(t.Rows, gb, ni))
+	if err != nil {
+		return types.Tabulation{}, err
+	}
+
+	columnLabels := map[string]string{}
+	if t.Columns != "" {
+		if columnLabels, err = tabulationLabels(database, definedName(t.Columns, gb, ni)); err != nil {
+			return types.Tabulation{}, err
+		}
+	}
+
+	res := types.Tabulation{
+		Dataset: t.Dataset,
+		Period:  t.Period.String(),
+		Rows:    t.Rows,
+		Columns: t.Columns,
+		Weight:  t.Weight,
+		Value:   t.Value,
+		Cells:   make([]types.TabulationCell, len(cells)),
+	}
+
+	for i, c := range cells {
+		if c.Row != nil {
+			c.RowLabel = rowLabels[csvValue(c.Row)]
+		}
+		if c.Column != nil {
+			c.ColumnLabel = columnLabels[csvValue(c.Column)]
+		}
+		res.Count += c.Count
+		res.WeightedCount += c.WeightedCount
+		res.Cells[i] = c
+	}
+
+	log.Debug().
+		Str("dataset", string(res.Dataset)).
+		Str("period", res.Period).
+		Str("rows", t.Rows).
+		Str("columns", t.Columns).
+		Int("cells", len(cells)).
+		Int("count", res.Count).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Tabulated survey data")
+
+	return res, nil
+}
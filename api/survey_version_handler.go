@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"services/types"
+	"strconv"
+)
+
+type SurveyVersionHandler struct{}
+
+func NewSurveyVersionHandler() *SurveyVersionHandler {
+	return &SurveyVersionHandler{}
+}
+
+func (sv SurveyVersionHandler) HandleGBVersionsRequest(w http.ResponseWriter, r *http.Request) {
+	sv.handleVersionsRequest(w, r, types.GBSource, "week")
+}
+
+func (sv SurveyVersionHandler) HandleNIVersionsRequest(w http.ResponseWriter, r *http.Request) {
+	sv.handleVersionsRequest(w, r, types.NISource, "month")
+}
+
+func (sv SurveyVersionHandler) handleVersionsRequest(w http.ResponseWriter, r *http.Request, source types.FileSource, periodName string) {
+	vars := mux.Vars(r)
+	year := vars["year"]
+	period := vars[periodName]
+
+	yearNo, err := strconv.Atoi(year)
+	if err != nil {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid year: %s, expected an integer", year)}.sendResponse(w, r)
+		return
+	}
+
+	periodNo, err := strconv.Atoi(period)
+	if err != nil {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid %s: %s, expected an integer", periodName, period)}.sendResponse(w, r)
+		return
+	}
+
+	res, err := sv.GetVersions(source, yearNo, periodNo)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if len(res) == 0 {
+		NoRecordsFoundStatus{}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (sv SurveyVersionHandler) HandleDiffRequest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	from, err := strconv.Atoi(vars["versionId"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: "invalid versionId, expected an integer"}.sendResponse(w, r)
+		return
+	}
+
+	to, err := strconv.Atoi(vars["otherId"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: "invalid otherId, expected an integer"}.sendResponse(w, r)
+		return
+	}
+
+	res, err := sv.DiffVersions(from, to)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (sv SurveyVersionHandler) HandleRollbackRequest(w http.ResponseWriter, r *http.Request) {
+	versionId, err := strconv.Atoi(mux.Vars(r)["versionId"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: "invalid versionId, expected an integer"}.sendResponse(w, r)
+		return
+	}
+
+	res, err := sv.Rollback(versionId, actingUser(r))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/rs/zerolog/log"
+	"services/db"
+	"services/types"
+)
+
+func (sv SurveyVersionHandler) GetVersions(source types.FileSource, year, period int) ([]types.SurveyVersion, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return nil, err
+	}
+
+	return dbase.GetSurveyVersions(source, year, period)
+}
+
+func (sv SurveyVersionHandler) DiffVersions(from, to int) (types.SurveyVersionDiff, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.SurveyVersionDiff{}, err
+	}
+
+	return dbase.DiffSurveyVersions(from, to)
+}
+
+func (sv SurveyVersionHandler) Rollback(versionId int, user string) (types.SurveyVersion, error) {
+	dbase, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().Err(err)
+		return types.SurveyVersion{}, err
+	}
+
+	version, err := dbase.RollbackSurveyVersion(versionId, user)
+	if err != nil {
+		return version, err
+	}
+
+	log.Info().
+		Int("versionId", versionId).
+		Str("fileSource", string(version.FileSource)).
+		Int("year", version.Year).
+		Int("month", version.Month).
+		Int("week", version.Week).
+		Int("version", version.Version).
+		Str("user", user).
+		Msg("Survey rolled back")
+
+	return version, nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/api/ws"
+	"strconv"
+)
+
+// UploadStatusHandler is a REST alternative to the /ws endpoint for clients that cannot hold a web socket open
+type UploadStatusHandler struct{}
+
+func NewUploadStatusHandler() *UploadStatusHandler {
+	return &UploadStatusHandler{}
+}
+
+// The status of an upload job, so that two uploads of the same file are reported apart
+func (us UploadStatusHandler) HandleUploadStatusRequest(w http.ResponseWriter, r *http.Request) {
+
+	jobId := mux.Vars(r)["jobId"]
+
+	id, err := strconv.Atoi(jobId)
+	if err != nil {
+		ErrorResponse{
+			Status:       Error,
+			ErrorMessage: fmt.Sprintf("invalid job id: %s, expected an integer", jobId)}.sendResponse(w, r)
+		return
+	}
+
+	uploads := ws.NewFileUploads()
+	m, err := uploads.Lookup(id)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, m)
+}
+
+// The status of the latest upload of a file
+func (us UploadStatusHandler) HandleFileUploadStatusRequest(w http.ResponseWriter, r *http.Request) {
+
+	fileName := mux.Vars(r)["fileName"]
+
+	if fileName == "" {
+		log.Warn().Msg("fileName not defined")
+		ErrorResponse{Status: Error, ErrorMessage: "fileName not defined"}.sendResponse(w, r)
+		return
+	}
+
+	uploads := ws.NewFileUploads()
+	m, err := uploads.LookupLatest(fileName)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, m)
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"services/types"
+)
+
+type UsersHandler struct {
+	login *LoginHandler
+}
+
+func NewUsersHandler(login *LoginHandler) *UsersHandler {
+	return &UsersHandler{login: login}
+}
+
+// Every user with their roles and when they last logged in
+func (h UsersHandler) HandleAllRequest(w http.ResponseWriter, r *http.Request) {
+	res, err := h.getUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("Get users failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (h UsersHandler) HandleUserRequest(w http.ResponseWriter, r *http.Request) {
+	res, err := h.getUser(mux.Vars(r)["user"])
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+/*
+Create a user from a body of the form {"username": "...", "password": "...", "roles": ["viewer"]}
+*/
+func (h UsersHandler) HandleCreateRequest(w http.ResponseWriter, r *http.Request) {
+	var u types.NewUser
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid user: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	res, err := h.createUser(u)
+	if err != nil {
+		log.Error().Err(err).Str("user", u.Username).Msg("Create user failed")
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	log.Info().
+		Str("user", res.Username).
+		Msg("User created")
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+/*
+Replace the roles of a user from a body of the form {"roles": ["viewer", "uploader"]}. Admins cannot remove their
+own admin role, so that there is always an admin left.
+*/
+func (h UsersHandler) HandleRolesRequest(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["user"]
+
+	var body struct {
+		Roles []types.Role `json:"roles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid roles: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	roles, err := parseRoles(body.Roles)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	if session, ok := requestSession(r); ok && session.Username == username && !types.HasRole(roles, types.Admin) {
+		ErrorResponse{Status: Error, ErrorMessage: "you cannot remove your own admin role"}.sendResponse(w, r)
+		return
+	}
+
+	res, err := h.setUserRoles(username, roles)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+// Reset the password of a user from a body of the form {"password": "..."}, logging them out
+func (h UsersHandler) HandleResetPasswordRequest(w http.ResponseWriter, r *http.Request) {
+	var change types.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid password: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	if err := h.resetPassword(mux.Vars(r)["user"], change.Password); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	OkayResponse{OK}.sendResponse(w, r)
+}
+
+/*
+Change the password of the user making the request from a body of the form
+{"currentPassword": "...", "password": "..."}
+*/
+func (h UsersHandler) HandleChangePasswordRequest(w http.ResponseWriter, r *http.Request) {
+	session, ok := requestSession(r)
+	if !ok {
+		UnauthorizedResponse{ErrorMessage: "not logged in"}.sendResponse(w, r)
+		return
+	}
+
+	var change types.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: fmt.Sprintf("invalid password: %s", err)}.sendResponse(w, r)
+		return
+	}
+
+	if err := h.changePassword(session, change, clientAddress(r)); err != nil {
+		if throttled, ok := err.(loginThrottled); ok {
+			TooManyRequestsResponse{ErrorMessage: err.Error(), RetryAfter: throttled.retryAfter}.sendResponse(w, r)
+			return
+		}
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	OkayResponse{OK}.sendResponse(w, r)
+}
+
+// Disable a user, ending their sessions. Admins cannot disable themselves.
+func (h UsersHandler) HandleDisableRequest(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["user"]
+
+	if session, ok := requestSession(r); ok && session.Username == username {
+		ErrorResponse{Status: Error, ErrorMessage: "you cannot disable your own account"}.sendResponse(w, r)
+		return
+	}
+
+	res, err := h.setUserDisabled(username, true)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	log.Info().
+		Str("user", username).
+		Msg("User disabled")
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+func (h UsersHandler) HandleEnableRequest(w http.ResponseWriter, r *http.Request) {
+	res, err := h.setUserDisabled(mux.Vars(r)["user"], false)
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
+
+// Unlock an account locked by failed logins
+func (h UsersHandler) HandleUnlockRequest(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["user"]
+
+	session, _ := requestSession(r)
+	res, err := h.unlockUser(username, session.Username, clientAddress(r))
+	if err != nil {
+		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
+		return
+	}
+
+	log.Info().
+		Str("user", username).
+		Str("admin", session.Username).
+		Msg("User unlocked")
+
+	SendDataResponse{}.sendResponse(w, r, res)
+}
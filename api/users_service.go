@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"services/config"
+	"services/types"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// bcrypt only uses the first 72 bytes of a password
+const maxPasswordLength = 72
+
+func parseUsername(username string) (string, error) {
+	if !usernamePattern.MatchString(username) {
+		return "", fmt.Errorf("invalid username: %s, expected up to 64 letters, digits, '.', '_' or '-'", username)
+	}
+	return username, nil
+}
+
+// the roles of a request, each given once
+func parseRoles(roles []types.Role) ([]types.Role, error) {
+	seen := make(map[types.Role]bool)
+	var res []types.Role
+
+	for _, r := range roles {
+		role := types.Role(strings.ToLower(string(r)))
+		valid := false
+		for _, v := range types.Roles {
+			if v == role {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid role: %s", r)
+		}
+		if !seen[role] {
+			seen[role] = true
+			res = append(res, role)
+		}
+	}
+
+	return res, nil
+}
+
+/*
+A password must be at least the configured length, have upper and lower case letters and a digit, and not
+contain the username
+*/
+func checkPassword(username, password string) error {
+	if len(password) < config.Config.Auth.MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", config.Config.Auth.MinPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+
+	var upper, lower, digit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return fmt.Errorf("password must have upper and lower case letters and a digit")
+	}
+
+	if strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return fmt.Errorf("password must not contain the username")
+	}
+
+	return nil
+}
+
+func hashPassword(username, password string) (string, error) {
+	if err := checkPassword(username, password); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("cannot hash password: %s", err)
+	}
+
+	return string(hash), nil
+}
+
+func (h UsersHandler) getUsers() ([]types.User, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return database.GetUsers()
+}
+
+func (h UsersHandler) getUser(username string) (types.User, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return types.User{}, err
+	}
+	return database.GetUser(username)
+}
+
+func (h UsersHandler) createUser(u types.NewUser) (types.User, error) {
+	username, err := parseUsername(u.Username)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	roles, err := parseRoles(u.Roles)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	hash, err := hashPassword(username, u.Password)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	database, err := connectDatabase()
+	if err != nil {
+		return types.User{}, err
+	}
+
+	if err := database.CreateUser(username, hash, roles); err != nil {
+		return types.User{}, err
+	}
+
+	return database.GetUser(username)
+}
+
+func (h UsersHandler) setUserRoles(username string, roles []types.Role) (types.User, error) {
+	roles, err := parseRoles(roles)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	database, err := connectDatabase()
+	if err != nil {
+		return types.User{}, err
+	}
+
+	if err := database.SetUserRoles(username, roles); err != nil {
+		return types.User{}, err
+	}
+
+	return database.GetUser(username)
+}
+
+/*
+An admin resetting a password ends the user's sessions, so whoever knew the old password is logged out
+*/
+func (h UsersHandler) resetPassword(username, password string) error {
+	hash, err := hashPassword(username, password)
+	if err != nil {
+		return err
+	}
+
+	database, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+
+	if err := database.SetUserPassword(username, hash); err != nil {
+		return err
+	}
+
+	return database.DeleteUserSessions(username)
+}
+
+/*
+Users changing their own password must give their current one. A wrong one counts as a failed login, so that a
+session cannot be used to guess the password, and a change ends the user's other sessions.
+*/
+func (h UsersHandler) changePassword(session types.Session, change types.PasswordChange, client string) error {
+	database, err := connectDatabase()
+	if err != nil {
+		return err
+	}
+
+	username := session.Username
+	if err := h.login.throttle(database, username, client, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	creds, err := database.GetUserID(username)
+	if err != nil {
+		return err
+	}
+
+	if !h.login.comparePasswords(creds.Password, change.Current) {
+		_ = h.login.loginFailed(database, username, client, "wrong current password", true)
+		return fmt.Errorf("current password is incorrect")
+	}
+
+	hash, err := hashPassword(username, change.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := database.SetUserPassword(username, hash); err != nil {
+		return err
+	}
+
+	return database.DeleteOtherUserSessions(username, session.TokenHash)
+}
+
+func (h UsersHandler) setUserDisabled(username string, disabled bool) (types.User, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return types.User{}, err
+	}
+
+	if err := database.SetUserDisabled(username, disabled); err != nil {
+		return types.User{}, err
+	}
+
+	return database.GetUser(username)
+}
+
+// an admin unlocking an account clears its failed logins, and is recorded in the authentication audit
+func (h UsersHandler) unlockUser(username, admin, client string) (types.User, error) {
+	database, err := connectDatabase()
+	if err != nil {
+		return types.User{}, err
+	}
+
+	if err := database.UnlockUser(username); err != nil {
+		return types.User{}, err
+	}
+
+	auditAuthEvent(database, username, client, types.AccountUnlocked, fmt.Sprintf("unlocked by %s", admin))
+
+	return database.GetUser(username)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"services/types"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	assert.NoError(t, checkPassword("analyst", "Labour-Force-2019"))
+
+	assert.Error(t, checkPassword("analyst", "Short1a"), "too short")
+	assert.Error(t, checkPassword("analyst", "labour-force-2019"), "no upper case")
+	assert.Error(t, checkPassword("analyst", "LABOUR-FORCE-2019"), "no lower case")
+	assert.Error(t, checkPassword("analyst", "Labour-Force-Survey"), "no digit")
+	assert.Error(t, checkPassword("analyst", "Analyst-2019-LFS"), "contains the username")
+}
+
+func TestParseRoles(t *testing.T) {
+	roles, err := parseRoles([]types.Role{"Uploader", "viewer", "uploader"})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Role{types.Uploader, types.Viewer}, roles)
+
+	_, err = parseRoles([]types.Role{"superuser"})
+	assert.Error(t, err)
+}
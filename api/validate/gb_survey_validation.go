@@ -3,7 +3,6 @@ package validate
 import (
 	"fmt"
 	"services/types"
-	"time"
 )
 
 type GBSurveyType int
@@ -13,95 +12,72 @@ type GBSurveyValidation struct {
 }
 
 func NewGBSurveyValidation(data *types.SavImportData) GBSurveyValidation {
-	return GBSurveyValidation{Validator: Validator{data}}
+	return GBSurveyValidation{Validator: Validator{data: data}}
 }
 
+/*
+Validate a GB file against the rules in the [[validation.gb]] configuration
+*/
 func (sf GBSurveyValidation) Validate(period, year int) (ValidationResponse, error) {
 
-	var columnsToCheck = []string{
-		"REFDTE", "PCODE", "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO",
-	}
-	v, e := sf.validateMissingValues(columnsToCheck)
-
-	if e != nil {
-		return v, e
+	failures, err := sf.applyRules(gbRules, period, year)
+	if err != nil {
+		return ValidationResponse{}, err
 	}
 
-	v, e = sf.validateREFDTE(period, year)
-	return v, e
-
+	return sf.response(failures), nil
 }
 
 /*
-Validate the REFDTE field in the Survey file
+Validate the REFDTE field in the Survey file. Every row must have the same REFDTE which must be a Sunday
+in the week and year being loaded.
+*/
+func (v Validator) validateREFDTE(column string, period, year int) []types.ValidationFailure {
+	var failures []types.ValidationFailure
 
-SPSS stores timestamps as the numbers of seconds between the year 1582 (start of the Gregorian calendar)
-and a given time on a given date. To get the actual date from this we need to:
+	times, rows, ok := v.getRowsAsTime(column)
+	if !ok {
+		return v.validateRequired([]string{column})
+	}
 
-1. Get the difference between the Gregorian time and the Unix epoch in seconds (141428)
-2. Multiply this value by the number of seconds in a day (86400)
-3. Subtract this value from the SPSS timestamp to get the Unix time, and
-4. Get the date from the Unix time using standard Go functions.
+	if len(v.data.Rows) == 0 {
+		return []types.ValidationFailure{
+			v.failure(types.RuleNoRows, "", -1, "", "there are no rows to validate"),
+		}
+	}
 
-*/
-func (v Validator) validateREFDTE(period, year int) (ValidationResponse, error) {
-	rows, err := v.GetRowsAsDouble("REFDTE")
-	if err != nil {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     err.Error(),
-		}, err
+	if len(times) == 0 {
+		return nil
 	}
 
-	var val float64
-	for _, j := range rows {
-		if val == 0.0 {
-			val = j
-		}
-		if val != j {
-			return ValidationResponse{
-				ValidationResult: ValidationFailed,
-				ErrorMessage:     "rows contain different values for REFDTE",
-			}, fmt.Errorf("rows contain different values for REFDTE")
+	refDate := times[0]
+	for i, tm := range times {
+		if !tm.Equal(refDate) {
+			failures = append(failures, v.failure(types.RuleSameRefDate, column, rows[i], tm.Format("2006-01-02"),
+				fmt.Sprintf("rows contain different values for %s, expected %s", column, refDate.Format("2006-01-02"))))
 		}
 	}
 
-	if len(rows) == 0 {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     fmt.Sprintf("There are no rows to validate"),
-		}, fmt.Errorf(fmt.Sprintf("there are no rows to validate"))
-	}
+	// the remaining checks apply to the file as a whole, so are reported once against the first row's REFDTE
+	value := refDate.Format("2006-01-02")
 
-	// Take the first row rather than checking in a loop
-	i := int64(rows[0]) - (141428 * 86400)
-	tm := time.Unix(i, 0)
-	if tm.Weekday() != 0 {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", tm.Weekday().String()),
-		}, fmt.Errorf(fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", tm.Weekday().String()))
+	if refDate.Weekday() != 0 {
+		failures = append(failures, v.failure(types.RuleRefDateSunday, column, -1, value,
+			fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", refDate.Weekday().String())))
 	}
 
 	// check week number against RFEDTE
-	y, w := tm.ISOWeek()
+	y, w := refDate.ISOWeek()
 
 	if w != period {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     fmt.Sprintf("Week number in RFEDTE is not the required week %d, it is %d", period, w),
-		}, fmt.Errorf(fmt.Sprintf("week number in RFEDTE is not the required week %d, it is %d", period, w))
+		failures = append(failures, v.failure(types.RuleRefDateWeek, column, -1, value,
+			fmt.Sprintf("week number in RFEDTE is not the required week %d, it is %d", period, w)))
 	}
 
 	if y != year {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     fmt.Sprintf("Year number in RFEDTE is not the required year %d, it is %d", year, y),
-		}, fmt.Errorf(fmt.Sprintf("year number in RFEDTE is not the required year %d, it is %d", year, y))
+		failures = append(failures, v.failure(types.RuleRefDateYear, column, -1, value,
+			fmt.Sprintf("year number in RFEDTE is not the required year %d, it is %d", year, y)))
 	}
 
-	return ValidationResponse{
-		ValidationResult: ValidationSuccessful,
-		ErrorMessage:     "",
-	}, nil
+	return failures
 }
@@ -11,59 +11,58 @@ type NISurveyValidation struct {
 }
 
 func NewNISurveyValidation(data *types.SavImportData) NISurveyValidation {
-	return NISurveyValidation{Validator: Validator{data}}
+	return NISurveyValidation{Validator: Validator{data: data}}
 }
 
+/*
+Validate an NI file against the rules in the [[validation.ni]] configuration
+*/
 func (sf NISurveyValidation) Validate(period, year int) (ValidationResponse, error) {
 
-	var columnsToCheck = []string{
-		"REFDTE", "PCODE", "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO",
-	}
-
-	v, e := sf.validateMissingValues(columnsToCheck)
-
-	if e != nil {
-		return v, e
+	failures, err := sf.applyRules(niRules, period, year)
+	if err != nil {
+		return ValidationResponse{}, err
 	}
 
-	v, e = sf.ValidateNIDates(period, year)
-	return v, e
-
+	return sf.response(failures), nil
 }
 
-func (sf NISurveyValidation) ValidateNIDates(month, year int) (ValidationResponse, error) {
+/*
+An NI file holds a month of data so REFDTE must take 4 or 5 different values, each of them a Sunday in the
+month and year being loaded. Each distinct REFDTE that fails is reported against the first row it appears on.
+*/
+func (v Validator) validateNIDates(column string, month, year int) []types.ValidationFailure {
+	var failures []types.ValidationFailure
 
-	// get the list of weeks in the sav file
-	rows, err := sf.GetRowsAsDouble("REFDTE")
-	if err != nil {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     err.Error(),
-		}, err
+	times, rows, ok := v.getRowsAsTime(column)
+	if !ok {
+		return v.validateRequired([]string{column})
 	}
 
-	var weeks = make(map[float64]int, 0)
-	for pos, j := range rows {
-		weeks[j] = pos
+	// get the list of weeks in the sav file, with the first row each is found on
+	var weeks = make(map[time.Time]int, 0)
+	var order []time.Time
+	for i, tm := range times {
+		if _, ok := weeks[tm]; !ok {
+			weeks[tm] = rows[i]
+			order = append(order, tm)
+		}
 	}
 
 	// check how many weeks we have
 	if len(weeks) != 4 && len(weeks) != 5 {
-		return ValidationResponse{
-			ValidationResult: ValidationFailed,
-			ErrorMessage:     fmt.Sprintf("rows must contain either 4 or 5 weeks of data, found: %d", len(weeks)),
-		}, fmt.Errorf("rows must contain either 4 or 5 weeks of data found: %d", len(weeks))
+		failures = append(failures, v.failure(types.RuleNumberOfWeeks, column, -1, fmt.Sprintf("%d", len(weeks)),
+			fmt.Sprintf("rows must contain either 4 or 5 weeks of data, found: %d", len(weeks))))
 	}
 
 	//check each week starts on a sunday
-	for timeStamp, _ := range weeks {
-		i := int64(timeStamp) - (141428 * 86400)
-		tm := time.Unix(i, 0)
+	for _, tm := range order {
+		row := weeks[tm]
+		value := tm.Format("2006-01-02")
+
 		if tm.Weekday() != 0 {
-			return ValidationResponse{
-				ValidationResult: ValidationFailed,
-				ErrorMessage:     fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", tm.Weekday().String()),
-			}, fmt.Errorf(fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", tm.Weekday().String()))
+			failures = append(failures, v.failure(types.RuleRefDateSunday, column, row, value,
+				fmt.Sprintf("RFEDTE is not a Sunday - it is a %s", tm.Weekday().String())))
 		}
 
 		// check week number against month and year
@@ -71,23 +70,15 @@ func (sf NISurveyValidation) ValidateNIDates(month, year int) (ValidationRespons
 		y := tm.Year()
 
 		if m != month {
-			return ValidationResponse{
-				ValidationResult: ValidationFailed,
-				ErrorMessage:     fmt.Sprintf("Week number in RFEDTE is not the required month %d, it is %d", month, m),
-			}, fmt.Errorf(fmt.Sprintf("week number in RFEDTE is not the required month %d, it is %d", month, y))
+			failures = append(failures, v.failure(types.RuleRefDateMonth, column, row, value,
+				fmt.Sprintf("week number in RFEDTE is not the required month %d, it is %d", month, m)))
 		}
 
 		if y != year {
-			return ValidationResponse{
-				ValidationResult: ValidationFailed,
-				ErrorMessage:     fmt.Sprintf("Year number in RFEDTE is not the required year %d, it is %d", year, y),
-			}, fmt.Errorf(fmt.Sprintf("year number in RFEDTE is not the required year %d, it is %d", year, y))
+			failures = append(failures, v.failure(types.RuleRefDateYear, column, row, value,
+				fmt.Sprintf("year number in RFEDTE is not the required year %d, it is %d", year, y)))
 		}
-
 	}
 
-	return ValidationResponse{
-		ValidationResult: ValidationSuccessful,
-		ErrorMessage:     "",
-	}, nil
+	return failures
 }
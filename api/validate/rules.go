@@ -0,0 +1,422 @@
+package validate
+
+import (
+	"fmt"
+	"math"
+	"services/api/expr"
+	"services/config"
+	"services/db"
+	"services/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rule types that can be used in the validation configuration
+const (
+	requiredRule   = "required"
+	notMissingRule = "notMissing"
+	rangeRule      = "range"
+	valuesRule     = "values"
+	conditionRule  = "condition"
+	uniqueRule     = "unique"
+	gbRefDateRule  = "gbRefDate"
+	niRefDateRule  = "niRefDate"
+)
+
+type rule struct {
+	config.ValidationRule
+	when  *expr.Expr
+	check expr.Expr
+}
+
+var gbRules []rule
+var niRules []rule
+
+func init() {
+	var err error
+
+	if gbRules, err = compileRules(config.Config.Validation.GB); err != nil {
+		panic(fmt.Sprintf("GB validation configuration error: %s", err))
+	}
+
+	if niRules, err = compileRules(config.Config.Validation.NI); err != nil {
+		panic(fmt.Sprintf("NI validation configuration error: %s", err))
+	}
+}
+
+func compileRules(rules []config.ValidationRule) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", r.Name, err)
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+func compileRule(r config.ValidationRule) (rule, error) {
+	c := rule{ValidationRule: r}
+
+	for i := range c.Columns {
+		c.Columns[i] = strings.ToUpper(c.Columns[i])
+	}
+
+	switch r.Type {
+	case requiredRule, notMissingRule, valuesRule, uniqueRule:
+		if len(r.Columns) == 0 {
+			return c, fmt.Errorf("columns not set")
+		}
+
+	case rangeRule:
+		if len(r.Columns) == 0 {
+			return c, fmt.Errorf("columns not set")
+		}
+		if r.Min == nil && r.Max == nil {
+			return c, fmt.Errorf("neither min nor max is set")
+		}
+
+	case gbRefDateRule, niRefDateRule:
+		if len(r.Columns) != 1 {
+			return c, fmt.Errorf("a single reference date column must be given")
+		}
+
+	case conditionRule:
+		if r.Check == "" {
+			return c, fmt.Errorf("check not set")
+		}
+		check, err := expr.Parse(r.Check)
+		if err != nil {
+			return c, err
+		}
+		c.check = check
+
+		if r.When != "" {
+			when, err := expr.Parse(r.When)
+			if err != nil {
+				return c, err
+			}
+			c.when = &when
+		}
+
+	default:
+		return c, fmt.Errorf("unknown rule type '%s'", r.Type)
+	}
+
+	return c, nil
+}
+
+// GBColumns returns the columns read when validating a GB file
+func GBColumns() []string {
+	return ruleColumns(gbRules)
+}
+
+// NIColumns returns the columns read when validating an NI file
+func NIColumns() []string {
+	return ruleColumns(niRules)
+}
+
+/*
+The columns used by a set of rules, including those CASENO is made from as it is reported with each failure.
+A file can be validated with just these columns rather than all of them.
+*/
+func ruleColumns(rules []rule) []string {
+	set := make(map[string]bool)
+
+	for _, c := range caseNoColumns {
+		set[c] = true
+	}
+
+	for _, r := range rules {
+		for _, c := range r.Columns {
+			set[c] = true
+		}
+		if r.when != nil {
+			for _, c := range r.when.Columns() {
+				set[c] = true
+			}
+		}
+		if r.Type == conditionRule {
+			for _, c := range r.check.Columns() {
+				set[c] = true
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(set))
+	for c := range set {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+/*
+Run each rule against the file and collect every failure found. An error is only returned if a rule cannot be
+run, not if the file fails it.
+*/
+func (v Validator) applyRules(rules []rule, period, year int) ([]types.ValidationFailure, error) {
+	var failures []types.ValidationFailure
+
+	// a column missing from the file is only reported once, however many rules use it
+	notFound := make(map[string]bool)
+
+	v.caseNoAt = v.caseNoIndexes()
+
+	for _, r := range rules {
+		var found []types.ValidationFailure
+
+		switch r.Type {
+		case requiredRule:
+			found = v.validateRequired(r.Columns)
+		case notMissingRule:
+			found = v.validateMissingValues(r.Columns)
+		case rangeRule:
+			found = v.validateRange(r)
+		case valuesRule:
+			var err error
+			if found, err = v.validateValues(r); err != nil {
+				return nil, fmt.Errorf("validation rule %s: %s", r.Name, err)
+			}
+		case conditionRule:
+			found = v.validateCondition(r)
+		case uniqueRule:
+			found = v.validateUnique(r.Columns)
+		case gbRefDateRule:
+			found = v.validateREFDTE(r.Columns[0], period, year)
+		case niRefDateRule:
+			found = v.validateNIDates(r.Columns[0], period, year)
+		}
+
+		for _, f := range found {
+			if f.Rule == types.RuleRequiredColumn {
+				if notFound[f.Column] {
+					continue
+				}
+				notFound[f.Column] = true
+			}
+
+			f.RuleName = r.Name
+			if r.Message != "" {
+				f.Message = r.Message
+			}
+			failures = append(failures, f)
+		}
+	}
+
+	return failures, nil
+}
+
+func (v Validator) validateRequired(columns []string) []types.ValidationFailure {
+	var failures []types.ValidationFailure
+
+	for _, col := range columns {
+		if _, ok := v.findRowIndex(col); !ok {
+			failures = append(failures, v.failure(types.RuleRequiredColumn, col, -1, "",
+				fmt.Sprintf("cannot find column %s", col)))
+		}
+	}
+
+	return failures
+}
+
+/*
+Call check with the numeric value of each row in a column, skipping missing values. Values that are not numeric
+are reported.
+*/
+func (v Validator) eachNumber(col string, check func(row int, value string, f float64) *types.ValidationFailure) []types.ValidationFailure {
+	inx, ok := v.findRowIndex(col)
+	if !ok {
+		return []types.ValidationFailure{
+			v.failure(types.RuleRequiredColumn, col, -1, "", fmt.Sprintf("cannot find column %s", col)),
+		}
+	}
+
+	var failures []types.ValidationFailure
+
+	for row, r := range v.data.Rows {
+		val := r.RowData[inx]
+		if val == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			failures = append(failures, v.failure(types.RuleNumeric, col, row, val,
+				fmt.Sprintf("column %s has a non numeric value", col)))
+			continue
+		}
+		if math.IsNaN(f) {
+			continue
+		}
+
+		if failure := check(row, val, f); failure != nil {
+			failures = append(failures, *failure)
+		}
+	}
+
+	return failures
+}
+
+func (v Validator) validateRange(r rule) []types.ValidationFailure {
+	var failures []types.ValidationFailure
+
+	for _, col := range r.Columns {
+		col := col
+		failures = append(failures, v.eachNumber(col, func(row int, value string, f float64) *types.ValidationFailure {
+			if (r.Min != nil && f < *r.Min) || (r.Max != nil && f > *r.Max) {
+				failure := v.failure(types.RuleRange, col, row, value,
+					fmt.Sprintf("column %s value %s is outside the range %s", col, value, rangeText(r)))
+				return &failure
+			}
+			return nil
+		})...)
+	}
+
+	return failures
+}
+
+func rangeText(r rule) string {
+	switch {
+	case r.Min == nil:
+		return fmt.Sprintf("up to %g", *r.Max)
+	case r.Max == nil:
+		return fmt.Sprintf("%g and over", *r.Min)
+	}
+	return fmt.Sprintf("%g to %g", *r.Min, *r.Max)
+}
+
+func (v Validator) validateValues(r rule) ([]types.ValidationFailure, error) {
+	var failures []types.ValidationFailure
+
+	for _, col := range r.Columns {
+		col := col
+
+		allowed, err := allowedValues(r, col)
+		if err != nil {
+			return nil, err
+		}
+
+		failures = append(failures, v.eachNumber(col, func(row int, value string, f float64) *types.ValidationFailure {
+			if !allowed[f] {
+				failure := v.failure(types.RuleValueLabel, col, row, value,
+					fmt.Sprintf("column %s value %s is not an allowed code", col, value))
+				return &failure
+			}
+			return nil
+		})...)
+	}
+
+	return failures, nil
+}
+
+// the codes allowed for a column, either from the rule or from the value labels loaded for the variable
+func allowedValues(r rule, col string) (map[float64]bool, error) {
+	allowed := make(map[float64]bool)
+
+	if len(r.Values) > 0 {
+		for _, f := range r.Values {
+			allowed[f] = true
+		}
+		return allowed, nil
+	}
+
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to database: %s", err)
+	}
+
+	labels, err := database.GetLabelsForValue(col)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("no value labels found for %s", col)
+	}
+
+	for _, l := range labels {
+		allowed[float64(l.LabelValue)] = true
+	}
+
+	return allowed, nil
+}
+
+func (v Validator) validateCondition(r rule) []types.ValidationFailure {
+	var failures []types.ValidationFailure
+
+	columns := expr.NewColumns(v.data.Header)
+	names := r.check.Columns()
+
+	for row, j := range v.data.Rows {
+		if r.when != nil && !r.when.True(columns, j.RowData) {
+			continue
+		}
+		if r.check.True(columns, j.RowData) {
+			continue
+		}
+
+		// show the values the check was made on so that the failure can be explained
+		values := make([]string, 0, len(names))
+		for _, n := range names {
+			val := "missing"
+			if inx, ok := columns[n]; ok {
+				val = j.RowData[inx]
+			}
+			values = append(values, fmt.Sprintf("%s=%s", n, val))
+		}
+
+		failures = append(failures, v.failure(types.RuleCondition, strings.Join(names, ","), row,
+			strings.Join(values, " "), fmt.Sprintf("%s is not true", r.check)))
+	}
+
+	return failures
+}
+
+func (v Validator) validateUnique(columns []string) []types.ValidationFailure {
+	var failures []types.ValidationFailure
+
+	var inx []int
+	for _, col := range columns {
+		i, ok := v.findRowIndex(col)
+		if !ok {
+			failures = append(failures, v.failure(types.RuleRequiredColumn, col, -1, "",
+				fmt.Sprintf("cannot find column %s", col)))
+			continue
+		}
+		inx = append(inx, i)
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+
+	name := strings.Join(columns, ",")
+	seen := make(map[string]int, len(v.data.Rows))
+
+	for row, r := range v.data.Rows {
+		key := make([]string, len(inx))
+		for k, i := range inx {
+			key[k] = r.RowData[i]
+		}
+		value := strings.Join(key, ",")
+
+		if first, ok := seen[value]; ok {
+			failures = append(failures, v.failure(types.RuleUnique, name, row, value,
+				fmt.Sprintf("%s duplicates row %d", name, first+1)))
+			continue
+		}
+		seen[value] = row
+	}
+
+	return failures
+}
@@ -0,0 +1,84 @@
+package validate
+
+import (
+	"services/config"
+	"services/types"
+	"testing"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestRules(t *testing.T) {
+	data := &types.SavImportData{
+		Header: []types.Header{
+			{VariableName: "HOUT", VariableType: types.TypeDouble},
+			{VariableName: "LSTHO", VariableType: types.TypeDouble},
+			{VariableName: "AGE", VariableType: types.TypeDouble},
+			{VariableName: "SEX", VariableType: types.TypeDouble},
+		},
+		Rows: []types.Rows{
+			{RowData: []string{"11", "NaN", "34", "1"}},
+			{RowData: []string{"37", "NaN", "120", "2"}},
+			{RowData: []string{"37", "11", "NaN", "3"}},
+			{RowData: []string{"11", "NaN", "34", "1"}},
+		},
+	}
+	data.HeaderCount = len(data.Header)
+	data.RowCount = len(data.Rows)
+
+	rules, err := compileRules([]config.ValidationRule{
+		{Name: "Columns", Type: requiredRule, Columns: []string{"HOUT", "PERSNO"}},
+		{Name: "Age", Type: rangeRule, Columns: []string{"age"}, Min: float(0), Max: float(99)},
+		{Name: "Sex", Type: valuesRule, Columns: []string{"SEX"}, Values: []float64{1, 2}},
+		{Name: "Last outcome", Type: conditionRule, When: "HOUT == 37", Check: "present(LSTHO)"},
+		{Name: "Unique", Type: uniqueRule, Columns: []string{"HOUT", "AGE"}, Message: "duplicate"},
+	})
+	if err != nil {
+		t.Fatalf("compileRules returned an error: %s", err)
+	}
+
+	failures, err := Validator{data: data}.applyRules(rules, 1, 2019)
+	if err != nil {
+		t.Fatalf("applyRules returned an error: %s", err)
+	}
+
+	want := []types.ValidationFailure{
+		{RuleName: "Columns", Rule: types.RuleRequiredColumn, Column: "PERSNO"},
+		{RuleName: "Age", Rule: types.RuleRange, Column: "AGE", Row: 2, Value: "120"},
+		{RuleName: "Sex", Rule: types.RuleValueLabel, Column: "SEX", Row: 3, Value: "3"},
+		{RuleName: "Last outcome", Rule: types.RuleCondition, Column: "LSTHO", Row: 2, Value: "LSTHO=NaN"},
+		{RuleName: "Unique", Rule: types.RuleUnique, Column: "HOUT,AGE", Row: 4, Value: "11,34", Message: "duplicate"},
+	}
+
+	if len(failures) != len(want) {
+		t.Fatalf("failures = %d, want %d: %+v", len(failures), len(want), failures)
+	}
+
+	for i, w := range want {
+		f := failures[i]
+		if f.RuleName != w.RuleName || f.Rule != w.Rule || f.Column != w.Column || f.Row != w.Row || f.Value != w.Value {
+			t.Errorf("failure %d = %+v, want %+v", i, f, w)
+		}
+		if w.Message != "" && f.Message != w.Message {
+			t.Errorf("failure %d message = %s, want %s", i, f.Message, w.Message)
+		}
+	}
+}
+
+func TestRuleConfigurationErrors(t *testing.T) {
+	bad := []config.ValidationRule{
+		{Type: "unknown", Columns: []string{"AGE"}},
+		{Type: rangeRule, Columns: []string{"AGE"}},
+		{Type: notMissingRule},
+		{Type: conditionRule, Check: "AGE >"},
+		{Type: gbRefDateRule, Columns: []string{"REFDTE", "AGE"}},
+	}
+
+	for _, r := range bad {
+		if _, err := compileRules([]config.ValidationRule{r}); err == nil {
+			t.Errorf("compileRules(%+v) did not return an error", r)
+		}
+	}
+}
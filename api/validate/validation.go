@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"math"
 	"services/types"
+	"services/util"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type ValidationResult int
@@ -20,15 +23,26 @@ type Val struct {
 type ValidationResponse struct {
 	ValidationResult ValidationResult
 	ErrorMessage     string
+	Failures         []types.ValidationFailure
 }
 
 type Validation interface {
 	Validate(period, year int) (ValidationResponse, error)
 }
 
+// ValidationError distinguishes a file that has failed validation from a failure to process it
+type ValidationError struct {
+	Message  string
+	Failures []types.ValidationFailure
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
 func (v Validator) findRowIndex(colName string) (int, bool) {
 	for i, col := range v.data.Header {
-		if col.VariableName == colName {
+		if strings.EqualFold(col.VariableName, colName) {
 			return i, true
 		}
 	}
@@ -40,6 +54,9 @@ Base validation. To use this, use composition in concrete structs
 */
 type Validator struct {
 	data *types.SavImportData
+
+	// where the columns CASENO is made from are in each row, resolved once by applyRules
+	caseNoAt []int
 }
 
 func (v Validator) GetRowsAsDouble(colName string) ([]float64, error) {
@@ -78,66 +95,160 @@ func (v Validator) GetRowsAsString(colName string) ([]string, error) {
 }
 
 /*
-Check if any rows in the list of columns to check are 'missing' where missing is defined as a NaN
-for int and float types respectively.
+Build the response for a validation run from the failures found. Every failure is kept in the response so that
+the whole file can be corrected in one go.
+*/
+func (v Validator) response(failures []types.ValidationFailure) ValidationResponse {
+	if len(failures) == 0 {
+		return ValidationResponse{
+			ValidationResult: ValidationSuccessful,
+			ErrorMessage:     "Successful",
+		}
+	}
+
+	message := failures[0].Message
+	if len(failures) > 1 {
+		message = fmt.Sprintf("%s (and %d other validation failures)", message, len(failures)-1)
+	}
+
+	return ValidationResponse{
+		ValidationResult: ValidationFailed,
+		ErrorMessage:     message,
+		Failures:         failures,
+	}
+}
+
+func (v Validator) failure(rule, column string, row int, value, message string) types.ValidationFailure {
+	f := types.ValidationFailure{
+		Rule:    rule,
+		Column:  column,
+		Row:     row + 1,
+		Value:   value,
+		Message: message,
+	}
+	if row >= 0 {
+		f.CaseNo = v.caseNo(row)
+	} else {
+		f.Row = 0
+	}
+	return f
+}
+
+var caseNoColumns = []string{"QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO"}
+
+// the positions of the columns CASENO is made from, or nil if any of them is missing
+func (v Validator) caseNoIndexes() []int {
+	indexes := make([]int, len(caseNoColumns))
+	for i, col := range caseNoColumns {
+		inx, ok := v.findRowIndex(col)
+		if !ok {
+			return nil
+		}
+		indexes[i] = inx
+	}
+	return indexes
+}
+
+/*
+CASENO is not added until after validation so work it out for the row being reported. An empty string is
+returned if any of the columns it is made from are missing.
+*/
+func (v Validator) caseNo(row int) string {
+	if v.caseNoAt == nil {
+		return ""
+	}
+
+	var values [8]float64
+	for i, inx := range v.caseNoAt {
+		f, err := strconv.ParseFloat(v.data.Rows[row].RowData[inx], 64)
+		if err != nil || math.IsNaN(f) {
+			return ""
+		}
+		values[i] = f
+	}
+	n := util.CaseNo(values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7])
+	return strconv.FormatInt(n, 10)
+}
+
+/*
+Check if any rows in the list of columns to check are 'missing' where missing is defined as an empty
+string for string types and a NaN for int and float types respectively.
 */
-func (v Validator) validateMissingValues(columnsToCheck []string) (ValidationResponse, error) {
+func (v Validator) validateMissingValues(columnsToCheck []string) []types.ValidationFailure {
+	var failures []types.ValidationFailure
+
 	for _, col := range columnsToCheck {
 
-		floatCheck := func() (ValidationResponse, error) {
-			rows, err := v.GetRowsAsDouble(col)
-			if err != nil {
-				return ValidationResponse{
-					ValidationResult: ValidationFailed,
-					ErrorMessage:     err.Error(),
-				}, err
-			}
-			for _, j := range rows {
-				if math.IsNaN(j) {
-					return ValidationResponse{
-						ValidationResult: ValidationFailed,
-						ErrorMessage:     "column %s has a missing value",
-					}, fmt.Errorf("column %s has a missing value - NaN", v)
+		inx, ok := v.findRowIndex(col)
+		if !ok {
+			failures = append(failures, v.failure(types.RuleRequiredColumn, col, -1, "",
+				fmt.Sprintf("cannot find column %s", col)))
+			continue
+		}
+
+		for row, r := range v.data.Rows {
+			val := r.RowData[inx]
+
+			if v.data.Header[inx].VariableType == types.TypeString {
+				if val == "" {
+					failures = append(failures, v.failure(types.RuleMissingValue, col, row, val,
+						fmt.Sprintf("column %s has a missing value", col)))
 				}
+				continue
 			}
-			return ValidationResponse{
-				ValidationResult: ValidationSuccessful,
-				ErrorMessage:     "Successful",
-			}, nil
-		}
 
-		stringCheck := func() (ValidationResponse, error) {
-			rows, err := v.GetRowsAsString(col)
+			f, err := strconv.ParseFloat(val, 64)
 			if err != nil {
-				return ValidationResponse{
-					ValidationResult: ValidationFailed,
-					ErrorMessage:     err.Error(),
-				}, err
+				failures = append(failures, v.failure(types.RuleNumeric, col, row, val,
+					fmt.Sprintf("column %s has a non numeric value", col)))
+				continue
 			}
-			for _, j := range rows {
-				if j == "" {
-					return ValidationResponse{
-						ValidationResult: ValidationFailed,
-						ErrorMessage:     "column %s has a missing value",
-					}, fmt.Errorf("column %s has a missing value", v)
-				}
+			if math.IsNaN(f) {
+				failures = append(failures, v.failure(types.RuleMissingValue, col, row, val,
+					fmt.Sprintf("column %s has a missing value - NaN", col)))
 			}
-			return ValidationResponse{
-				ValidationResult: ValidationSuccessful,
-				ErrorMessage:     "Successful",
-			}, nil
 		}
+	}
 
-		if col == "PCODE" {
-			return stringCheck()
-		} else {
-			return floatCheck()
-		}
+	return failures
+}
 
+/*
+Get the values of a date column as times. Rows that are not numeric or are missing are left out as
+the notMissing rule reports them. The rows returned are the indexes of the dates returned.
+*/
+func (v Validator) getRowsAsTime(colName string) ([]time.Time, []int, bool) {
+	inx, ok := v.findRowIndex(colName)
+	if !ok {
+		return nil, nil, false
 	}
 
-	return ValidationResponse{
-		ValidationResult: ValidationSuccessful,
-		ErrorMessage:     "Successful",
-	}, nil
+	var times []time.Time
+	var rows []int
+
+	for row, r := range v.data.Rows {
+		f, err := strconv.ParseFloat(r.RowData[inx], 64)
+		if err != nil || math.IsNaN(f) {
+			continue
+		}
+		times = append(times, spssTime(f))
+		rows = append(rows, row)
+	}
+
+	return times, rows, true
+}
+
+/*
+SPSS stores timestamps as the numbers of seconds between the year 1582 (start of the Gregorian calendar)
+and a given time on a given date. To get the actual date from this we need to:
+
+1. Get the difference between the Gregorian time and the Unix epoch in seconds (141428)
+2. Multiply this value by the number of seconds in a day (86400)
+3. Subtract this value from the SPSS timestamp to get the Unix time, and
+4. Get the date from the Unix time using standard Go functions.
+
+*/
+func spssTime(f float64) time.Time {
+	i := int64(f) - (141428 * 86400)
+	return time.Unix(i, 0)
 }
@@ -0,0 +1,95 @@
+package validate_test
+
+import (
+	"services/api/validate"
+	"services/types"
+	"testing"
+)
+
+// Sunday 6th January 2019 (week 1) at midday as an SPSS date
+const sunday = "13766155200"
+
+func surveyData(rows [][]string) *types.SavImportData {
+	columns := []string{"REFDTE", "PCODE", "QUOTA", "WEEK", "W1YR", "QRTR", "ADDR", "WAVFND", "HHLD", "PERSNO"}
+
+	data := &types.SavImportData{}
+	for _, c := range columns {
+		variableType := types.TypeDouble
+		if c == "PCODE" {
+			variableType = types.TypeString
+		}
+		data.Header = append(data.Header, types.Header{VariableName: c, VariableType: variableType})
+	}
+	data.HeaderCount = len(data.Header)
+
+	for _, r := range rows {
+		data.Rows = append(data.Rows, types.Rows{RowData: r})
+	}
+	data.RowCount = len(data.Rows)
+
+	return data
+}
+
+func TestGBValidationReportsEveryFailure(t *testing.T) {
+	data := surveyData([][]string{
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "1", "1", "1", "1"},
+		{sunday, "", "1", "1", "9", "1", "2", "1", "1", "1"},
+		{sunday, "NP10 8XG", "NaN", "1", "9", "1", "3", "1", "1", "1"},
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "4", "1", "1", "NaN"},
+	})
+
+	res, err := validate.NewGBSurveyValidation(data).Validate(1, 2019)
+	if err != nil {
+		t.Fatalf("Validate returned an error: %s", err)
+	}
+
+	if res.ValidationResult != validate.ValidationFailed {
+		t.Fatalf("ValidationResult = %d, want %d", res.ValidationResult, validate.ValidationFailed)
+	}
+
+	if len(res.Failures) != 3 {
+		t.Fatalf("failures = %d, want 3: %+v", len(res.Failures), res.Failures)
+	}
+
+	pcode := res.Failures[0]
+	if pcode.Column != "PCODE" || pcode.Row != 2 || pcode.Rule != types.RuleMissingValue {
+		t.Errorf("first failure = %+v, want a missing PCODE on row 2", pcode)
+	}
+
+	if pcode.CaseNo != "101910210101" {
+		t.Errorf("CaseNo = %s, want 101910210101", pcode.CaseNo)
+	}
+
+	quota := res.Failures[1]
+	if quota.Column != "QUOTA" || quota.Row != 3 || quota.CaseNo != "" {
+		t.Errorf("second failure = %+v, want a missing QUOTA on row 3 with no CASENO", quota)
+	}
+}
+
+func TestGBValidationReportsWrongWeek(t *testing.T) {
+	data := surveyData([][]string{
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "1", "1", "1", "1"},
+	})
+
+	res, _ := validate.NewGBSurveyValidation(data).Validate(2, 2019)
+
+	if len(res.Failures) != 1 {
+		t.Fatalf("failures = %d, want 1: %+v", len(res.Failures), res.Failures)
+	}
+
+	if res.Failures[0].Rule != types.RuleRefDateWeek || res.Failures[0].Row != 0 {
+		t.Errorf("failure = %+v, want a file level week failure", res.Failures[0])
+	}
+}
+
+func TestGBValidationSuccessful(t *testing.T) {
+	data := surveyData([][]string{
+		{sunday, "NP10 8XG", "1", "1", "9", "1", "1", "1", "1", "1"},
+	})
+
+	res, _ := validate.NewGBSurveyValidation(data).Validate(1, 2019)
+
+	if res.ValidationResult != validate.ValidationSuccessful {
+		t.Errorf("ValidationResult = %d, want %d: %+v", res.ValidationResult, validate.ValidationSuccessful, res.Failures)
+	}
+}
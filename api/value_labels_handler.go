@@ -6,35 +6,22 @@ import (
 	"github.com/rs/zerolog/log"
 	"net/http"
 	"os"
+	"services/api/jobs"
 	"services/types"
 	"strings"
-	"sync"
 )
 
 type ValueLabelsHandler struct {
-	mutux            *sync.Mutex
-	uploadInProgress bool // we can only handle a single upload at a time
+	queue *jobs.Queue
 }
 
-func NewValueLabelsHandler() *ValueLabelsHandler {
-	return &ValueLabelsHandler{
-		mutux:            &sync.Mutex{},
-		uploadInProgress: false,
-	}
-}
-
-func (vl *ValueLabelsHandler) setUpload(val bool) {
-	vl.mutux.Lock()
-	vl.uploadInProgress = val
-	vl.mutux.Unlock()
+func NewValueLabelsHandler(queue *jobs.Queue) *ValueLabelsHandler {
+	vl := &ValueLabelsHandler{queue: queue}
+	queue.Register(types.ValueLabelsJob, vl.processValLabJob)
+	return vl
 }
 
 func (vl ValueLabelsHandler) HandleValLabRequestlUpload(w http.ResponseWriter, r *http.Request) {
-	if vl.uploadInProgress {
-		log.Error().Msg("Value labels file is currently being uploaded")
-		ErrorResponse{Status: Error, ErrorMessage: "value labels file is currently being uploaded"}.sendResponse(w, r)
-		return
-	}
 
 	vars := mux.Vars(r)
 	source := vars["source"]
@@ -48,35 +35,33 @@ func (vl ValueLabelsHandler) HandleValLabRequestlUpload(w http.ResponseWriter, r
 		return
 	}
 
-	vl.setUpload(true)
-
 	fileName := r.FormValue("fileName")
 	if fileName == "" {
 		log.Error().Msg("File name not set")
 		ErrorResponse{Status: Error, ErrorMessage: "fileName not set"}.sendResponse(w, r)
-		vl.setUpload(false)
 		return
 	}
 
 	tmpfile, err := SaveStreamToTempFile(w, r)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		vl.setUpload(false)
 		return
 	}
 
-	defer func() {
-		vl.setUpload(false)
+	job, err := vl.queue.Enqueue(types.UploadJob{
+		JobType:     types.ValueLabelsJob,
+		FileName:    fileName,
+		FilePath:    tmpfile,
+		FileSource:  types.FileSource(source),
+		SubmittedBy: actingUser(r),
+	})
+	if err != nil {
 		_ = os.Remove(tmpfile)
-	}()
-
-	if err := vl.parseValLabUpload(tmpfile, fileName, types.FileSource(source)); err != nil {
-		log.Debug().Msg("Cannot process Value Upload upload")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
 	}
 
-	OkayResponse{OK}.sendResponse(w, r)
+	InProgressResponse{JobId: job.Id}.sendResponse(w, r)
 }
 
 func (vl ValueLabelsHandler) HandleValLabRequestValue(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +38,11 @@ func (vl ValueLabelsHandler) getValLabByValue(value string) ([]types.ValueLabels
 	return res, nil
 }
 
-func (vl ValueLabelsHandler) parseValLabUpload(tmpfile, fileName string, source types.FileSource) error {
+func (vl ValueLabelsHandler) processValLabJob(job types.UploadJob, _ *types.WSMessage) error {
+	return vl.parseValLabUpload(job.FilePath, job.FileName, job.FileSource, job.SubmittedBy)
+}
+
+func (vl ValueLabelsHandler) parseValLabUpload(tmpfile, fileName string, source types.FileSource, user string) error {
 	var csvFile []types.ValueLabelsImport
 
 	if err := importdata.ImportCSVFile(tmpfile, &csvFile); err != nil {
@@ -77,6 +81,7 @@ func (vl ValueLabelsHandler) parseValLabUpload(tmpfile, fileName string, source
 			Source:       string(source),
 			VariableType: getSource(j.Value),
 			LastUpdated:  time.Now(),
+			UpdatedBy:    user,
 		}
 	}
 
@@ -85,6 +90,7 @@ func (vl ValueLabelsHandler) parseValLabUpload(tmpfile, fileName string, source
 			Err(err).
 			Str("fileName", fileName).
 			Msg("Cannot persist value labels")
+		return err
 	}
 
 	return nil
@@ -6,63 +6,48 @@ import (
 	"github.com/rs/zerolog/log"
 	"net/http"
 	"os"
-	"sync"
+	"services/api/jobs"
+	"services/types"
 )
 
 type VariableDefinitionsHandler struct {
-	mutux            *sync.Mutex
-	uploadInProgress bool // we can only handle a single upload at a time
+	queue *jobs.Queue
 }
 
-func NewVariableDefinitionsHandler() *VariableDefinitionsHandler {
-	return &VariableDefinitionsHandler{
-		mutux:            &sync.Mutex{},
-		uploadInProgress: false,
-	}
-}
-
-func (vd *VariableDefinitionsHandler) setUpload(val bool) {
-	vd.mutux.Lock()
-	vd.uploadInProgress = val
-	vd.mutux.Unlock()
+func NewVariableDefinitionsHandler(queue *jobs.Queue) *VariableDefinitionsHandler {
+	vd := &VariableDefinitionsHandler{queue: queue}
+	queue.Register(types.VariableDefinitionsJob, vd.processVDJob)
+	return vd
 }
 
 func (vd VariableDefinitionsHandler) HandleRequestVariableUpload(w http.ResponseWriter, r *http.Request) {
-	if vd.uploadInProgress {
-		log.Error().Msg("Variable Definitions file is currently being uploaded")
-		ErrorResponse{Status: Error, ErrorMessage: "variable definitions file is currently being uploaded"}.sendResponse(w, r)
-		return
-	}
-
-	vd.setUpload(true)
 
 	fileName := r.FormValue("fileName")
 	if fileName == "" {
 		log.Error().Msg("File name not set")
 		ErrorResponse{Status: Error, ErrorMessage: "fileName not set"}.sendResponse(w, r)
-		vd.setUpload(false)
 		return
 	}
 
 	tmpfile, err := SaveStreamToTempFile(w, r)
 	if err != nil {
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
-		vd.setUpload(false)
 		return
 	}
 
-	defer func() {
-		vd.setUpload(false)
+	job, err := vd.queue.Enqueue(types.UploadJob{
+		JobType:     types.VariableDefinitionsJob,
+		FileName:    fileName,
+		FilePath:    tmpfile,
+		SubmittedBy: actingUser(r),
+	})
+	if err != nil {
 		_ = os.Remove(tmpfile)
-	}()
-
-	if err := vd.parseVDUpload(tmpfile, fileName); err != nil {
-		log.Debug().Msg("Cannot process Variable Definitions upload")
 		ErrorResponse{Status: Error, ErrorMessage: err.Error()}.sendResponse(w, r)
 		return
 	}
 
-	OkayResponse{OK}.sendResponse(w, r)
+	InProgressResponse{JobId: job.Id}.sendResponse(w, r)
 }
 
 func (vd VariableDefinitionsHandler) HandleRequestVariable(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +38,11 @@ func (vd VariableDefinitionsHandler) getVDByVariable(variable string) ([]types.V
 	return res, nil
 }
 
-func (vd VariableDefinitionsHandler) parseVDUpload(tmpfile, fileName string) error {
+func (vd VariableDefinitionsHandler) processVDJob(job types.UploadJob, _ *types.WSMessage) error {
+	return vd.parseVDUpload(job.FilePath, job.FileName, job.SubmittedBy)
+}
+
+func (vd VariableDefinitionsHandler) parseVDUpload(tmpfile, fileName, user string) error {
 	var csvFile []types.VariableDefinitionsImport
 
 	if err := importdata.ImportCSVFile(tmpfile, &csvFile); err != nil {
@@ -66,6 +70,7 @@ func (vd VariableDefinitionsHandler) parseVDUpload(tmpfile, fileName string) err
 		v[i].Editable = vd.mapBool(j.Editable)
 		v[i].Imputation = vd.mapBool(j.Imputation)
 		v[i].DV = vd.mapBool(j.DV)
+		v[i].ChangedBy = user
 	}
 
 	log.Debug().
@@ -84,6 +89,7 @@ func (vd VariableDefinitionsHandler) parseVDUpload(tmpfile, fileName string) err
 			Err(err).
 			Str("fileName", fileName).
 			Msg("Cannot persist variable definitions")
+		return err
 	}
 
 	return nil
@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"services/db"
+	"services/types"
+	"sync"
+)
+
+// size of each client's queue of outgoing messages. A client that falls this far behind misses messages
+const sendQueueSize = 256
+
+type client struct {
+	conn  *websocket.Conn
+	send  chan types.WSMessage
+	files map[string]bool
+	jobs  map[int]bool
+	all   bool
+	mux   *sync.Mutex
+}
+
+func newClient(conn *websocket.Conn) *client {
+	return &client{
+		conn:  conn,
+		send:  make(chan types.WSMessage, sendQueueSize),
+		files: make(map[string]bool),
+		jobs:  make(map[int]bool),
+		mux:   &sync.Mutex{},
+	}
+}
+
+func (c *client) subscribe(req types.WSRequest) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if req.All {
+		c.all = true
+		return
+	}
+	if req.JobId > 0 {
+		c.jobs[req.JobId] = true
+		return
+	}
+	c.files[req.Filename] = true
+}
+
+func (c *client) unsubscribe(req types.WSRequest) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if req.All {
+		c.all = false
+		c.files = make(map[string]bool)
+		c.jobs = make(map[int]bool)
+		return
+	}
+	if req.JobId > 0 {
+		delete(c.jobs, req.JobId)
+		return
+	}
+	delete(c.files, req.Filename)
+}
+
+func (c *client) wants(m types.WSMessage) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.all || c.jobs[m.JobId] || c.files[m.Filename]
+}
+
+// queue a message without blocking the caller
+func (c *client) push(m types.WSMessage) {
+	select {
+	case c.send <- m:
+	default:
+		log.Warn().
+			Int("jobId", m.JobId).
+			Str("fileName", m.Filename).
+			Msg("WebSocket client is not keeping up, status message dropped")
+	}
+}
+
+// Hub pushes upload status changes to subscribed web socket clients. Changes arrive through a database
+// notification so that clients connected to any instance see uploads being processed by every instance.
+type Hub struct {
+	clients   map[*client]bool
+	listening bool
+	mux       *sync.Mutex
+}
+
+var hub *Hub
+var hubOnce sync.Once
+
+func getHub() *Hub {
+	hubOnce.Do(func() {
+		hub = &Hub{
+			clients: make(map[*client]bool),
+			mux:     &sync.Mutex{},
+		}
+		hub.listen()
+	})
+	return hub
+}
+
+func (h *Hub) listen() {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err == nil {
+		err = database.ListenUploadStatus(h.broadcast)
+	}
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot listen for upload status notifications, only uploads on this instance will be pushed")
+		return
+	}
+
+	h.mux.Lock()
+	h.listening = true
+	h.mux.Unlock()
+}
+
+func (h *Hub) isListening() bool {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return h.listening
+}
+
+func (h *Hub) register(c *client) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.clients[c] = true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	delete(h.clients, c)
+}
+
+func (h *Hub) broadcast(m types.WSMessage) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for c := range h.clients {
+		if c.wants(m) {
+			c.push(m)
+		}
+	}
+}
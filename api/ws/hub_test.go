@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"services/types"
+	"testing"
+)
+
+func TestClientSubscriptions(t *testing.T) {
+	byJob := types.WSMessage{JobId: 7, Filename: "LFSwk18PERS_GB_1920.sav"}
+	byFile := types.WSMessage{JobId: 8, Filename: "LFSwk18PERS_GB_1920.sav"}
+	other := types.WSMessage{JobId: 9, Filename: "LFSwk19PERS_GB_1920.sav"}
+
+	tests := []struct {
+		name        string
+		subscribe   []types.WSRequest
+		unsubscribe []types.WSRequest
+		message     types.WSMessage
+		want        bool
+	}{
+		{
+			name:    "no subscriptions",
+			message: byJob,
+			want:    false,
+		},
+		{
+			name:      "subscribed to the job",
+			subscribe: []types.WSRequest{{JobId: 7}},
+			message:   byJob,
+			want:      true,
+		},
+		{
+			name:      "subscribed to another job",
+			subscribe: []types.WSRequest{{JobId: 7}},
+			message:   other,
+			want:      false,
+		},
+		{
+			name:      "job takes precedence over the file in a request",
+			subscribe: []types.WSRequest{{JobId: 7, Filename: "LFSwk18PERS_GB_1920.sav"}},
+			message:   byFile,
+			want:      false,
+		},
+		{
+			name:      "subscribed to the file",
+			subscribe: []types.WSRequest{{Filename: "LFSwk18PERS_GB_1920.sav"}},
+			message:   byFile,
+			want:      true,
+		},
+		{
+			name:      "subscribed to another file",
+			subscribe: []types.WSRequest{{Filename: "LFSwk18PERS_GB_1920.sav"}},
+			message:   other,
+			want:      false,
+		},
+		{
+			name:      "subscribed to all",
+			subscribe: []types.WSRequest{{All: true}},
+			message:   other,
+			want:      true,
+		},
+		{
+			name:        "unsubscribed from the job",
+			subscribe:   []types.WSRequest{{JobId: 7}},
+			unsubscribe: []types.WSRequest{{JobId: 7}},
+			message:     byJob,
+			want:        false,
+		},
+		{
+			name:        "unsubscribed from another job",
+			subscribe:   []types.WSRequest{{JobId: 7}},
+			unsubscribe: []types.WSRequest{{JobId: 9}},
+			message:     byJob,
+			want:        true,
+		},
+		{
+			name:        "unsubscribed from the file",
+			subscribe:   []types.WSRequest{{Filename: "LFSwk18PERS_GB_1920.sav"}},
+			unsubscribe: []types.WSRequest{{Filename: "LFSwk18PERS_GB_1920.sav"}},
+			message:     byFile,
+			want:        false,
+		},
+		{
+			name:        "unsubscribed from all clears every subscription",
+			subscribe:   []types.WSRequest{{All: true}, {JobId: 7}, {Filename: "LFSwk18PERS_GB_1920.sav"}},
+			unsubscribe: []types.WSRequest{{All: true}},
+			message:     byJob,
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(nil)
+			for _, req := range tt.subscribe {
+				c.subscribe(req)
+			}
+			for _, req := range tt.unsubscribe {
+				c.unsubscribe(req)
+			}
+			if got := c.wants(tt.message); got != tt.want {
+				t.Errorf("wants(%d, %s) = %v, want %v", tt.message.JobId, tt.message.Filename, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,53 +1,110 @@
 package ws
 
 import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"services/db"
 	"services/types"
-	"sync"
 )
 
-type FileUploads struct {
-	files map[string]*types.WSMessage
-	mux   *sync.Mutex
-}
-
-var uploads FileUploads
-var cm = &sync.Mutex{}
+// FileUploads gives access to the status of file uploads. The status is kept in the database rather than
+// in memory so that it survives a restart and can be answered by any instance of the service.
+type FileUploads struct{}
 
 func NewFileUploads() FileUploads {
-	cm.Lock()
-	defer cm.Unlock()
-
-	if uploads.files == nil {
-		uploads.files = make(map[string]*types.WSMessage)
-		uploads.mux = &sync.Mutex{}
-	}
-	return uploads
+	return FileUploads{}
 }
 
-func (up *FileUploads) Status(fileName string) *types.WSMessage {
-	m, ok := up.files[fileName]
-	if !ok {
+/*
+Status returns the status of the upload job jobId or, without one, of the latest upload of fileName, reporting
+an unknown upload or database failure as an upload error
+*/
+func (up *FileUploads) Status(jobId int, fileName string) *types.WSMessage {
+	var m *types.WSMessage
+	var err error
+	if jobId > 0 {
+		m, err = up.Lookup(jobId)
+	} else {
+		m, err = up.LookupLatest(fileName)
+	}
+	if err != nil {
 		return &types.WSMessage{
+			JobId:        jobId,
 			Filename:     fileName,
 			Percentage:   0,
 			Status:       types.UploadError,
-			ErrorMessage: "fileName not found",
+			ErrorMessage: err.Error(),
 		}
 	}
 	return m
 }
 
-func (up *FileUploads) Add(fileName string) *types.WSMessage {
-	up.mux.Lock()
-	defer up.mux.Unlock()
+func (up *FileUploads) Lookup(jobId int) (*types.WSMessage, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to database")
+	}
 
-	m := types.NewWSMessage(fileName, persistStatus)
-	up.files[fileName] = m
-	return m
+	m, err := database.GetUploadStatus(jobId)
+	if err != nil {
+		return nil, fmt.Errorf("job not found")
+	}
+	return &m, nil
+}
+
+// LookupLatest returns the status of the most recent upload of a file
+func (up *FileUploads) LookupLatest(fileName string) (*types.WSMessage, error) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to database")
+	}
 
+	m, err := database.GetLatestUploadStatus(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fileName not found")
+	}
+	return &m, nil
 }
 
-//TODO add code here
-func persistStatus() {
+// Add starts the status of an upload job as queued
+func (up *FileUploads) Add(jobId int, fileName string) *types.WSMessage {
+	m := types.NewWSMessage(jobId, fileName, persistStatus)
+	m.SetQueued()
+	return m
+}
+
+func persistStatus(m *types.WSMessage) {
+	database, err := db.GetDefaultPersistenceImpl()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Int("jobId", m.JobId).
+			Str("fileName", m.Filename).
+			Msg("Cannot connect to database to persist upload status")
+		return
+	}
+
+	if err := database.PersistUploadStatus(*m); err != nil {
+		log.Error().
+			Err(err).
+			Int("jobId", m.JobId).
+			Str("fileName", m.Filename).
+			Msg("Cannot persist upload status")
+	}
 
+	// subscribers on every instance, including this one, receive the change through the notification
+	h := getHub()
+	if !h.isListening() {
+		h.broadcast(*m)
+		return
+	}
+
+	if err := database.NotifyUploadStatus(*m); err != nil {
+		log.Error().
+			Err(err).
+			Int("jobId", m.JobId).
+			Str("fileName", m.Filename).
+			Msg("Cannot publish upload status")
+		h.broadcast(*m)
+	}
 }
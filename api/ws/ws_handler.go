@@ -18,6 +18,10 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+/*
+Clients either ask for the current status of a file by sending its fileName, or subscribe to a file (or to all
+uploads) with the subscribe action, after which every status change is pushed to them as it happens.
+*/
 func (wsh WebSocketHandler) ServeWs(w http.ResponseWriter, r *http.Request) {
 
 	ws, err := upgrader.Upgrade(w, r, nil)
@@ -30,32 +34,69 @@ func (wsh WebSocketHandler) ServeWs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	defer func() { _ = ws.Close() }()
+	ws.EnableWriteCompression(true)
 
-	var message types.WSMessage
+	h := getHub()
+	c := newClient(ws)
+	h.register(c)
 
-	ws.EnableWriteCompression(true)
+	done := make(chan struct{})
+	go wsh.writer(c, done)
+
+	defer func() {
+		h.unregister(c)
+		close(done)
+		_ = ws.Close()
+	}()
 
 	uploads := NewFileUploads()
 
 	for {
-		err := ws.ReadJSON(&message)
+		var request types.WSRequest
+
+		err := ws.ReadJSON(&request)
 		if err != nil {
 			break
 		}
 
 		log.Debug().
-			Str("fileName", message.Filename).
+			Int("jobId", request.JobId).
+			Str("fileName", request.Filename).
+			Str("action", request.Action).
+			Bool("all", request.All).
 			Msg("received status request")
 
-		m := uploads.Status(message.Filename)
+		switch request.Action {
+		case types.Subscribe:
+			c.subscribe(request)
+			// send the current state so the client doesn't have to wait for the next change
+			if !request.All {
+				c.push(*uploads.Status(request.JobId, request.Filename))
+			}
 
-		err = ws.WriteJSON(&m)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Msg("WebSocket write error")
-			break
+		case types.Unsubscribe:
+			c.unsubscribe(request)
+
+		default:
+			c.push(*uploads.Status(request.JobId, request.Filename))
+		}
+	}
+}
+
+// all writes to the connection are done here as gorilla web sockets allow only one concurrent writer
+func (wsh WebSocketHandler) writer(c *client, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case m := <-c.send:
+			if err := c.conn.WriteJSON(&m); err != nil {
+				log.Error().
+					Err(err).
+					Msg("WebSocket write error")
+				_ = c.conn.Close()
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,11 @@
+package config
+
+type AuthConfiguration struct {
+	TokenLifetime      string
+	SessionLifetime    string
+	MinPasswordLength  int
+	MaxFailedLogins    int
+	LoginBackoff       string
+	MaxLoginBackoff    string
+	LoginBackoffWindow string
+}
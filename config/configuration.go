@@ -7,6 +7,11 @@ type configuration struct {
 	TestDirectory string
 	Database      DatabaseConfiguration
 	Service       ServiceConfiguration
+	Auth          AuthConfiguration
+	Jobs          JobsConfiguration
 	Rename        Rename
 	DropColumns   DropColumns
+	Validation    Validation
+	SkipRows      SkipRows
+	Harmonise     Harmonise
 }
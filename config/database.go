@@ -11,25 +11,40 @@ type Pool struct {
 }
 
 type DatabaseConfiguration struct {
-	Server              string `env:"DB_SERVER"`
-	User                string `env:"DB_USER"`
-	Password            string `env:"DB_PASSWORD"`
-	Database            string `env:"DB_DATABASE"`
-	Verbose             bool
-	ConnectionPool      Pool
-	SurveyTable         string
-	AddressesTable      string
-	SurveyAuditTable    string
-	BatchInfoView       string
-	GbInfoView          string
-	NiInfoView          string
-	MonthlyBatchTable   string
-	QuarterlyBatchTable string
-	AnnualBatchTable    string
-	GbBatchTable        string
-	NiBatchTable        string
-	UserTable           string
-	DefinitionsTable    string
-	ValueLabelsTable    string
-	ValueLabelsView     string
+	Server                 string `env:"DB_SERVER"`
+	User                   string `env:"DB_USER"`
+	Password               string `env:"DB_PASSWORD"`
+	Database               string `env:"DB_DATABASE"`
+	Verbose                bool
+	ConnectionPool         Pool
+	SurveyTable            string
+	SurveyVersionTable     string
+	SurveyCurrentView      string
+	SurveyVersionsKept     int
+	CopyBatchSize          int
+	AddressesTable         string
+	SurveyAuditTable       string
+	BatchInfoView          string
+	GbInfoView             string
+	NiInfoView             string
+	MonthlyBatchTable      string
+	QuarterlyBatchTable    string
+	AnnualBatchTable       string
+	BatchSurveyTable       string
+	BatchAuditTable        string
+	BatchConflictsTable    string
+	GbBatchTable           string
+	NiBatchTable           string
+	UserTable              string
+	UserRolesTable         string
+	SessionTable           string
+	AuthAuditTable         string
+	DefinitionsTable       string
+	ValueLabelsTable       string
+	ValueLabelsView        string
+	UploadJobsTable        string
+	UploadStatusTable      string
+	ValidationTable        string
+	ExportDefinitionsTable string
+	ExportAuditTable       string
 }
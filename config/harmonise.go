@@ -0,0 +1,6 @@
+package config
+
+// Harmonise maps the names of NI variables onto the GB names they have in the combined datasets
+type Harmonise struct {
+	NI []Columns
+}
@@ -0,0 +1,9 @@
+package config
+
+type JobsConfiguration struct {
+	Workers         int
+	PollInterval    string
+	StaleAfter      string
+	MaxAttempts     int
+	UploadDirectory string `env:"UPLOAD_DIRECTORY"`
+}
@@ -0,0 +1,13 @@
+package config
+
+// SkipRows holds the rules that decide which survey rows are not loaded
+type SkipRows struct {
+	GB []SkipRule
+	NI []SkipRule
+}
+
+// SkipRule drops every row for which Skip, an expression over the row's columns, is true
+type SkipRule struct {
+	Name string
+	Skip string
+}
@@ -0,0 +1,33 @@
+package config
+
+// Validation holds the rules each survey file must pass before it is loaded
+type Validation struct {
+	GB []ValidationRule
+	NI []ValidationRule
+}
+
+/*
+ValidationRule is one check on a survey file. The fields used depend on the rule type:
+
+	required       Columns must be in the file
+	notMissing     Columns must be in the file and have a value on every row
+	range          Columns must lie between Min and Max (either may be left out)
+	values         Columns must be one of Values or, if Values is empty, a code in the value labels table
+	condition      Check must be true on every row where When, if set, is true
+	unique         the combination of Columns must be unique across all rows
+	gbRefDate      Columns[0] is the same Sunday on every row, in the week and year being loaded
+	niRefDate      Columns[0] holds 4 or 5 Sundays, all in the month and year being loaded
+
+Missing values are only reported by notMissing; the other rules ignore them.
+*/
+type ValidationRule struct {
+	Name    string
+	Type    string
+	Columns []string
+	Min     *float64
+	Max     *float64
+	Values  []float64
+	When    string
+	Check   string
+	Message string
+}
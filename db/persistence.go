@@ -7,6 +7,7 @@ import (
 	"services/db/postgres"
 	"services/types"
 	"sync"
+	"time"
 )
 
 var cachedConnection Persistence
@@ -23,7 +24,7 @@ func GetDefaultPersistenceImpl() (Persistence, error) {
 		return cachedConnection, nil
 	}
 
-	cachedConnection = &postgres.Postgres{nil}
+	cachedConnection = &postgres.Postgres{DB: nil}
 
 	if err := cachedConnection.Connect(); err != nil {
 		log.Info().
@@ -48,12 +49,34 @@ type Persistence interface {
 
 	// Import
 	PersistSurvey(vo types.SurveyVO) error
-	PersistVariableDefinitions([]types.Header, types.FileSource) error
+	PersistVariableDefinitions([]types.Header, types.FileSource, string) error
 	PersistDVChanges(definitions []types.VariableDefinitions) error
 	PersistAddresses(headers []string, rows [][]string, status *types.WSMessage) error
 
 	// User
 	GetUserID(user string) (types.UserCredentials, error)
+	GetUserRoles(user string) ([]types.Role, error)
+	GetUsers() ([]types.User, error)
+	GetUser(username string) (types.User, error)
+	CreateUser(username, passwordHash string, roles []types.Role) error
+	SetUserRoles(username string, roles []types.Role) error
+	SetUserPassword(username, passwordHash string) error
+	SetUserDisabled(username string, disabled bool) error
+	RecordLoginFailure(username string, maxFailures int) (bool, error)
+	UnlockUser(username string) error
+
+	// Sessions
+	CreateSession(session types.Session) error
+	GetSession(tokenHash string) (types.Session, error)
+	RefreshSession(tokenHash string, session types.Session) error
+	DeleteSession(tokenHash string) error
+	DeleteUserSessions(username string) error
+	DeleteOtherUserSessions(username, tokenHash string) error
+
+	// Authentication audit
+	AuditAuthEvent(event types.AuthAudit) error
+	GetAuthAudits(query types.AuthAuditQuery) ([]types.AuthAudit, error)
+	GetLoginFailures(username, client string, since time.Time) (types.LoginFailures, error)
 
 	// New Batch
 	MonthlyBatchExists(month, year int) bool
@@ -65,8 +88,9 @@ type Persistence interface {
 	ValidateQuartersForAnnualBatch(year int) ([]types.QuarterlyBatch, error)
 
 	CreateMonthlyBatch(batch types.MonthlyBatch) error
-	CreateQuarterlyBatch(batch types.QuarterlyBatch) error
-	CreateAnnualBatch(batch types.AnnualBatch) error
+	CreateQuarterlyBatch(batch types.QuarterlyBatch, assembler types.Assembler) (types.BatchAudit, error)
+	CreateAnnualBatch(batch types.AnnualBatch, assembler types.Assembler) (types.BatchAudit, error)
+	HarmoniseMonthlyBatch(month, year int, user string, assembler types.Assembler) (types.BatchAudit, error)
 
 	FindGBBatchInfo(week, year int) (types.GBBatchItem, error)
 	FindNIBatchInfo(month, year int) (types.NIBatchItem, error)
@@ -76,11 +100,39 @@ type Persistence interface {
 	GetIdsByQuarter(year types.Year, quarter types.Quarter) ([]types.QuarterID, error)
 	GetIdsByMonth(year types.Year, quarter types.Month) ([]types.MonthID, error)
 
+	// Survey Versions
+	GetSurveyVersions(source types.FileSource, year, period int) ([]types.SurveyVersion, error)
+	GetSurveyVersion(versionId int) (types.SurveyVersion, error)
+	DiffSurveyVersions(from, to int) (types.SurveyVersionDiff, error)
+	RollbackSurveyVersion(versionId int, user string) (types.SurveyVersion, error)
+
+	// Exports
+	GetExportVariables(audience types.Audience) ([]string, error)
+	ExportSurvey(dataset types.Dataset, period types.ExportPeriod, count func(rows int) error, emit func(columns map[string]interface{}) error) error
+
+	// Survey Queries
+	QuerySurvey(query types.SurveyQuery, emit func(columns map[string]interface{}) error) (int64, error)
+	TabulateSurvey(t types.SurveyTabulation) ([]types.TabulationCell, error)
+
+	// Export Definitions
+	GetExportDefinitions() ([]types.ExportDefinition, error)
+	GetExportDefinition(variable string) (types.ExportDefinition, error)
+	CreateExportDefinition(d types.ExportDefinition, changedBy string) error
+	UpdateExportDefinition(d types.ExportDefinition, changedBy string) error
+	DeleteExportDefinition(variable, changedBy string) error
+	ImportExportDefinitions(definitions []types.ExportDefinition, changedBy string) (types.ExportDefinitionsImport, error)
+	GetExportDefinitionsAudit(variable string) ([]types.ExportDefinitionAudit, error)
+
 	// Audits
-	GetAllAudits() ([]types.Audit, error)
-	GetAuditsByYear(year types.Year) ([]types.Audit, error)
-	GetAuditsByYearMonth(month types.Month, year types.Year) ([]types.Audit, error)
-	GetAuditsByYearWeek(week types.Week, year types.Year) ([]types.Audit, error)
+	GetAllAudits(user string) ([]types.Audit, error)
+	GetAuditsByYear(year types.Year, user string) ([]types.Audit, error)
+	GetAuditsByYearMonth(month types.Month, year types.Year, user string) ([]types.Audit, error)
+	GetAuditsByYearWeek(week types.Week, year types.Year, user string) ([]types.Audit, error)
+	GetAudit(auditId int) (types.Audit, error)
+	GetBatchAudits(user string) (types.BatchAudits, error)
+	GetMetadataChanges(user string) ([]types.MetadataChange, error)
+	AuditValidationFailure(audit types.Audit, failures []types.ValidationFailure) (int, error)
+	GetValidationReport(auditId int) ([]types.ValidationFailure, error)
 
 	// Variable Definitions
 	GetAllDefinitions() ([]types.VariableDefinitionsQuery, error)
@@ -96,7 +148,27 @@ type Persistence interface {
 	// Value Labels
 	GetAllValueLabels() ([]types.ValueLabelsView, error)
 	GetLabelsForValue(variable string) ([]types.ValueLabelsView, error)
+	GetAllValueLabelsRows() ([]types.ValueLabelsRow, error)
 	PersistValues(types.ValueLabelsRow) error
 	PersistValueLabels([]types.ValueLabelsRow) error
-	PersistSavValueLabels(map[string][]types.Labels, types.FileSource) error
+	PersistSavValueLabels(map[string][]types.Labels, types.FileSource, string) error
+
+	// Upload Jobs
+	CreateUploadJob(job types.UploadJob) (types.UploadJob, error)
+	GetUploadJob(id int) (types.UploadJob, error)
+	GetUploadJobs() ([]types.UploadJob, error)
+	ClaimUploadJob() (types.UploadJob, bool, error)
+	FinishUploadJob(id int, status types.JobStatus, message string) error
+	ChangeUploadJobStatus(id int, to types.JobStatus, from ...types.JobStatus) (bool, error)
+	RetryUploadJob(id int) (bool, error)
+	SetUploadJobResult(id int, result interface{}) error
+	TouchUploadJob(id int) error
+	RequeueStaleUploadJobs(staleAfter time.Duration, maxAttempts int) (int, error)
+
+	// Upload Status
+	PersistUploadStatus(status types.WSMessage) error
+	GetUploadStatus(jobId int) (types.WSMessage, error)
+	GetLatestUploadStatus(fileName string) (types.WSMessage, error)
+	NotifyUploadStatus(status types.WSMessage) error
+	ListenUploadStatus(received func(types.WSMessage)) error
 }
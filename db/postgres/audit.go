@@ -6,6 +6,7 @@ import (
 	"services/config"
 	"services/types"
 	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 type DBAudit struct {
@@ -32,10 +33,34 @@ func (s Postgres) AuditFileUploadEvent(event types.Audit) error {
 	return nil
 }
 
-func (s Postgres) GetAllAudits() ([]types.Audit, error) {
+func (s Postgres) GetAudit(auditId int) (types.Audit, error) {
+	var audit types.Audit
+
+	res := s.DB.Collection(surveyAuditTable).Find(db.Cond{"audit_id": auditId})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&audit); err != nil {
+		if err == db.ErrNoMoreRows {
+			return audit, fmt.Errorf("audit %d not found", auditId)
+		}
+		return audit, err
+	}
+
+	return audit, nil
+}
+
+// the conditions of an audit query, limited to the entries of a user unless user is empty
+func auditCond(cond db.Cond, user string) db.Cond {
+	if user != "" {
+		cond["username"] = user
+	}
+	return cond
+}
+
+func (s Postgres) GetAllAudits(user string) ([]types.Audit, error) {
 
 	var audits []types.Audit
-	res := s.DB.Collection(surveyAuditTable).Find()
+	res := s.DB.Collection(surveyAuditTable).Find(auditCond(db.Cond{}, user))
 	err := res.All(&audits)
 	if err != nil {
 		return nil, res.Err()
@@ -44,7 +69,7 @@ func (s Postgres) GetAllAudits() ([]types.Audit, error) {
 	return audits, nil
 }
 
-func (s Postgres) GetAuditsByYear(year types.Year) ([]types.Audit, error) {
+func (s Postgres) GetAuditsByYear(year types.Year, user string) ([]types.Audit, error) {
 
 	var audits []types.Audit
 	dbAudit := s.DB.Collection(surveyAuditTable)
@@ -52,7 +77,7 @@ func (s Postgres) GetAuditsByYear(year types.Year) ([]types.Audit, error) {
 		log.Error().Str("table", surveyAuditTable).Msg("Table does not exist")
 		return nil, fmt.Errorf("table: %s does not exist", surveyAuditTable)
 	}
-	res := dbAudit.Find(db.Cond{"year": year})
+	res := dbAudit.Find(auditCond(db.Cond{"year": year}, user))
 	err := res.All(&audits)
 	// Error handling
 	if err != nil {
@@ -69,7 +94,7 @@ func (s Postgres) GetAuditsByYear(year types.Year) ([]types.Audit, error) {
 	return audits, nil
 }
 
-func (s Postgres) GetAuditsByYearMonth(month types.Month, year types.Year) ([]types.Audit, error) {
+func (s Postgres) GetAuditsByYearMonth(month types.Month, year types.Year, user string) ([]types.Audit, error) {
 
 	var audits []types.Audit
 	dbAudit := s.DB.Collection(surveyAuditTable)
@@ -77,7 +102,7 @@ func (s Postgres) GetAuditsByYearMonth(month types.Month, year types.Year) ([]ty
 		log.Error().Str("table", surveyAuditTable).Msg("Table does not exist")
 		return nil, fmt.Errorf("table: %s does not exist", surveyAuditTable)
 	}
-	res := dbAudit.Find(db.Cond{"year": year, "month": month})
+	res := dbAudit.Find(auditCond(db.Cond{"year": year, "month": month}, user))
 	err := res.All(&audits)
 	// Error handling
 	if err != nil {
@@ -94,7 +119,7 @@ func (s Postgres) GetAuditsByYearMonth(month types.Month, year types.Year) ([]ty
 	return audits, nil
 }
 
-func (s Postgres) GetAuditsByYearWeek(week types.Week, year types.Year) ([]types.Audit, error) {
+func (s Postgres) GetAuditsByYearWeek(week types.Week, year types.Year, user string) ([]types.Audit, error) {
 
 	var audits []types.Audit
 	dbAudit := s.DB.Collection(surveyAuditTable)
@@ -102,7 +127,7 @@ func (s Postgres) GetAuditsByYearWeek(week types.Week, year types.Year) ([]types
 		log.Error().Str("table", surveyAuditTable).Msg("Table does not exist")
 		return nil, fmt.Errorf("table: %s does not exist", surveyAuditTable)
 	}
-	res := dbAudit.Find(db.Cond{"year": year, "week": week})
+	res := dbAudit.Find(auditCond(db.Cond{"year": year, "week": week}, user))
 	err := res.All(&audits)
 	// Error handling
 	if err != nil {
@@ -118,3 +143,38 @@ func (s Postgres) GetAuditsByYearWeek(week types.Week, year types.Year) ([]types
 
 	return audits, nil
 }
+
+/*
+GetMetadataChanges returns each load of a variable definition or of the value labels of a name, latest first,
+only those of a user if one is given. A load of value labels writes a row for each value, which are one change.
+*/
+func (s Postgres) GetMetadataChanges(user string) ([]types.MetadataChange, error) {
+	var res []types.MetadataChange
+
+	definitions := fmt.Sprintf("SELECT ?::text AS kind, variable AS name, source, valid_from AS changed_at, "+
+		"changed_by FROM %s", definitionsTable)
+	labels := fmt.Sprintf("SELECT DISTINCT ?::text AS kind, name, source, last_updated AS changed_at, "+
+		"updated_by AS changed_by FROM %s", valueLabelsTable)
+
+	args := []interface{}{types.VariableDefinitionChange}
+	if user != "" {
+		definitions += " WHERE changed_by = ?"
+		args = append(args, user)
+	}
+	args = append(args, types.ValueLabelsChange)
+	if user != "" {
+		labels += " WHERE updated_by = ?"
+		args = append(args, user)
+	}
+
+	q := fmt.Sprintf("%s UNION ALL %s ORDER BY changed_at DESC, kind, name", definitions, labels)
+	rows, err := s.DB.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get metadata changes, error: %s", err)
+	}
+	if err := sqlbuilder.NewIterator(rows).All(&res); err != nil {
+		return nil, fmt.Errorf("cannot get metadata changes, error: %s", err)
+	}
+
+	return res, nil
+}
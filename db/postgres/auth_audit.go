@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"fmt"
+	"services/config"
+	"services/types"
+	"time"
+	"upper.io/db.v3"
+)
+
+var authAuditTable string
+
+func init() {
+	authAuditTable = config.Config.Database.AuthAuditTable
+	if authAuditTable == "" {
+		panic("auth audit table configuration not set")
+	}
+}
+
+func (s Postgres) AuditAuthEvent(event types.AuthAudit) error {
+	if _, err := s.DB.Collection(authAuditTable).Insert(event); err != nil {
+		return fmt.Errorf("insert into %s failed, error: %s", authAuditTable, err)
+	}
+	return nil
+}
+
+// GetAuthAudits returns the latest authentication events first
+func (s Postgres) GetAuthAudits(query types.AuthAuditQuery) ([]types.AuthAudit, error) {
+	var res []types.AuthAudit
+
+	cond := db.Cond{}
+	if query.Username != "" {
+		cond["username"] = query.Username
+	}
+	if query.Client != "" {
+		cond["client"] = query.Client
+	}
+	if query.Event != "" {
+		cond["event"] = string(query.Event)
+	}
+
+	find := s.DB.Collection(authAuditTable).Find(cond).OrderBy("-occurred_at", "-id")
+	if query.Limit > 0 {
+		find = find.Limit(query.Limit)
+	}
+	defer func() { _ = find.Close() }()
+
+	if err := find.All(&res); err != nil {
+		return nil, fmt.Errorf("cannot get authentication audit, error: %s", err)
+	}
+
+	return res, nil
+}
+
+/*
+GetLoginFailures counts the failed logins of a username and of a client after since. Failures of a username
+before it last logged in or was unlocked are not counted.
+*/
+func (s Postgres) GetLoginFailures(username, client string, since time.Time) (types.LoginFailures, error) {
+	var failures types.LoginFailures
+
+	q := fmt.Sprintf("SELECT count(*), max(occurred_at) FROM %s WHERE username = ? AND event = ? AND occurred_at > ? "+
+		"AND occurred_at > coalesce((SELECT max(occurred_at) FROM %s WHERE username = ? AND event IN (?, ?)), ?)",
+		authAuditTable, authAuditTable)
+
+	row, err := s.DB.QueryRow(q, username, string(types.LoginFailed), since,
+		username, string(types.LoginSucceeded), string(types.AccountUnlocked), since)
+	if err != nil {
+		return failures, err
+	}
+	if err := row.Scan(&failures.User, &failures.UserLast); err != nil {
+		return failures, fmt.Errorf("cannot count failed logins, error: %s", err)
+	}
+
+	q = fmt.Sprintf("SELECT count(*), max(occurred_at) FROM %s WHERE client = ? AND event = ? AND occurred_at > ?",
+		authAuditTable)
+
+	row, err = s.DB.QueryRow(q, client, string(types.LoginFailed), since)
+	if err != nil {
+		return failures, err
+	}
+	if err := row.Scan(&failures.Client, &failures.ClientLast); err != nil {
+		return failures, fmt.Errorf("cannot count failed logins, error: %s", err)
+	}
+
+	return failures, nil
+}
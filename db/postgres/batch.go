@@ -6,6 +6,7 @@ import (
 	"services/config"
 	"services/types"
 	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 var batchTable string
@@ -365,66 +366,54 @@ func (s Postgres) CreateMonthlyBatch(batch types.MonthlyBatch) error {
 	return nil
 }
 
-func (s Postgres) CreateQuarterlyBatch(batch types.QuarterlyBatch) error {
-	// Create new transaction
-	tx, err := s.DB.NewTx(nil)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Start transaction failed")
-		return fmt.Errorf("cannot start a transaction, error: %s", err)
-	}
-
-	// Insert into quarterly_batch
-	b := tx.Collection(quarterlyBatchTable)
-	_, err = b.Insert(batch)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Cannot insert into " + quarterlyBatchTable)
-		return fmt.Errorf("insert into %s failed, error: %s", quarterlyBatchTable, err)
-	}
-
-	// Commit
-	if err := tx.Commit(); err != nil {
-		log.Error().
-			Err(err).
-			Msg("Commit transaction failed")
-		return fmt.Errorf("commit failed, error: %s", err)
-	}
-
-	return nil
+/*
+CreateQuarterlyBatch adds a quarterly batch with the dataset assembled from its three monthly batches
+*/
+func (s Postgres) CreateQuarterlyBatch(batch types.QuarterlyBatch, assembler types.Assembler) (types.BatchAudit, error) {
+	audit := types.BatchAudit{BatchType: types.QuarterlyBatchType, Year: batch.Year, Period: batch.Quarter,
+		AssembledBy: batch.CreatedBy}
+	return s.createBatch(quarterlyBatchTable, batch, audit, batch.Quarter*3-2, batch.Quarter*3, assembler)
 }
 
-func (s Postgres) CreateAnnualBatch(batch types.AnnualBatch) error {
-	// Create new transaction
-	tx, err := s.DB.NewTx(nil)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Start transaction failed")
-		return fmt.Errorf("cannot start a transaction, error: %s", err)
-	}
+/*
+CreateAnnualBatch adds an annual batch with the dataset assembled from its twelve monthly batches
+*/
+func (s Postgres) CreateAnnualBatch(batch types.AnnualBatch, assembler types.Assembler) (types.BatchAudit, error) {
+	audit := types.BatchAudit{BatchType: types.AnnualBatchType, Year: batch.Year, AssembledBy: batch.CreatedBy}
+	return s.createBatch(annualBatchTable, batch, audit, 1, 12, assembler)
+}
 
-	// Insert into annual_batch
-	b := tx.Collection(annualBatchTable)
-	_, err = b.Insert(batch)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Cannot insert into " + annualBatchTable)
-		return fmt.Errorf("insert into %s failed, error: %s", annualBatchTable, err)
-	}
+func (s Postgres) createBatch(table string, batch interface{}, audit types.BatchAudit, first, last int, assembler types.Assembler) (types.BatchAudit, error) {
+	err := s.inTx(func(tx sqlbuilder.Tx) error {
+		// Insert into the batch table
+		batchId, err := tx.Collection(table).Insert(batch)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("Cannot insert into " + table)
+			return fmt.Errorf("insert into %s failed, error: %s", table, err)
+		}
+		id, ok := batchId.(int64)
+		if !ok {
+			log.Error().
+				Str("table", table).
+				Msgf("Unexpected batch id type %T", batchId)
+			return fmt.Errorf("insert into %s returned an id of type %T, expected an integer", table, batchId)
+		}
+		audit.BatchId = int(id)
 
-	// Commit
-	if err := tx.Commit(); err != nil {
-		log.Error().
-			Err(err).
-			Msg("Commit transaction failed")
-		return fmt.Errorf("commit failed, error: %s", err)
-	}
+		// Assemble the batch dataset
+		if audit, err = s.assembleBatch(tx, audit, first, last, assembler); err != nil {
+			log.Error().
+				Err(err).
+				Str("batchType", string(audit.BatchType)).
+				Msg("Cannot assemble batch dataset")
+			return fmt.Errorf("cannot assemble the %s batch dataset, error: %s", audit.BatchType, err)
+		}
+		return nil
+	})
 
-	return nil
+	return audit, err
 }
 
 func (s Postgres) FindGBBatchInfo(week, year int) (types.GBBatchItem, error) {
@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"services/config"
+	"services/types"
+	"services/util"
+	"strings"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var batchSurveyTable string
+var batchAuditTable string
+var batchConflictsTable string
+
+func init() {
+	batchSurveyTable = config.Config.Database.BatchSurveyTable
+	if batchSurveyTable == "" {
+		panic("batch survey table configuration not set")
+	}
+
+	batchAuditTable = config.Config.Database.BatchAuditTable
+	if batchAuditTable == "" {
+		panic("batch audit table configuration not set")
+	}
+
+	batchConflictsTable = config.Config.Database.BatchConflictsTable
+	if batchConflictsTable == "" {
+		panic("batch conflicts table configuration not set")
+	}
+}
+
+/*
+Assemble the dataset of a batch from the current rows of its monthly batches, latest first, and record it with
+its conflicts in the batch audit. The rows are read outside the transaction, as a COPY cannot share its
+connection with a query, which is safe as only the current versions of each week and month are read.
+*/
+func (s Postgres) assembleBatch(tx sqlbuilder.Tx, audit types.BatchAudit, first, last int, assembler types.Assembler) (types.BatchAudit, error) {
+	startTime := time.Now()
+
+	q := fmt.Sprintf("SELECT s.file_source, s.columns FROM %s s JOIN %s b ON b.id = s.id "+
+		"WHERE b.year = ? AND b.month BETWEEN ? AND ? ORDER BY b.month DESC, s.week DESC",
+		surveyCurrentView, batchTable)
+
+	rows, err := s.DB.Query(q, audit.Year, first, last)
+	if err != nil {
+		return audit, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	sqlTx, ok := tx.Driver().(*sql.Tx)
+	if !ok {
+		return audit, fmt.Errorf("cannot copy batch rows, the transaction is not a database/sql transaction")
+	}
+
+	stmt, err := sqlTx.Prepare(pq.CopyIn(batchSurveyTable, "batch_type", "batch_id", "file_source", "columns"))
+	if err != nil {
+		return audit, err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for rows.Next() {
+		var source string
+		var raw []byte
+		if err := rows.Scan(&source, &raw); err != nil {
+			return audit, err
+		}
+
+		var columns map[string]interface{}
+		if err := json.Unmarshal(raw, &columns); err != nil {
+			return audit, fmt.Errorf("cannot decode survey row: %s", err)
+		}
+
+		source = strings.TrimSpace(source)
+		row, keep := assembler.Add(types.FileSource(source), columns)
+		if !keep {
+			continue
+		}
+
+		b, err := json.Marshal(row)
+		if err != nil {
+			return audit, err
+		}
+		if _, err := stmt.Exec(string(audit.BatchType), audit.BatchId, source, string(b)); err != nil {
+			return audit, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return audit, err
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		return audit, err
+	}
+
+	counts := assembler.Audit()
+	audit.GBRows = counts.GBRows
+	audit.NIRows = counts.NIRows
+	audit.Duplicates = counts.Duplicates
+	audit.Rows = counts.Rows
+	audit.Variables = counts.Variables
+	audit.Converted = counts.Converted
+	audit.AssembledAt = time.Now()
+
+	if err := tx.Collection(batchAuditTable).InsertReturning(&audit); err != nil {
+		return audit, fmt.Errorf("insert into %s failed, error: %s", batchAuditTable, err)
+	}
+
+	audit.Conflicts = assembler.Conflicts()
+	conflicts := tx.Collection(batchConflictsTable)
+	for i := range audit.Conflicts {
+		audit.Conflicts[i].AuditId = audit.Id
+		if _, err := conflicts.Insert(audit.Conflicts[i]); err != nil {
+			return audit, fmt.Errorf("insert into %s failed, error: %s", batchConflictsTable, err)
+		}
+	}
+
+	log.Info().
+		Str("batchType", string(audit.BatchType)).
+		Int("batchId", audit.BatchId).
+		Int("gbRows", audit.GBRows).
+		Int("niRows", audit.NIRows).
+		Int("duplicates", audit.Duplicates).
+		Int("rows", audit.Rows).
+		Int("variables", audit.Variables).
+		Int("conflicts", len(audit.Conflicts)).
+		Str("elapsedTime", util.FmtDuration(startTime)).
+		Msg("Assembled batch dataset")
+
+	return audit, nil
+}
+
+/*
+HarmoniseMonthlyBatch assembles the UK dataset of a month from its GB weeks and NI month, replacing the dataset
+assembled before. The audits of earlier datasets are kept, each recording the user who assembled it.
+*/
+func (s Postgres) HarmoniseMonthlyBatch(month, year int, user string, assembler types.Assembler) (types.BatchAudit, error) {
+	audit := types.BatchAudit{BatchType: types.MonthlyBatchType, Year: year, Period: month, AssembledBy: user}
+
+	var batch types.MonthlyBatch
+	if err := s.DB.Collection(batchTable).Find(db.Cond{"year": year, "month": month}).One(&batch); err != nil {
+		if err == db.ErrNoMoreRows {
+			return audit, fmt.Errorf("monthly batch for month %d, year %d does not exist", month, year)
+		}
+		return audit, err
+	}
+	audit.BatchId = batch.Id
+
+	err := s.inTx(func(tx sqlbuilder.Tx) error {
+		if _, err := tx.DeleteFrom(batchSurveyTable).
+			Where("batch_type = ? AND batch_id = ?", string(audit.BatchType), audit.BatchId).Exec(); err != nil {
+			return fmt.Errorf("delete from %s failed, error: %s", batchSurveyTable, err)
+		}
+
+		var err error
+		if audit, err = s.assembleBatch(tx, audit, month, month, assembler); err != nil {
+			log.Error().
+				Err(err).
+				Int("month", month).
+				Int("year", year).
+				Msg("Cannot assemble UK dataset")
+			return fmt.Errorf("cannot assemble the UK dataset, error: %s", err)
+		}
+		return nil
+	})
+
+	return audit, err
+}
+
+/*
+GetBatchAudits returns every batch created and every dataset assembled, latest first, with the conflicts each
+assembly settled. A user limits both to what that user did.
+*/
+func (s Postgres) GetBatchAudits(user string) (types.BatchAudits, error) {
+	var res types.BatchAudits
+
+	batches := []struct {
+		table, period string
+		batchType     types.BatchType
+	}{
+		{batchTable, "month", types.MonthlyBatchType},
+		{quarterlyBatchTable, "quarter", types.QuarterlyBatchType},
+		{annualBatchTable, "0", types.AnnualBatchType},
+	}
+
+	where := ""
+	if user != "" {
+		where = " WHERE created_by = ?"
+	}
+
+	var args []interface{}
+	selects := make([]string, len(batches))
+	for i, b := range batches {
+		selects[i] = fmt.Sprintf("SELECT ?::text AS batch_type, id, coalesce(year, 0) AS year, "+
+			"coalesce(%s, 0) AS period, coalesce(description, '') AS description, created_by FROM %s%s",
+			b.period, b.table, where)
+		args = append(args, string(b.batchType))
+		if user != "" {
+			args = append(args, user)
+		}
+	}
+
+	q := strings.Join(selects, " UNION ALL ") + " ORDER BY year DESC, period DESC, batch_type, id DESC"
+	rows, err := s.DB.Query(q, args...)
+	if err != nil {
+		return res, fmt.Errorf("cannot get batches, error: %s", err)
+	}
+	if err := sqlbuilder.NewIterator(rows).All(&res.Batches); err != nil {
+		return res, fmt.Errorf("cannot get batches, error: %s", err)
+	}
+
+	cond := db.Cond{}
+	if user != "" {
+		cond["assembled_by"] = user
+	}
+
+	find := s.DB.Collection(batchAuditTable).Find(cond).OrderBy("-assembled_at", "-id")
+	defer func() { _ = find.Close() }()
+
+	if err := find.All(&res.Assemblies); err != nil {
+		return res, fmt.Errorf("cannot get batch audits, error: %s", err)
+	}
+	if len(res.Assemblies) == 0 {
+		return res, nil
+	}
+
+	ids := make([]int, len(res.Assemblies))
+	byId := make(map[int]int, len(res.Assemblies))
+	for i, a := range res.Assemblies {
+		ids[i] = a.Id
+		byId[a.Id] = i
+	}
+
+	var conflicts []types.BatchConflict
+	found := s.DB.Collection(batchConflictsTable).Find(db.Cond{"audit_id IN": ids}).OrderBy("id")
+	defer func() { _ = found.Close() }()
+
+	if err := found.All(&conflicts); err != nil {
+		return res, fmt.Errorf("cannot get batch conflicts, error: %s", err)
+	}
+	for _, c := range conflicts {
+		i := byId[c.AuditId]
+		res.Assemblies[i].Conflicts = append(res.Assemblies[i].Conflicts, c)
+	}
+
+	return res, nil
+}
@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"fmt"
 	"github.com/rs/zerolog/log"
+	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 func (s Postgres) DeleteFrom(table string) error {
@@ -29,3 +31,30 @@ func (s Postgres) CountRows(table string) (int, error) {
 	}
 	return count, nil
 }
+
+/*
+Run fn in a transaction, committing it if fn succeeds
+*/
+func (s Postgres) inTx(fn func(tx sqlbuilder.Tx) error) error {
+	tx, err := s.DB.NewTx(nil)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Start transaction failed")
+		return fmt.Errorf("cannot start a transaction, error: %s", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Commit transaction failed")
+		return fmt.Errorf("commit failed, error: %s", err)
+	}
+
+	return nil
+}
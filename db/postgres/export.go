@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"services/config"
+	"services/types"
+	"strings"
+	"upper.io/db.v3"
+)
+
+var exportDefinitionsTable string
+var surveyCurrentView string
+
+func init() {
+	exportDefinitionsTable = config.Config.Database.ExportDefinitionsTable
+	if exportDefinitionsTable == "" {
+		panic("export definitions table configuration not set")
+	}
+
+	surveyCurrentView = config.Config.Database.SurveyCurrentView
+	if surveyCurrentView == "" {
+		panic("survey current view configuration not set")
+	}
+}
+
+/*
+GetExportVariables returns the variables that can be exported to an audience, in name order
+*/
+func (s Postgres) GetExportVariables(audience types.Audience) ([]string, error) {
+	var definitions []types.ExportDefinition
+
+	res := s.DB.Collection(exportDefinitionsTable).Find(db.Cond{string(audience): "1"}).OrderBy("variables")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&definitions); err != nil {
+		return nil, err
+	}
+
+	variables := make([]string, len(definitions))
+	for i, d := range definitions {
+		variables[i] = strings.ToUpper(d.Variable)
+	}
+
+	return variables, nil
+}
+
+/*
+ExportSurvey passes the columns of each row of a dataset for a period to emit, the current versions of the surveys
+loaded into its monthly batches for the survey dataset. Rows are read from the database as they are emitted rather
+than all at once. If count is given it is called first with the number of rows that will be emitted, which are
+counted and read in one snapshot so that a load or rollback in between cannot change them.
+*/
+func (s Postgres) ExportSurvey(dataset types.Dataset, period types.ExportPeriod, count func(rows int) error, emit func(columns map[string]interface{}) error) error {
+
+	from, order, args, err := surveyFrom(dataset, period, nil)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.NewTx(nil)
+	if err != nil {
+		return fmt.Errorf("cannot start a transaction, error: %s", err)
+	}
+	// nothing is written, so the transaction is always rolled back
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		return err
+	}
+
+	if count != nil {
+		row, err := tx.QueryRow(fmt.Sprintf("SELECT count(*) %s", from), args...)
+		if err != nil {
+			return err
+		}
+		var rows int
+		if err := row.Scan(&rows); err != nil {
+			return err
+		}
+		if err := count(rows); err != nil {
+			return err
+		}
+	}
+
+	q := fmt.Sprintf("SELECT s.columns %s ORDER BY %s", from, order)
+
+	rows, err := tx.Query(q, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return err
+		}
+
+		var columns map[string]interface{}
+		if err := json.Unmarshal(raw, &columns); err != nil {
+			return fmt.Errorf("cannot decode survey row: %s", err)
+		}
+
+		if err := emit(columns); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
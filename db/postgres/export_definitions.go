@@ -0,0 +1,273 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"services/config"
+	"services/types"
+	"services/util"
+	"strings"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var exportAuditTable string
+
+func init() {
+	exportAuditTable = config.Config.Database.ExportAuditTable
+	if exportAuditTable == "" {
+		panic("export audit table configuration not set")
+	}
+}
+
+// the audience flags are bit columns, which are written as 1 or 0
+type bit bool
+
+func (b bit) Value() (driver.Value, error) {
+	if b {
+		return "1", nil
+	}
+	return "0", nil
+}
+
+type exportDefinitionRow struct {
+	Variable       string `db:"variables"`
+	Research       bit    `db:"research"`
+	RegionalClient bit    `db:"regional_client"`
+	Government     bit    `db:"government"`
+	SpecialLicense bit    `db:"special_license"`
+	EndUser        bit    `db:"end_user"`
+	Adhoc          bit    `db:"adhoc"`
+}
+
+func newExportDefinitionRow(d types.ExportDefinition) exportDefinitionRow {
+	return exportDefinitionRow{
+		Variable:       d.Variable,
+		Research:       bit(d.Research),
+		RegionalClient: bit(d.RegionalClient),
+		Government:     bit(d.Government),
+		SpecialLicense: bit(d.SpecialLicense),
+		EndUser:        bit(d.EndUser),
+		Adhoc:          bit(d.Adhoc),
+	}
+}
+
+/*
+Describe the flags changed between two versions of a definition. All of the flags are listed when a definition
+is created or deleted.
+*/
+func exportDefinitionChanges(from, to *types.ExportDefinition) string {
+	var changes []string
+
+	for _, a := range types.Audiences {
+		switch {
+		case from == nil:
+			changes = append(changes, fmt.Sprintf("%s: %d", a, util.Flag(to.Allowed(a))))
+		case to == nil:
+			changes = append(changes, fmt.Sprintf("%s: %d", a, util.Flag(from.Allowed(a))))
+		case from.Allowed(a) != to.Allowed(a):
+			changes = append(changes, fmt.Sprintf("%s: %d -> %d", a, util.Flag(from.Allowed(a)), util.Flag(to.Allowed(a))))
+		}
+	}
+
+	return strings.Join(changes, ", ")
+}
+
+func (s Postgres) GetExportDefinitions() ([]types.ExportDefinition, error) {
+	var definitions []types.ExportDefinition
+
+	res := s.DB.Collection(exportDefinitionsTable).Find().OrderBy("variables")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&definitions); err != nil {
+		return nil, err
+	}
+
+	return definitions, nil
+}
+
+func (s Postgres) GetExportDefinition(variable string) (types.ExportDefinition, error) {
+	var definition types.ExportDefinition
+
+	res := s.DB.Collection(exportDefinitionsTable).Find(db.Cond{"variables": variable})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&definition); err != nil {
+		if err == db.ErrNoMoreRows {
+			return definition, fmt.Errorf("export definition for %s not found", variable)
+		}
+		return definition, err
+	}
+
+	return definition, nil
+}
+
+// the definition of a variable in a transaction, nil if there is none
+func findExportDefinition(tx sqlbuilder.Tx, variable string) (*types.ExportDefinition, error) {
+	var definition types.ExportDefinition
+
+	res := tx.Collection(exportDefinitionsTable).Find(db.Cond{"variables": variable})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&definition); err != nil {
+		if err == db.ErrNoMoreRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &definition, nil
+}
+
+func auditExportDefinition(tx sqlbuilder.Tx, variable string, action types.ExportDefinitionAction, changes, changedBy string) error {
+	_, err := tx.Collection(exportAuditTable).Insert(types.ExportDefinitionAudit{
+		Variable:  variable,
+		Action:    action,
+		Changes:   changes,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+	})
+	return err
+}
+
+/*
+Create or update a definition and audit the change. Nothing is written if the flags have not changed, in
+which case the action returned is empty.
+*/
+func saveExportDefinition(tx sqlbuilder.Tx, d types.ExportDefinition, changedBy string) (types.ExportDefinitionAction, error) {
+
+	existing, err := findExportDefinition(tx, d.Variable)
+	if err != nil {
+		return "", err
+	}
+
+	if existing == nil {
+		if _, err := tx.Collection(exportDefinitionsTable).Insert(newExportDefinitionRow(d)); err != nil {
+			return "", err
+		}
+		changes := exportDefinitionChanges(nil, &d)
+		return types.ExportDefinitionCreated,
+			auditExportDefinition(tx, d.Variable, types.ExportDefinitionCreated, changes, changedBy)
+	}
+
+	changes := exportDefinitionChanges(existing, &d)
+	if changes == "" {
+		return "", nil
+	}
+
+	_, err = tx.Update(exportDefinitionsTable).
+		Set(newExportDefinitionRow(d)).
+		Where(db.Cond{"variables": d.Variable}).
+		Exec()
+	if err != nil {
+		return "", err
+	}
+
+	return types.ExportDefinitionUpdated,
+		auditExportDefinition(tx, d.Variable, types.ExportDefinitionUpdated, changes, changedBy)
+}
+
+func (s Postgres) CreateExportDefinition(d types.ExportDefinition, changedBy string) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		existing, err := findExportDefinition(tx, d.Variable)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("export definition for %s already exists", d.Variable)
+		}
+
+		_, err = saveExportDefinition(tx, d, changedBy)
+		return err
+	})
+}
+
+func (s Postgres) UpdateExportDefinition(d types.ExportDefinition, changedBy string) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		existing, err := findExportDefinition(tx, d.Variable)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("export definition for %s not found", d.Variable)
+		}
+
+		_, err = saveExportDefinition(tx, d, changedBy)
+		return err
+	})
+}
+
+func (s Postgres) DeleteExportDefinition(variable, changedBy string) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		existing, err := findExportDefinition(tx, variable)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("export definition for %s not found", variable)
+		}
+
+		if _, err := tx.DeleteFrom(exportDefinitionsTable).Where(db.Cond{"variables": variable}).Exec(); err != nil {
+			return err
+		}
+
+		return auditExportDefinition(tx, variable, types.ExportDefinitionDeleted,
+			exportDefinitionChanges(existing, nil), changedBy)
+	})
+}
+
+/*
+ImportExportDefinitions creates or updates the definitions in a file. Definitions not in the file are left as
+they are. The import is all or nothing.
+*/
+func (s Postgres) ImportExportDefinitions(definitions []types.ExportDefinition, changedBy string) (types.ExportDefinitionsImport, error) {
+	var result types.ExportDefinitionsImport
+
+	err := s.inTx(func(tx sqlbuilder.Tx) error {
+		for _, d := range definitions {
+			action, err := saveExportDefinition(tx, d, changedBy)
+			if err != nil {
+				return fmt.Errorf("cannot save export definition for %s: %s", d.Variable, err)
+			}
+
+			switch action {
+			case types.ExportDefinitionCreated:
+				result.Created++
+			case types.ExportDefinitionUpdated:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return types.ExportDefinitionsImport{}, err
+	}
+
+	return result, nil
+}
+
+/*
+GetExportDefinitionsAudit returns the changes made to the export definitions, newest first. If a variable is
+given only its changes are returned.
+*/
+func (s Postgres) GetExportDefinitionsAudit(variable string) ([]types.ExportDefinitionAudit, error) {
+	var audits []types.ExportDefinitionAudit
+
+	cond := db.Cond{}
+	if variable != "" {
+		cond["variable"] = variable
+	}
+
+	res := s.DB.Collection(exportAuditTable).Find(cond).OrderBy("-changed_at", "-id")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&audits); err != nil {
+		return nil, err
+	}
+
+	return audits, nil
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"fmt"
+	"services/config"
+	"services/types"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var sessionTable string
+
+func init() {
+	sessionTable = config.Config.Database.SessionTable
+	if sessionTable == "" {
+		panic("session table configuration not set")
+	}
+}
+
+/*
+CreateSession records a login as the user's last, clearing their failed logins and removing any sessions that
+have expired
+*/
+func (s Postgres) CreateSession(session types.Session) error {
+	if _, err := s.DB.DeleteFrom(sessionTable).Where("expires_at < ?", time.Now().UTC()).Exec(); err != nil {
+		return fmt.Errorf("delete from %s failed, error: %s", sessionTable, err)
+	}
+
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		if _, err := tx.Collection(sessionTable).Insert(session); err != nil {
+			return fmt.Errorf("insert into %s failed, error: %s", sessionTable, err)
+		}
+
+		if _, err := tx.Update(userTable).Set("last_login", session.CreatedAt, "failed_logins", 0).
+			Where("username = ?", session.Username).Exec(); err != nil {
+			return fmt.Errorf("update %s failed, error: %s", userTable, err)
+		}
+
+		return nil
+	})
+}
+
+/*
+GetSession finds the session of a token hash. Sessions that have expired are not found.
+*/
+func (s Postgres) GetSession(tokenHash string) (types.Session, error) {
+	var session types.Session
+
+	res := s.DB.Collection(sessionTable).Find(db.Cond{"token_hash": tokenHash, "expires_at >": time.Now().UTC()})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&session); err != nil {
+		if err == db.ErrNoMoreRows {
+			return session, fmt.Errorf("session not found or expired")
+		}
+		return session, err
+	}
+
+	return session, nil
+}
+
+/*
+RefreshSession replaces the token of a session and moves on its expiry. A token can only be refreshed once, so a
+token that has already been refreshed or logged out is not found.
+*/
+func (s Postgres) RefreshSession(tokenHash string, session types.Session) error {
+	res, err := s.DB.Update(sessionTable).
+		Set("token_hash", session.TokenHash, "expires_at", session.ExpiresAt).
+		Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now().UTC()).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("update %s failed, error: %s", sessionTable, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found or expired")
+	}
+
+	return nil
+}
+
+// DeleteSession ends the session of a token hash
+func (s Postgres) DeleteSession(tokenHash string) error {
+	if _, err := s.DB.DeleteFrom(sessionTable).Where("token_hash = ?", tokenHash).Exec(); err != nil {
+		return fmt.Errorf("delete from %s failed, error: %s", sessionTable, err)
+	}
+	return nil
+}
+
+// DeleteUserSessions ends every session of a user
+func (s Postgres) DeleteUserSessions(username string) error {
+	if _, err := s.DB.DeleteFrom(sessionTable).Where("username = ?", username).Exec(); err != nil {
+		return fmt.Errorf("delete from %s failed, error: %s", sessionTable, err)
+	}
+	return nil
+}
+
+// DeleteOtherUserSessions ends every session of a user but the one of a token hash
+func (s Postgres) DeleteOtherUserSessions(username, tokenHash string) error {
+	if _, err := s.DB.DeleteFrom(sessionTable).
+		Where("username = ? AND token_hash <> ?", username, tokenHash).Exec(); err != nil {
+		return fmt.Errorf("delete from %s failed, error: %s", sessionTable, err)
+	}
+	return nil
+}
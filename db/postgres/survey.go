@@ -1,152 +1,82 @@
 package postgres
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"math"
 	"services/config"
 	"services/types"
 	"strconv"
-	"upper.io/db.v3"
+	"strings"
 	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 var surveyTable string
+var copyBatchSize int
 
 func init() {
 	surveyTable = config.Config.Database.SurveyTable
 	if surveyTable == "" {
 		panic("survey table configuration not set")
 	}
-}
-
-func (s Postgres) DeleteSurveyData(audit types.Audit) (bool, error) {
-	col := s.DB.Collection(surveyTable)
-
-	res := col.Find(db.Cond{
-		"file_name": audit.FileName, "week": audit.Week,
-		"year": audit.Year, "file_source": audit.FileSource,
-	})
-
-	defer func() { _ = res.Close() }()
-
-	if res.Err() != nil {
-		return false, res.Err()
-	}
-
-	count, err := res.Count()
-	if err != nil {
-		return true, err
-	}
-	if count == 0 {
-		return false, nil
-	}
-	err = res.Delete()
 
-	if err != nil {
-		return true, err
+	copyBatchSize = config.Config.Database.CopyBatchSize
+	if copyBatchSize <= 0 {
+		copyBatchSize = BatchSize
 	}
-	return true, nil
 }
 
 func (s Postgres) PersistSurvey(vo types.SurveyVO) error {
 
 	log.Debug().Msg("Starting persistence into DB")
 
-	existingDataset, err := s.DeleteSurveyData(*vo.Audit)
+	tx, err := s.DB.NewTx(nil)
 	if err != nil {
 		log.Error().
 			Err(err).
-			Msg("Delete existing survey data failed")
-		return fmt.Errorf("delete existing survey data failed, error: %s", err)
+			Msg("Start transaction failed")
+		return fmt.Errorf("cannot start a transaction, error: %s", err)
 	}
 
-	tx, err := s.DB.NewTx(nil)
+	version, err := s.newSurveyVersion(tx, vo.Audit, vo.RowCount)
 	if err != nil {
+		_ = tx.Rollback()
 		log.Error().
 			Err(err).
-			Msg("Start transaction failed")
-		return fmt.Errorf("cannot start a transaction, error: %s", err)
+			Msg("Cannot create survey version")
+		return fmt.Errorf("cannot create survey version, error: %s", err)
 	}
 
-	body := vo.Data.Rows
-	columns := vo.Data.Header
-
-	defer vo.Status.SetUploadFinished()
-
-	for cnt, v := range body {
-		var rowMap = make(map[string]*interface{})
-
-		for colNo, val := range v.RowData {
-
-			columnKind := columns[colNo].VariableType
-			switch columnKind {
-			case types.TypeString:
-				if val == "NULL" || val == "" {
-					continue
-					//rowMap[columns[colNo].Name] = nil
-				} else {
-					var ms interface{} = val
-					rowMap[columns[colNo].VariableName] = &ms
-				}
-
-			case types.TypeInt8, types.TypeInt16, types.TypeInt32:
-				i64, err := strconv.ParseInt(val, 10, 64)
-				if err != nil {
-					log.Error().
-						Str("methodName", "PersistSurvey").
-						Str("type", string(columnKind)).
-						Msg("field is not an int")
-					return fmt.Errorf("field is not an int")
-				}
-				var ms interface{} = i64
-				if i64 == 0 {
-					continue
-					//ms = nil
-				}
-				rowMap[columns[colNo].VariableName] = &ms
+	columns := vo.Header
 
-			case types.TypeFloat, types.TypeDouble:
-				if val == "" || val == "NULL" {
-					val = "0.0"
-				}
-				f, err := strconv.ParseFloat(val, 64)
-				if err != nil {
-					log.Error().
-						Str("methodName", "PersistSurvey").
-						Str("type", string(columnKind)).
-						Str("variable", columns[colNo].VariableName).
-						Str("value", val).
-						Int("index", colNo).
-						Msg("field is not a float")
-					return fmt.Errorf("field is not a float")
-				}
-				if math.IsNaN(f) {
-					//rowMap[columns[colNo].Name] = nil
-					continue
-				} else {
-					var ms interface{} = f
-					rowMap[columns[colNo].VariableName] = &ms
-				}
-
-			default:
-				log.Error().
-					Str("methodName", "PersistSurvey").
-					Str("type", string(columnKind)).
-					Msg("Unknown type - possible corruption or structure does not map to file")
-				return fmt.Errorf("unknown type - possible corruption or structure does not map to file")
-			}
+	rowsFailed := func(err error) error {
+		_ = tx.Rollback()
+		vo.Audit.AuditId = 0
+		vo.Audit.Status = types.UploadFailed
+		vo.Audit.Message = "Insert survey row failed"
+		_ = s.AuditFileUploadEvent(*vo.Audit)
+		log.Error().
+			Err(err).
+			Int("week", vo.Audit.Week).
+			Int("month", vo.Audit.Month).
+			Int("year", vo.Audit.Year).
+			Msg("Cannot insert survey row")
+		return fmt.Errorf("cannot insert survey row, error: %s", err)
+	}
 
-			if colNo == len(v.RowData)-1 {
-				var perc = (float64(cnt) / float64(len(body))) * 100
-				vo.Status.SetPercentage(perc)
-			}
-		}
+	copier, err := newSurveyCopy(tx, vo.Status, vo.RowCount)
+	if err != nil {
+		return rowsFailed(err)
+	}
 
-		re, err := json.Marshal(rowMap)
+	// rows are written as they are read so the survey is never held in memory
+	err = vo.Rows(func(rowData []string) error {
+		re, err := surveyColumns(columns, rowData)
 		if err != nil {
-			return fmt.Errorf("json marshall failed: %s", err)
+			return err
 		}
 
 		row := types.SurveyRow{
@@ -156,38 +86,32 @@ func (s Postgres) PersistSurvey(vo types.SurveyVO) error {
 			Week:       vo.Audit.Week,
 			Month:      vo.Audit.Month,
 			Year:       vo.Audit.Year,
-			Columns:    string(re),
+			VersionId:  version.VersionId,
+			Columns:    re,
 		}
 
-		if err := s.insertSurveyData(tx, row); err != nil {
-			_ = tx.Rollback()
-			vo.Audit.Status = types.UploadError
-			vo.Audit.Message = "Insert survey row failed"
-			_ = s.AuditFileUploadEvent(*vo.Audit)
-			log.Error().
-				Err(err).
-				Int("week", row.Week).
-				Int("month", row.Month).
-				Int("year", row.Year).
-				Msg("Cannot insert survey row")
-			return fmt.Errorf("cannot insert survey row, error: %s", err)
-		}
+		return copier.write(row)
+	})
+	if err != nil {
+		_ = copier.close()
+		return rowsFailed(err)
 	}
 
-	if existingDataset {
-		vo.Audit.Status = types.FileReloaded
-	} else {
-		vo.Audit.Status = types.UploadFinished
+	if err := copier.close(); err != nil {
+		return rowsFailed(err)
 	}
 
-	vo.Audit.Message = "File Uploaded"
+	if copier.written != vo.RowCount {
+		return rowsFailed(fmt.Errorf("%d rows written, expected %d", copier.written, vo.RowCount))
+	}
 
-	if err := s.AuditFileUploadEvent(*vo.Audit); err != nil {
+	if err := s.makeCurrentVersion(tx, version); err != nil {
+		_ = tx.Rollback()
 		log.Error().
 			Err(err).
-			Msg("Audit event failed")
-		_ = tx.Rollback()
-		return fmt.Errorf("audit event failed, error: %s", err)
+			Int("versionId", version.VersionId).
+			Msg("Cannot make survey version current")
+		return fmt.Errorf("cannot make survey version current, error: %s", err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -197,16 +121,167 @@ func (s Postgres) PersistSurvey(vo types.SurveyVO) error {
 		return fmt.Errorf("commit failed, error: %s", err)
 	}
 
+	// only a committed survey is finished; a failed one is reported by the caller
+	vo.Status.SetUploadFinished()
+
 	return nil
 }
 
-func (s Postgres) insertSurveyData(tx sqlbuilder.Tx, survey types.SurveyRow) error {
+/*
+Encode a row's values as the JSON held in the survey table's columns field. Missing values are left out.
+*/
+func surveyColumns(columns []types.Header, rowData []string) (string, error) {
+	var rowMap = make(map[string]*interface{})
+
+	for colNo, val := range rowData {
+
+		columnKind := columns[colNo].VariableType
+		switch columnKind {
+		case types.TypeString:
+			if val == "NULL" || val == "" {
+				continue
+				//rowMap[columns[colNo].Name] = nil
+			} else {
+				var ms interface{} = val
+				rowMap[columns[colNo].VariableName] = &ms
+			}
 
-	col := tx.Collection(surveyTable)
-	_, err := col.Insert(survey)
+		case types.TypeInt8, types.TypeInt16, types.TypeInt32:
+			if val == "" || val == "NULL" || strings.EqualFold(val, "NaN") {
+				continue
+			}
+			i64, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				log.Error().
+					Str("methodName", "PersistSurvey").
+					Str("type", string(columnKind)).
+					Msg("field is not an int")
+				return "", fmt.Errorf("field is not an int")
+			}
+			var ms interface{} = i64
+			if i64 == 0 {
+				continue
+				//ms = nil
+			}
+			rowMap[columns[colNo].VariableName] = &ms
+
+		case types.TypeFloat, types.TypeDouble:
+			if val == "" || val == "NULL" {
+				val = "0.0"
+			}
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				log.Error().
+					Str("methodName", "PersistSurvey").
+					Str("type", string(columnKind)).
+					Str("variable", columns[colNo].VariableName).
+					Str("value", val).
+					Int("index", colNo).
+					Msg("field is not a float")
+				return "", fmt.Errorf("field is not a float")
+			}
+			if math.IsNaN(f) {
+				//rowMap[columns[colNo].Name] = nil
+				continue
+			} else {
+				var ms interface{} = f
+				rowMap[columns[colNo].VariableName] = &ms
+			}
+
+		default:
+			log.Error().
+				Str("methodName", "PersistSurvey").
+				Str("type", string(columnKind)).
+				Msg("Unknown type - possible corruption or structure does not map to file")
+			return "", fmt.Errorf("unknown type - possible corruption or structure does not map to file")
+		}
+	}
+
+	re, err := json.Marshal(rowMap)
+	if err != nil {
+		return "", fmt.Errorf("json marshall failed: %s", err)
+	}
+
+	return string(re), nil
+}
+
+/*
+surveyCopy writes survey rows with COPY FROM STDIN, which is much faster than inserting a row at a time. Each
+COPY is ended after copyBatchSize rows so that progress can be reported as the rows are written.
+*/
+type surveyCopy struct {
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	status  *types.WSMessage
+	total   int
+	written int
+	inBatch int
+}
+
+func newSurveyCopy(tx sqlbuilder.Tx, status *types.WSMessage, total int) (*surveyCopy, error) {
+	sqlTx, ok := tx.Driver().(*sql.Tx)
+	if !ok {
+		return nil, fmt.Errorf("cannot copy survey rows, the transaction is not a database/sql transaction")
+	}
+
+	return &surveyCopy{tx: sqlTx, status: status, total: total}, nil
+}
+
+func (c *surveyCopy) write(row types.SurveyRow) error {
+	if c.stmt == nil {
+		stmt, err := c.tx.Prepare(pq.CopyIn(surveyTable,
+			"id", "file_name", "file_source", "week", "month", "year", "version_id", "columns"))
+		if err != nil {
+			return err
+		}
+		c.stmt = stmt
+	}
+
+	_, err := c.stmt.Exec(row.Id, row.FileName, string(row.FileSource), row.Week, row.Month, row.Year,
+		row.VersionId, row.Columns)
 	if err != nil {
 		return err
 	}
 
+	c.inBatch++
+	if c.inBatch == copyBatchSize {
+		return c.flush()
+	}
+
 	return nil
 }
+
+// end the current COPY, sending any buffered rows to the database
+func (c *surveyCopy) flush() error {
+	if c.stmt == nil {
+		return nil
+	}
+
+	stmt := c.stmt
+	c.stmt = nil
+
+	if _, err := stmt.Exec(); err != nil {
+		_ = stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	c.written += c.inBatch
+	c.inBatch = 0
+
+	if c.total > 0 && c.status != nil {
+		c.status.SetPercentage((float64(c.written) / float64(c.total)) * 100)
+	}
+
+	log.Debug().
+		Int("rowsWritten", c.written).
+		Msg("Copied survey rows")
+
+	return nil
+}
+
+func (c *surveyCopy) close() error {
+	return c.flush()
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"services/types"
+	"strings"
+)
+
+var surveyOperators = map[types.Operator]string{
+	types.Equal:          "=",
+	types.NotEqual:       "<>",
+	types.Less:           "<",
+	types.LessOrEqual:    "<=",
+	types.Greater:        ">",
+	types.GreaterOrEqual: ">=",
+}
+
+/*
+A variable of a survey row as a number. Only values stored as JSON numbers are cast, so a string held in a
+variable that is numeric elsewhere does not fail the query; it is null, as is a missing value.
+*/
+func numericColumn(variable string) (string, []interface{}) {
+	return "CASE WHEN jsonb_typeof(s.columns -> ?::text) = 'number' THEN (s.columns ->> ?::text)::numeric END",
+		[]interface{}{variable, variable}
+}
+
+/*
+The SQL of a condition on the columns of a survey row. Equality is a containment test, which can use the GIN
+index on the columns.
+*/
+func surveyCondition(c types.SurveyCondition) (string, []interface{}, error) {
+	op, ok := surveyOperators[c.Operator]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid operator: %s", c.Operator)
+	}
+
+	if c.Operator == types.Equal {
+		b, err := json.Marshal(map[string]interface{}{c.Variable: c.Value})
+		if err != nil {
+			return "", nil, err
+		}
+		return "s.columns @> ?::jsonb", []interface{}{string(b)}, nil
+	}
+
+	switch v := c.Value.(type) {
+	case float64:
+		column, args := numericColumn(c.Variable)
+		return fmt.Sprintf("%s %s ?::numeric", column, op), append(args, v), nil
+	case string:
+		return fmt.Sprintf("s.columns ->> ?::text %s ?::text", op), []interface{}{c.Variable, v}, nil
+	}
+
+	return "", nil, fmt.Errorf("invalid value for %s: %v", c.Variable, c.Value)
+}
+
+/*
+The columns returned for each row: the variables asked for that the row has, or all of them. The list of
+variables is expanded into one placeholder for each.
+*/
+func surveySelection(variables []string) (string, []interface{}) {
+	if len(variables) == 0 {
+		return "s.columns", nil
+	}
+
+	return "(SELECT coalesce(jsonb_object_agg(c.key, c.value), '{}'::jsonb) " +
+		"FROM jsonb_each(s.columns) c WHERE c.key IN ?)", []interface{}{variables}
+}
+
+/*
+The rows of a dataset for a period that meet every condition, and the order to read them in. The batch table of
+the dataset is joined as b. Rows are keyed in the order they were loaded, which orders the rows of a week or
+batch. Quarterly datasets can be read for a quarter or a year and annual datasets only for a year.
+*/
+func surveyFrom(dataset types.Dataset, period types.ExportPeriod, conditions []types.SurveyCondition) (string, string, []interface{}, error) {
+	var from, order string
+	var where []string
+	var args []interface{}
+
+	switch dataset {
+	case types.SurveyDataset, "":
+		from = fmt.Sprintf("%s s JOIN %s v ON v.version_id = s.version_id AND v.is_current JOIN %s b ON b.id = s.id",
+			surveyTable, surveyVersionTable, batchTable)
+		order = "b.month, s.file_source, s.week, s.row_id"
+		first, last := period.Months()
+		where = []string{"b.year = ?", "b.month BETWEEN ? AND ?"}
+		args = []interface{}{period.Year, first, last}
+
+	case types.UKDataset:
+		from = fmt.Sprintf("%s s JOIN %s b ON s.batch_type = ? AND b.id = s.batch_id", batchSurveyTable, batchTable)
+		order = "b.month, s.row_id"
+		first, last := period.Months()
+		where = []string{"b.year = ?", "b.month BETWEEN ? AND ?"}
+		args = []interface{}{string(types.MonthlyBatchType), period.Year, first, last}
+
+	case types.QuarterlyDataset:
+		if period.Month > 0 {
+			return "", "", nil, fmt.Errorf("the quarterly dataset is for a quarter or a year, not a month")
+		}
+		first, last := 1, 4
+		if period.Quarter > 0 {
+			first, last = period.Quarter, period.Quarter
+		}
+		from = fmt.Sprintf("%s s JOIN %s b ON s.batch_type = ? AND b.id = s.batch_id", batchSurveyTable, quarterlyBatchTable)
+		order = "b.quarter, s.row_id"
+		where = []string{"b.year = ?", "b.quarter BETWEEN ? AND ?"}
+		args = []interface{}{string(types.QuarterlyBatchType), period.Year, first, last}
+
+	case types.AnnualDataset:
+		if period.Month > 0 || period.Quarter > 0 {
+			return "", "", nil, fmt.Errorf("the annual dataset is for a year")
+		}
+		from = fmt.Sprintf("%s s JOIN %s b ON s.batch_type = ? AND b.id = s.batch_id", batchSurveyTable, annualBatchTable)
+		order = "s.row_id"
+		where = []string{"b.year = ?"}
+		args = []interface{}{string(types.AnnualBatchType), period.Year}
+
+	default:
+		return "", "", nil, fmt.Errorf("invalid dataset: %s", dataset)
+	}
+
+	for _, c := range conditions {
+		condition, conditionArgs, err := surveyCondition(c)
+		if err != nil {
+			return "", "", nil, err
+		}
+		where = append(where, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	return fmt.Sprintf("FROM %s WHERE %s", from, strings.Join(where, " AND ")), order, args, nil
+}
+
+/*
+QuerySurvey passes each row of a page of a survey query to emit as it is read, returning the key of the last
+row. Pages are read in key order from the key after the last page, so each page costs the same however far in
+it is and a row loaded while paging cannot move rows from one page to another.
+*/
+func (s Postgres) QuerySurvey(query types.SurveyQuery, emit func(columns map[string]interface{}) error) (int64, error) {
+
+	selection, args := surveySelection(query.Variables)
+
+	from, _, fromArgs, err := surveyFrom(query.Dataset, query.Period, query.Conditions)
+	if err != nil {
+		return 0, err
+	}
+	args = append(args, fromArgs...)
+
+	if query.BatchId > 0 {
+		from += " AND b.id = ?"
+		args = append(args, query.BatchId)
+	}
+
+	q := fmt.Sprintf("SELECT s.row_id, %s %s AND s.row_id > ? ORDER BY s.row_id LIMIT ?", selection, from)
+	args = append(args, query.After, query.Limit)
+
+	rows, err := s.DB.Query(q, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var last int64
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&last, &raw); err != nil {
+			return last, err
+		}
+
+		var columns map[string]interface{}
+		if err := json.Unmarshal(raw, &columns); err != nil {
+			return last, fmt.Errorf("cannot decode survey row: %s", err)
+		}
+
+		if err := emit(columns); err != nil {
+			return last, err
+		}
+	}
+
+	return last, rows.Err()
+}
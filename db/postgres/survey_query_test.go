@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"github.com/stretchr/testify/assert"
+	"services/types"
+	"testing"
+)
+
+func TestSurveyCondition(t *testing.T) {
+	sql, args, err := surveyCondition(types.SurveyCondition{Variable: "SEX", Operator: types.Equal, Value: 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "s.columns @> ?::jsonb", sql)
+	assert.Equal(t, []interface{}{`{"SEX":1}`}, args)
+
+	sql, args, err = surveyCondition(types.SurveyCondition{Variable: "AGE", Operator: types.GreaterOrEqual, Value: 16.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "CASE WHEN jsonb_typeof(s.columns -> ?::text) = 'number' THEN (s.columns ->> ?::text)::numeric END >= ?::numeric", sql)
+	assert.Equal(t, []interface{}{"AGE", "AGE", 16.0}, args)
+
+	sql, args, err = surveyCondition(types.SurveyCondition{Variable: "POSTCODE", Operator: types.Less, Value: "NP"})
+	assert.NoError(t, err)
+	assert.Equal(t, "s.columns ->> ?::text < ?::text", sql)
+	assert.Equal(t, []interface{}{"POSTCODE", "NP"}, args)
+
+	_, _, err = surveyCondition(types.SurveyCondition{Variable: "AGE", Operator: "~", Value: 16.0})
+	assert.Error(t, err, "an unknown operator")
+
+	_, _, err = surveyCondition(types.SurveyCondition{Variable: "AGE", Operator: types.Greater, Value: true})
+	assert.Error(t, err, "a value that is neither a number nor a string")
+}
+
+func TestSurveySelection(t *testing.T) {
+	sql, args := surveySelection(nil)
+	assert.Equal(t, "s.columns", sql)
+	assert.Empty(t, args)
+
+	sql, args = surveySelection([]string{"AGE", "SEX"})
+	assert.Contains(t, sql, "FROM jsonb_each(s.columns) c WHERE c.key IN ?")
+	assert.Equal(t, []interface{}{[]string{"AGE", "SEX"}}, args)
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"services/types"
+)
+
+// a value of a grouping variable, nil if it is missing
+func tabulationKey(raw []byte) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("cannot decode tabulation value: %s", err)
+	}
+	return v, nil
+}
+
+/*
+TabulateSurvey counts the rows of a tabulation in each cell, weighted and unweighted, and takes the weighted sum
+and mean of its value variable. Cells are in order of their row and column values, with missing values last.
+*/
+func (s Postgres) TabulateSurvey(t types.SurveyTabulation) ([]types.TabulationCell, error) {
+
+	args := []interface{}{t.Rows}
+
+	columns := "NULL::jsonb"
+	if t.Columns != "" {
+		columns = "s.columns -> ?::text"
+		args = append(args, t.Columns)
+	}
+
+	weight := "1::numeric"
+	if t.Weight != "" {
+		var weightArgs []interface{}
+		weight, weightArgs = numericColumn(t.Weight)
+		args = append(args, weightArgs...)
+	}
+
+	value := "NULL::numeric"
+	if t.Value != "" {
+		var valueArgs []interface{}
+		value, valueArgs = numericColumn(t.Value)
+		args = append(args, valueArgs...)
+	}
+
+	from, _, fromArgs, err := surveyFrom(t.Dataset, t.Period, t.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, fromArgs...)
+
+	// the weight and value of each row are taken once, before they are summed
+	q := fmt.Sprintf("SELECT r, c, count(*), coalesce(sum(w), 0), sum(w * x), "+
+		"sum(w * x) / nullif(sum(CASE WHEN x IS NOT NULL THEN w END), 0) "+
+		"FROM (SELECT s.columns -> ?::text AS r, %s AS c, %s AS w, %s AS x %s) t "+
+		"GROUP BY r, c ORDER BY r, c", columns, weight, value, from)
+
+	rows, err := s.DB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var cells []types.TabulationCell
+
+	for rows.Next() {
+		var row, column []byte
+		var sum, mean sql.NullFloat64
+		var cell types.TabulationCell
+
+		if err := rows.Scan(&row, &column, &cell.Count, &cell.WeightedCount, &sum, &mean); err != nil {
+			return nil, err
+		}
+
+		if cell.Row, err = tabulationKey(row); err != nil {
+			return nil, err
+		}
+		if cell.Column, err = tabulationKey(column); err != nil {
+			return nil, err
+		}
+		if sum.Valid {
+			cell.WeightedSum = &sum.Float64
+		}
+		if mean.Valid {
+			cell.WeightedMean = &mean.Float64
+		}
+
+		cells = append(cells, cell)
+	}
+
+	return cells, rows.Err()
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"fmt"
+	"services/config"
+	"services/types"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var surveyVersionTable string
+
+func init() {
+	surveyVersionTable = config.Config.Database.SurveyVersionTable
+	if surveyVersionTable == "" {
+		panic("survey version table configuration not set")
+	}
+}
+
+// a GB week or NI month
+func period(source types.FileSource, year, month, week int) db.Cond {
+	return db.Cond{"file_source": source, "year": year, "month": month, "week": week}
+}
+
+/*
+Create the next version of the audit's period and record the load in the audit table. The version is not
+current until makeCurrentVersion is called.
+*/
+func (s Postgres) newSurveyVersion(tx sqlbuilder.Tx, audit *types.Audit, rows int) (types.SurveyVersion, error) {
+
+	var latest struct {
+		Version int `db:"version"`
+	}
+
+	q := fmt.Sprintf("SELECT coalesce(max(version), 0) AS version FROM %s "+
+		"WHERE file_source = ? AND year = ? AND month = ? AND week = ?", surveyVersionTable)
+
+	r, err := tx.Query(q, audit.FileSource, audit.Year, audit.Month, audit.Week)
+	if err != nil {
+		return types.SurveyVersion{}, err
+	}
+	if err := sqlbuilder.NewIterator(r).One(&latest); err != nil {
+		return types.SurveyVersion{}, err
+	}
+
+	if latest.Version > 0 {
+		audit.Status = types.FileReloaded
+	} else {
+		audit.Status = types.FileUploaded
+	}
+	audit.Message = fmt.Sprintf("File Uploaded as version %d", latest.Version+1)
+
+	audit.AuditId = 0
+	if err := tx.Collection(surveyAuditTable).InsertReturning(audit); err != nil {
+		return types.SurveyVersion{}, fmt.Errorf("audit event failed, error: %s", err)
+	}
+
+	version := types.SurveyVersion{
+		Id:         audit.Id,
+		FileName:   audit.FileName,
+		FileSource: audit.FileSource,
+		Week:       audit.Week,
+		Month:      audit.Month,
+		Year:       audit.Year,
+		Version:    latest.Version + 1,
+		Rows:       rows,
+		AuditId:    audit.AuditId,
+		LoadedAt:   time.Now(),
+	}
+
+	if err := tx.Collection(surveyVersionTable).InsertReturning(&version); err != nil {
+		return types.SurveyVersion{}, err
+	}
+
+	return version, nil
+}
+
+/*
+Make a version the current one for its period and remove versions beyond the number configured to be kept
+*/
+func (s Postgres) makeCurrentVersion(tx sqlbuilder.Tx, version types.SurveyVersion) error {
+
+	p := period(version.FileSource, version.Year, version.Month, version.Week)
+
+	// the previous version must be cleared first as only one version of a period can be current
+	_, err := tx.Update(surveyVersionTable).
+		Set("is_current", false).
+		Where(p, db.Cond{"is_current": true}).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Update(surveyVersionTable).
+		Set("is_current", true).
+		Where(db.Cond{"version_id": version.VersionId}).
+		Exec()
+	if err != nil {
+		return err
+	}
+
+	kept := config.Config.Database.SurveyVersionsKept
+	if kept <= 0 {
+		return nil
+	}
+
+	// survey rows are removed with their version
+	_, err = tx.DeleteFrom(surveyVersionTable).
+		Where(p, db.Cond{"is_current": false, "version <=": version.Version - kept}).
+		Exec()
+
+	return err
+}
+
+func (s Postgres) GetSurveyVersions(source types.FileSource, year, period int) ([]types.SurveyVersion, error) {
+	var versions []types.SurveyVersion
+
+	cond := db.Cond{"file_source": source, "year": year}
+	if source == types.GBSource {
+		cond["week"] = period
+	} else {
+		cond["month"] = period
+	}
+
+	res := s.DB.Collection(surveyVersionTable).Find(cond).OrderBy("-version")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&versions); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (s Postgres) GetSurveyVersion(versionId int) (types.SurveyVersion, error) {
+	var version types.SurveyVersion
+
+	res := s.DB.Collection(surveyVersionTable).Find(db.Cond{"version_id": versionId})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&version); err != nil {
+		if err == db.ErrNoMoreRows {
+			return version, fmt.Errorf("version %d not found", versionId)
+		}
+		return version, err
+	}
+
+	return version, nil
+}
+
+/*
+DiffSurveyVersions compares the rows of two versions of the same period, matching respondents on CASENO
+*/
+func (s Postgres) DiffSurveyVersions(from, to int) (types.SurveyVersionDiff, error) {
+	var diff types.SurveyVersionDiff
+	var err error
+
+	if diff.From, err = s.GetSurveyVersion(from); err != nil {
+		return diff, err
+	}
+	if diff.To, err = s.GetSurveyVersion(to); err != nil {
+		return diff, err
+	}
+
+	if diff.From.FileSource != diff.To.FileSource || diff.From.Year != diff.To.Year ||
+		diff.From.Month != diff.To.Month || diff.From.Week != diff.To.Week {
+		return diff, fmt.Errorf("versions %d and %d are not for the same period", from, to)
+	}
+
+	var counts struct {
+		Added   int `db:"rows_added"`
+		Removed int `db:"rows_removed"`
+		InBoth  int `db:"rows_in_both"`
+	}
+
+	q := fmt.Sprintf(`
+		WITH f AS (SELECT columns->>'CASENO' AS caseno FROM %[1]s WHERE version_id = ?),
+		     t AS (SELECT columns->>'CASENO' AS caseno FROM %[1]s WHERE version_id = ?)
+		SELECT
+			(SELECT count(*) FROM (SELECT caseno FROM t EXCEPT SELECT caseno FROM f) a) AS rows_added,
+			(SELECT count(*) FROM (SELECT caseno FROM f EXCEPT SELECT caseno FROM t) r) AS rows_removed,
+			(SELECT count(*) FROM (SELECT caseno FROM f INTERSECT SELECT caseno FROM t) b) AS rows_in_both`,
+		surveyTable)
+
+	r, err := s.DB.Query(q, from, to)
+	if err != nil {
+		return diff, err
+	}
+	if err := sqlbuilder.NewIterator(r).One(&counts); err != nil {
+		return diff, err
+	}
+
+	diff.RowsAdded = counts.Added
+	diff.RowsRemoved = counts.Removed
+	diff.RowsInBoth = counts.InBoth
+	diff.RowDifference = diff.To.Rows - diff.From.Rows
+
+	return diff, nil
+}
+
+/*
+RollbackSurveyVersion makes an earlier version of a period current again. The rollback is recorded in the
+audit table against the user who made it.
+*/
+func (s Postgres) RollbackSurveyVersion(versionId int, user string) (types.SurveyVersion, error) {
+
+	version, err := s.GetSurveyVersion(versionId)
+	if err != nil {
+		return version, err
+	}
+
+	if version.IsCurrent {
+		return version, fmt.Errorf("version %d is already the current version", versionId)
+	}
+
+	err = s.inTx(func(tx sqlbuilder.Tx) error {
+		if err := s.makeCurrentVersion(tx, version); err != nil {
+			return fmt.Errorf("cannot roll back to version %d, error: %s", versionId, err)
+		}
+
+		audit := types.Audit{
+			Id:            version.Id,
+			FileName:      version.FileName,
+			FileSource:    version.FileSource,
+			Week:          version.Week,
+			Month:         version.Month,
+			Year:          version.Year,
+			ReferenceDate: time.Now(),
+			NumObFile:     version.Rows,
+			NumObLoaded:   version.Rows,
+			Status:        types.RolledBack,
+			Message:       fmt.Sprintf("Rolled back to version %d", version.Version),
+			Username:      user,
+		}
+
+		if _, err := tx.Collection(surveyAuditTable).Insert(audit); err != nil {
+			return fmt.Errorf("audit event failed, error: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return version, err
+	}
+
+	version.IsCurrent = true
+	return version, nil
+}
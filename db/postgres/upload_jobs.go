@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"services/config"
+	"services/types"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var uploadJobsTable string
+
+func init() {
+	uploadJobsTable = config.Config.Database.UploadJobsTable
+	if uploadJobsTable == "" {
+		panic("upload jobs table configuration not set")
+	}
+}
+
+func (s Postgres) CreateUploadJob(job types.UploadJob) (types.UploadJob, error) {
+
+	job.Status = types.JobQueued
+	job.Attempts = 0
+	job.CreatedAt = time.Now()
+
+	if err := s.DB.Collection(uploadJobsTable).InsertReturning(&job); err != nil {
+		log.Error().
+			Err(err).
+			Str("fileName", job.FileName).
+			Msg("Cannot create upload job")
+		return types.UploadJob{}, fmt.Errorf("cannot create upload job, error: %s", err)
+	}
+
+	return job, nil
+}
+
+func (s Postgres) GetUploadJob(id int) (types.UploadJob, error) {
+	var job types.UploadJob
+
+	res := s.DB.Collection(uploadJobsTable).Find(db.Cond{"id": id})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&job); err != nil {
+		if err == db.ErrNoMoreRows {
+			return job, fmt.Errorf("job %d not found", id)
+		}
+		return job, err
+	}
+
+	return job, nil
+}
+
+func (s Postgres) GetUploadJobs() ([]types.UploadJob, error) {
+	var jobs []types.UploadJob
+
+	res := s.DB.Collection(uploadJobsTable).Find().OrderBy("-id")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// a second running job of the same type and period, refused by the upload_jobs_running_idx index
+func runningJobConflict(err error) bool {
+	e, ok := err.(*pq.Error)
+	return ok && e.Code == "23505" && e.Constraint == "upload_jobs_running_idx"
+}
+
+// ClaimUploadJob marks the oldest queued job as running and returns it. Jobs of the same type and period
+// as one that is already running are left in the queue so that, for example, two reloads of the same
+// GB week cannot overwrite each other. Two workers can pick jobs for the same period at once, as neither
+// sees the other's claim until it commits; the unique index on running jobs refuses the second, which
+// then looks again.
+func (s Postgres) ClaimUploadJob() (types.UploadJob, bool, error) {
+	for {
+		job, found, err := s.claimUploadJob()
+		if runningJobConflict(err) {
+			continue
+		}
+		return job, found, err
+	}
+}
+
+func (s Postgres) claimUploadJob() (types.UploadJob, bool, error) {
+	var job types.UploadJob
+
+	q := fmt.Sprintf(`
+		UPDATE %[1]s SET status = ?, attempts = attempts + 1, started_at = ?, heartbeat = ?,
+			finished_at = NULL, error_message = '', result = NULL
+		WHERE id = (
+			SELECT j.id FROM %[1]s j
+			WHERE j.status = ?
+			AND NOT EXISTS (
+				SELECT 1 FROM %[1]s r
+				WHERE r.status = ? AND r.job_type = j.job_type
+				AND r.year = j.year AND r.month = j.month AND r.week = j.week
+			)
+			ORDER BY j.id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *`, uploadJobsTable)
+
+	now := time.Now()
+	rows, err := s.DB.Query(q, types.JobRunning, now, now, types.JobQueued, types.JobRunning)
+	if err != nil {
+		return job, false, err
+	}
+
+	err = sqlbuilder.NewIterator(rows).One(&job)
+	if err == db.ErrNoMoreRows {
+		return job, false, nil
+	}
+	if err != nil {
+		return job, false, err
+	}
+
+	return job, true, nil
+}
+
+func (s Postgres) FinishUploadJob(id int, status types.JobStatus, message string) error {
+	_, err := s.DB.Update(uploadJobsTable).
+		Set("status", status, "error_message", message, "finished_at", time.Now()).
+		Where(db.Cond{"id": id}).
+		Exec()
+	return err
+}
+
+// ChangeUploadJobStatus moves a job to a new status only if it is currently in one of the from states.
+// It returns false if the job was not in one of those states.
+func (s Postgres) ChangeUploadJobStatus(id int, to types.JobStatus, from ...types.JobStatus) (bool, error) {
+	res, err := s.DB.Update(uploadJobsTable).
+		Set("status", to, "error_message", "").
+		Where(db.Cond{"id": id, "status IN": from}).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RetryUploadJob puts a failed job back on the queue with its attempts cleared. It returns false if the job
+// had not failed.
+func (s Postgres) RetryUploadJob(id int) (bool, error) {
+	res, err := s.DB.Update(uploadJobsTable).
+		Set("status", types.JobQueued, "attempts", 0, "error_message", "").
+		Where(db.Cond{"id": id, "status": types.JobFailed}).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// SetUploadJobResult stores what a job returned, to be read with the job
+func (s Postgres) SetUploadJobResult(id int, result interface{}) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("cannot encode the result of job %d: %s", id, err)
+	}
+
+	_, err = s.DB.Update(uploadJobsTable).
+		Set("result", string(b)).
+		Where(db.Cond{"id": id}).
+		Exec()
+	return err
+}
+
+func (s Postgres) TouchUploadJob(id int) error {
+	_, err := s.DB.Update(uploadJobsTable).
+		Set("heartbeat", time.Now()).
+		Where(db.Cond{"id": id}).
+		Exec()
+	return err
+}
+
+// RequeueStaleUploadJobs puts running jobs whose worker has stopped sending heartbeats, typically because
+// the service was restarted mid-upload, back on the queue. Jobs that have already used maxAttempts are
+// failed instead.
+func (s Postgres) RequeueStaleUploadJobs(staleAfter time.Duration, maxAttempts int) (int, error) {
+
+	cutOff := time.Now().Add(-staleAfter)
+	stale := db.Cond{"status": types.JobRunning, "heartbeat <": cutOff}
+
+	_, err := s.DB.Update(uploadJobsTable).
+		Set("status", types.JobFailed,
+			"error_message", "job abandoned after the maximum number of attempts",
+			"finished_at", time.Now()).
+		Where(stale, db.Cond{"attempts >=": maxAttempts}).
+		Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.DB.Update(uploadJobsTable).
+		Set("status", types.JobQueued).
+		Where(stale).
+		Exec()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
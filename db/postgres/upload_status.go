@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"services/config"
+	"services/types"
+	"time"
+	"upper.io/db.v3"
+	"upper.io/db.v3/postgresql"
+)
+
+var uploadStatusTable string
+
+func init() {
+	uploadStatusTable = config.Config.Database.UploadStatusTable
+	if uploadStatusTable == "" {
+		panic("upload status table configuration not set")
+	}
+}
+
+func (s Postgres) PersistUploadStatus(status types.WSMessage) error {
+	q := fmt.Sprintf(`
+		INSERT INTO %s (job_id, file_name, percentage, status, error_message, rows_in_file, rows_loaded, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, now())
+		ON CONFLICT (job_id) DO UPDATE SET
+			file_name = excluded.file_name,
+			percentage = excluded.percentage,
+			status = excluded.status,
+			error_message = excluded.error_message,
+			rows_in_file = excluded.rows_in_file,
+			rows_loaded = excluded.rows_loaded,
+			updated_at = excluded.updated_at`, uploadStatusTable)
+
+	_, err := s.DB.Exec(q, status.JobId, status.Filename, status.Percentage, status.Status, status.ErrorMessage,
+		status.RowsInFile, status.RowsLoaded)
+	return err
+}
+
+// maximum size of a notification payload is 8000 bytes so long error messages are cut short
+const maxNotifyErrorMessage = 1024
+
+// NotifyUploadStatus publishes a status change to every instance listening through ListenUploadStatus
+func (s Postgres) NotifyUploadStatus(status types.WSMessage) error {
+	if len(status.ErrorMessage) > maxNotifyErrorMessage {
+		status.ErrorMessage = status.ErrorMessage[:maxNotifyErrorMessage]
+	}
+
+	payload, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec("SELECT pg_notify(?, ?)", uploadStatusTable, string(payload))
+	return err
+}
+
+// ListenUploadStatus calls received for every status change published by NotifyUploadStatus, on any instance.
+// The listener uses its own connection and reconnects if that connection is lost.
+func (s Postgres) ListenUploadStatus(received func(types.WSMessage)) error {
+
+	var settings = postgresql.ConnectionURL{
+		Database: config.Config.Database.Database,
+		Host:     config.Config.Database.Server,
+		User:     config.Config.Database.User,
+		Password: config.Config.Database.Password,
+	}
+
+	listener := pq.NewListener(settings.String(), 10*time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Msg("Upload status listener connection problem")
+			}
+		})
+
+	if err := listener.Listen(uploadStatusTable); err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil is sent after the connection has been re-established
+				if n == nil {
+					continue
+				}
+				var status types.WSMessage
+				if err := json.Unmarshal([]byte(n.Extra), &status); err != nil {
+					log.Warn().
+						Err(err).
+						Msg("Cannot decode upload status notification")
+					continue
+				}
+				received(status)
+
+			case <-time.After(90 * time.Second):
+				go func() { _ = listener.Ping() }()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// GetUploadStatus returns the status of the upload job jobId
+func (s Postgres) GetUploadStatus(jobId int) (types.WSMessage, error) {
+	var status types.WSMessage
+
+	res := s.DB.Collection(uploadStatusTable).Find(db.Cond{"job_id": jobId})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&status); err != nil {
+		if err == db.ErrNoMoreRows {
+			return status, fmt.Errorf("upload job %d not found", jobId)
+		}
+		return status, err
+	}
+
+	return status, nil
+}
+
+// GetLatestUploadStatus returns the status of the most recent upload job of a file
+func (s Postgres) GetLatestUploadStatus(fileName string) (types.WSMessage, error) {
+	var status types.WSMessage
+
+	res := s.DB.Collection(uploadStatusTable).Find(db.Cond{"file_name": fileName}).OrderBy("-job_id").Limit(1)
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&status); err != nil {
+		if err == db.ErrNoMoreRows {
+			return status, fmt.Errorf("upload %s not found", fileName)
+		}
+		return status, err
+	}
+
+	return status, nil
+}
@@ -1,19 +1,28 @@
 package postgres
 
 import (
+	"database/sql"
 	"fmt"
 	"services/config"
 	"services/types"
+	"time"
 	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 var userTable string
+var userRolesTable string
 
 func init() {
 	userTable = config.Config.Database.UserTable
 	if userTable == "" {
 		panic("user table configuration not set")
 	}
+
+	userRolesTable = config.Config.Database.UserRolesTable
+	if userRolesTable == "" {
+		panic("user roles table configuration not set")
+	}
 }
 
 func (s Postgres) GetUserID(user string) (types.UserCredentials, error) {
@@ -35,3 +44,219 @@ func (s Postgres) GetUserID(user string) (types.UserCredentials, error) {
 	}
 	return creds, nil
 }
+
+// GetUserRoles returns the roles of a user, in name order
+func (s Postgres) GetUserRoles(user string) ([]types.Role, error) {
+	var rows []struct {
+		Role types.Role `db:"role"`
+	}
+
+	res := s.DB.Collection(userRolesTable).Find(db.Cond{"username": user}).OrderBy("role")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&rows); err != nil {
+		return nil, fmt.Errorf("cannot get roles of user %s, error: %s", user, err)
+	}
+
+	roles := make([]types.Role, len(rows))
+	for i, r := range rows {
+		roles[i] = r.Role
+	}
+
+	return roles, nil
+}
+
+// GetUsers returns every user with their roles, in name order
+func (s Postgres) GetUsers() ([]types.User, error) {
+	var users []types.User
+
+	res := s.DB.Collection(userTable).Find().OrderBy("username")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&users); err != nil {
+		return nil, fmt.Errorf("cannot get users, error: %s", err)
+	}
+
+	var roles []struct {
+		Username string     `db:"username"`
+		Role     types.Role `db:"role"`
+	}
+	rolesRes := s.DB.Collection(userRolesTable).Find().OrderBy("role")
+	defer func() { _ = rolesRes.Close() }()
+
+	if err := rolesRes.All(&roles); err != nil {
+		return nil, fmt.Errorf("cannot get user roles, error: %s", err)
+	}
+
+	byUser := make(map[string][]types.Role)
+	for _, r := range roles {
+		byUser[r.Username] = append(byUser[r.Username], r.Role)
+	}
+	for i := range users {
+		users[i].Roles = byUser[users[i].Username]
+	}
+
+	return users, nil
+}
+
+// GetUser returns a user with their roles
+func (s Postgres) GetUser(username string) (types.User, error) {
+	var user types.User
+
+	res := s.DB.Collection(userTable).Find(db.Cond{"username": username})
+	defer func() { _ = res.Close() }()
+
+	if err := res.One(&user); err != nil {
+		if err == db.ErrNoMoreRows {
+			return user, fmt.Errorf("user %s not found", username)
+		}
+		return user, err
+	}
+
+	roles, err := s.GetUserRoles(username)
+	if err != nil {
+		return user, err
+	}
+	user.Roles = roles
+
+	return user, nil
+}
+
+func setUserRoles(tx sqlbuilder.Tx, username string, roles []types.Role) error {
+	if _, err := tx.DeleteFrom(userRolesTable).Where("username = ?", username).Exec(); err != nil {
+		return fmt.Errorf("delete from %s failed, error: %s", userRolesTable, err)
+	}
+
+	col := tx.Collection(userRolesTable)
+	for _, r := range roles {
+		if _, err := col.Insert(map[string]interface{}{"username": username, "role": string(r)}); err != nil {
+			return fmt.Errorf("insert into %s failed, error: %s", userRolesTable, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateUser adds a user with the hash of their password and their roles
+func (s Postgres) CreateUser(username, passwordHash string, roles []types.Role) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		n, err := tx.Collection(userTable).Find(db.Cond{"username": username}).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("user %s already exists", username)
+		}
+
+		now := time.Now().UTC()
+		user := map[string]interface{}{
+			"username":            username,
+			"password":            passwordHash,
+			"disabled":            false,
+			"created_at":          now,
+			"password_changed_at": now,
+		}
+		if _, err := tx.Collection(userTable).Insert(user); err != nil {
+			return fmt.Errorf("insert into %s failed, error: %s", userTable, err)
+		}
+
+		return setUserRoles(tx, username, roles)
+	})
+}
+
+// SetUserRoles replaces the roles of a user
+func (s Postgres) SetUserRoles(username string, roles []types.Role) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		n, err := tx.Collection(userTable).Find(db.Cond{"username": username}).Count()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("user %s not found", username)
+		}
+
+		return setUserRoles(tx, username, roles)
+	})
+}
+
+// SetUserPassword replaces the password hash of a user
+func (s Postgres) SetUserPassword(username, passwordHash string) error {
+	res, err := s.DB.Update(userTable).
+		Set("password", passwordHash, "password_changed_at", time.Now().UTC()).
+		Where("username = ?", username).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("update %s failed, error: %s", userTable, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %s not found", username)
+	}
+
+	return nil
+}
+
+/*
+SetUserDisabled disables or enables a user. Disabling a user ends their sessions.
+*/
+func (s Postgres) SetUserDisabled(username string, disabled bool) error {
+	return s.inTx(func(tx sqlbuilder.Tx) error {
+		res, err := tx.Update(userTable).Set("disabled", disabled).Where("username = ?", username).Exec()
+		if err != nil {
+			return fmt.Errorf("update %s failed, error: %s", userTable, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("user %s not found", username)
+		}
+
+		if disabled {
+			if _, err := tx.DeleteFrom(sessionTable).Where("username = ?", username).Exec(); err != nil {
+				return fmt.Errorf("delete from %s failed, error: %s", sessionTable, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+/*
+RecordLoginFailure counts a failed login of a user, locking the account once maxFailures have failed in a row.
+Whether the account is now locked is returned.
+*/
+func (s Postgres) RecordLoginFailure(username string, maxFailures int) (bool, error) {
+	q := fmt.Sprintf("UPDATE %s SET failed_logins = failed_logins + 1, "+
+		"locked_at = CASE WHEN failed_logins + 1 >= ? THEN coalesce(locked_at, ?) ELSE locked_at END "+
+		"WHERE username = ? RETURNING locked_at IS NOT NULL", userTable)
+
+	row, err := s.DB.QueryRow(q, maxFailures, time.Now().UTC(), username)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	if err := row.Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("update %s failed, error: %s", userTable, err)
+	}
+
+	return locked, nil
+}
+
+// UnlockUser clears the failed logins of a user and unlocks their account
+func (s Postgres) UnlockUser(username string) error {
+	res, err := s.DB.Update(userTable).
+		Set("failed_logins", 0, "locked_at", nil).
+		Where("username = ?", username).
+		Exec()
+	if err != nil {
+		return fmt.Errorf("update %s failed, error: %s", userTable, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %s not found", username)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"services/config"
+	"services/types"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var validationTable string
+
+func init() {
+	validationTable = config.Config.Database.ValidationTable
+	if validationTable == "" {
+		panic("validation table configuration not set")
+	}
+}
+
+/*
+AuditValidationFailure records a failed upload in the audit table along with every validation failure found,
+returning the id of the new audit record.
+*/
+func (s Postgres) AuditValidationFailure(audit types.Audit, failures []types.ValidationFailure) (int, error) {
+
+	err := s.inTx(func(tx sqlbuilder.Tx) error {
+		audit.AuditId = 0
+		if err := tx.Collection(surveyAuditTable).InsertReturning(&audit); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Audit event failed")
+			return fmt.Errorf("audit event failed, error: %s", err)
+		}
+
+		batch := tx.InsertInto(validationTable).Batch(BatchSize)
+
+		go func() {
+			defer batch.Done()
+			for _, f := range failures {
+				f.AuditId = audit.AuditId
+				batch.Values(f)
+			}
+		}()
+
+		if err := batch.Wait(); err != nil {
+			log.Error().
+				Err(err).
+				Int("auditId", audit.AuditId).
+				Msg("Cannot insert validation report")
+			return fmt.Errorf("cannot insert validation report, error: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return audit.AuditId, nil
+}
+
+func (s Postgres) GetValidationReport(auditId int) ([]types.ValidationFailure, error) {
+	var failures []types.ValidationFailure
+
+	res := s.DB.Collection(validationTable).Find(db.Cond{"audit_id": auditId}).OrderBy("id")
+	defer func() { _ = res.Close() }()
+
+	if err := res.All(&failures); err != nil {
+		return nil, err
+	}
+
+	return failures, nil
+}
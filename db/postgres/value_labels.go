@@ -71,7 +71,7 @@ func (s Postgres) GetAllValueLabels() ([]types.ValueLabelsView, error) {
 	return valueLabels, nil
 }
 
-func (s Postgres) getAllValueLabelsRow() ([]types.ValueLabelsRow, error) {
+func (s Postgres) GetAllValueLabelsRows() ([]types.ValueLabelsRow, error) {
 
 	var valueLabels []types.ValueLabelsRow
 	res := s.DB.Collection(valueLabelsTable).Find()
@@ -96,7 +96,7 @@ func (s Postgres) GetLabelsForValue(value string) ([]types.ValueLabelsView, erro
 	return values, nil
 }
 
-func (s Postgres) PersistSavValueLabels(items map[string][]types.Labels, source types.FileSource) error {
+func (s Postgres) PersistSavValueLabels(items map[string][]types.Labels, source types.FileSource, user string) error {
 
 	var all = make([]types.ValueLabelsRow, 0, len(items))
 
@@ -110,6 +110,7 @@ func (s Postgres) PersistSavValueLabels(items map[string][]types.Labels, source
 				Source:       string(source),
 				VariableType: j.VariableType,
 				LastUpdated:  time.Now(),
+				UpdatedBy:    user,
 			}
 
 			switch j.VariableType {
@@ -141,7 +142,7 @@ func (s Postgres) PersistValueLabels(data []types.ValueLabelsRow) error {
 	// get existing items
 	var all []types.ValueLabelsRow
 	var err error
-	all, err = s.getAllValueLabelsRow()
+	all, err = s.GetAllValueLabelsRows()
 
 	if err != nil {
 		return err
@@ -165,6 +166,7 @@ func (s Postgres) PersistValueLabels(data []types.ValueLabelsRow) error {
 				VariableType: v.VariableType,
 				Value:        v.Value,
 				LastUpdated:  v.LastUpdated,
+				UpdatedBy:    v.UpdatedBy,
 			}
 			changes = append(changes, r)
 		}
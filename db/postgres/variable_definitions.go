@@ -78,6 +78,7 @@ func (s Postgres) GetAllDefinitions() ([]types.VariableDefinitionsQuery, error)
 			Imputation:     v.Imputation,
 			DV:             v.DV,
 			ValidFrom:      v.ValidFrom,
+			ChangedBy:      v.ChangedBy,
 		}
 		d = append(d, r)
 	}
@@ -110,6 +111,7 @@ func (s Postgres) GetDefinitionsForVariable(variable string) ([]types.VariableDe
 			Imputation:     v.Imputation,
 			DV:             v.DV,
 			ValidFrom:      v.ValidFrom,
+			ChangedBy:      v.ChangedBy,
 		}
 		d = append(d, r)
 	}
@@ -121,7 +123,7 @@ func (s Postgres) GetDefinitionsForVariable(variable string) ([]types.VariableDe
 /* persist any new variable definitions.
 New is defined as any changes to the description
 */
-func (s Postgres) PersistVariableDefinitions(header []types.Header, source types.FileSource) error {
+func (s Postgres) PersistVariableDefinitions(header []types.Header, source types.FileSource, user string) error {
 
 	// get existing items
 	var all []types.VariableDefinitions
@@ -160,6 +162,7 @@ func (s Postgres) PersistVariableDefinitions(header []types.Header, source types
 				Editable:       false,
 				Imputation:     false,
 				DV:             false,
+				ChangedBy:      user,
 			}
 			changes = append(changes, r)
 		}
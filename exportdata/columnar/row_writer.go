@@ -0,0 +1,256 @@
+package columnar
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/compress"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"io"
+	"os"
+	"services/types"
+	"strconv"
+)
+
+// the number of rows in each record batch, which is a row group in a Parquet file
+const batchSize = 64 * 1024
+
+// the metadata keys of each column, and of the file for the value labels of every column
+const (
+	descriptionKey = "description"
+	valueLabelsKey = "value_labels"
+)
+
+type recordWriter interface {
+	Write(record arrow.Record) error
+	Close() error
+}
+
+/*
+RowWriter writes a dataset to a columnar file, Parquet or Arrow IPC, in batches of rows. Each variable is a
+typed column and its description and value labels are kept in the column's metadata.
+*/
+type RowWriter struct {
+	file      *os.File
+	newWriter func(schema *arrow.Schema, file *os.File) (recordWriter, error)
+	mem       memory.Allocator
+	labels    map[string][]types.Labels
+	writer    recordWriter
+	builder   *array.RecordBuilder
+	rows      int
+}
+
+func newRowWriter(fileName string, newWriter func(*arrow.Schema, *os.File) (recordWriter, error)) (*RowWriter, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file: %s, err: %w", fileName, err)
+	}
+	return &RowWriter{file: file, newWriter: newWriter, mem: memory.NewGoAllocator()}, nil
+}
+
+// the Parquet writer closes its output, but the file is closed by the RowWriter
+type writerOnly struct {
+	io.Writer
+}
+
+func NewParquetWriter(fileName string) (*RowWriter, error) {
+	return newRowWriter(fileName, func(schema *arrow.Schema, file *os.File) (recordWriter, error) {
+		props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+		// the arrow schema is stored so that readers get back the column types and metadata
+		arrowProps := pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema())
+		return pqarrow.NewFileWriter(schema, writerOnly{file}, props, arrowProps)
+	})
+}
+
+func NewArrowWriter(fileName string) (*RowWriter, error) {
+	return newRowWriter(fileName, func(schema *arrow.Schema, file *os.File) (recordWriter, error) {
+		return ipc.NewFileWriter(file, ipc.WithSchema(schema))
+	})
+}
+
+func (c *RowWriter) WriteLabels(labels map[string][]types.Labels) error {
+	c.labels = labels
+	return nil
+}
+
+func columnType(t types.SavType) arrow.DataType {
+	switch t {
+	case types.TypeString:
+		return arrow.BinaryTypes.String
+	case types.TypeInt8:
+		return arrow.PrimitiveTypes.Int8
+	case types.TypeInt16:
+		return arrow.PrimitiveTypes.Int16
+	case types.TypeInt32:
+		return arrow.PrimitiveTypes.Int32
+	case types.TypeFloat:
+		return arrow.PrimitiveTypes.Float32
+	}
+	return arrow.PrimitiveTypes.Float64
+}
+
+// value labels as a map of value to label
+func labelMap(labels []types.Labels) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, l := range labels {
+		switch v := l.Value.(type) {
+		case float64:
+			m[strconv.FormatFloat(v, 'f', -1, 64)] = l.Label
+		default:
+			m[fmt.Sprint(v)] = l.Label
+		}
+	}
+	return m
+}
+
+func (c *RowWriter) WriteHeader(header []types.Header) error {
+	fields := make([]arrow.Field, len(header))
+	allLabels := make(map[string]map[string]string)
+
+	for i, h := range header {
+		keys := []string{descriptionKey}
+		values := []string{h.VariableDescription}
+
+		if labels, ok := c.labels[h.LabelName]; ok && h.LabelName != "" {
+			m := labelMap(labels)
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, valueLabelsKey)
+			values = append(values, string(b))
+			allLabels[h.VariableName] = m
+		}
+
+		fields[i] = arrow.Field{
+			Name:     h.VariableName,
+			Type:     columnType(h.VariableType),
+			Nullable: true,
+			Metadata: arrow.NewMetadata(keys, values),
+		}
+	}
+
+	b, err := json.Marshal(allLabels)
+	if err != nil {
+		return err
+	}
+	metadata := arrow.NewMetadata([]string{valueLabelsKey}, []string{string(b)})
+	schema := arrow.NewSchema(fields, &metadata)
+
+	writer, err := c.newWriter(schema, c.file)
+	if err != nil {
+		return err
+	}
+
+	c.writer = writer
+	c.builder = array.NewRecordBuilder(c.mem, schema)
+
+	return nil
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func stringValue(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// add a value to a column, as null if it is missing or is not a number in a numeric column
+func appendValue(b array.Builder, v interface{}) {
+	if sb, ok := b.(*array.StringBuilder); ok {
+		if s, ok := stringValue(v); ok {
+			sb.Append(s)
+		} else {
+			sb.AppendNull()
+		}
+		return
+	}
+
+	f, ok := numberValue(v)
+	if !ok {
+		b.AppendNull()
+		return
+	}
+
+	switch nb := b.(type) {
+	case *array.Int8Builder:
+		nb.Append(int8(f))
+	case *array.Int16Builder:
+		nb.Append(int16(f))
+	case *array.Int32Builder:
+		nb.Append(int32(f))
+	case *array.Float32Builder:
+		nb.Append(float32(f))
+	case *array.Float64Builder:
+		nb.Append(f)
+	}
+}
+
+func (c *RowWriter) WriteRow(row []interface{}) error {
+	fields := c.builder.Fields()
+	if len(row) != len(fields) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(fields))
+	}
+
+	for i, v := range row {
+		appendValue(fields[i], v)
+	}
+
+	c.rows++
+	if c.rows == batchSize {
+		return c.flush()
+	}
+
+	return nil
+}
+
+func (c *RowWriter) flush() error {
+	record := c.builder.NewRecord()
+	defer record.Release()
+
+	c.rows = 0
+	return c.writer.Write(record)
+}
+
+func (c *RowWriter) Close() error {
+	if c.writer == nil {
+		return c.file.Close()
+	}
+
+	defer c.builder.Release()
+
+	if c.rows > 0 {
+		if err := c.flush(); err != nil {
+			_ = c.writer.Close()
+			_ = c.file.Close()
+			return err
+		}
+	}
+
+	if err := c.writer.Close(); err != nil {
+		_ = c.file.Close()
+		return err
+	}
+
+	return c.file.Close()
+}
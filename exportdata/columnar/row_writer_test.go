@@ -0,0 +1,150 @@
+package columnar
+
+import (
+	"context"
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet/file"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"os"
+	"path/filepath"
+	"services/types"
+	"testing"
+)
+
+func testHeader() []types.Header {
+	return []types.Header{
+		{VariableName: "SEX", VariableDescription: "Sex of respondent", VariableType: types.TypeInt8, LabelName: "GB_SEX"},
+		{VariableName: "AGE", VariableDescription: "Age", VariableType: types.TypeInt32},
+		{VariableName: "PWT", VariableDescription: "Person weight", VariableType: types.TypeDouble},
+		{VariableName: "POSTCODE", VariableType: types.TypeString},
+	}
+}
+
+func testLabels() map[string][]types.Labels {
+	return map[string][]types.Labels{
+		"GB_SEX": {
+			{Name: "GB_SEX", Value: int64(1), Label: "Male"},
+			{Name: "GB_SEX", Value: int64(2), Label: "Female"},
+		},
+	}
+}
+
+func writeTestFile(t *testing.T, w *RowWriter) {
+	if err := w.WriteLabels(testLabels()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(testHeader()); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{1.0, 34.0, 1234.5, "NP10 8XG"},
+		{2.0, nil, 987.25, nil},
+	}
+	for _, r := range rows {
+		if err := w.WriteRow(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkRecord(t *testing.T, schema *arrow.Schema, record arrow.Record) {
+	if record.NumRows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", record.NumRows())
+	}
+
+	if !arrow.TypeEqual(schema.Field(0).Type, arrow.PrimitiveTypes.Int8) {
+		t.Errorf("expected SEX to be int8, got %s", schema.Field(0).Type)
+	}
+	if labels, ok := schema.Field(0).Metadata.GetValue(valueLabelsKey); !ok || labels != `{"1":"Male","2":"Female"}` {
+		t.Errorf("unexpected value labels for SEX: %q", labels)
+	}
+	if description, _ := schema.Field(2).Metadata.GetValue(descriptionKey); description != "Person weight" {
+		t.Errorf("unexpected description for PWT: %q", description)
+	}
+
+	sex := record.Column(0).(*array.Int8)
+	if sex.Value(1) != 2 {
+		t.Errorf("expected SEX of 2, got %d", sex.Value(1))
+	}
+	if !record.Column(1).IsNull(1) {
+		t.Error("expected a missing AGE to be null")
+	}
+	if pwt := record.Column(2).(*array.Float64); pwt.Value(0) != 1234.5 {
+		t.Errorf("expected PWT of 1234.5, got %f", pwt.Value(0))
+	}
+	if postcode := record.Column(3).(*array.String); postcode.Value(0) != "NP10 8XG" || !postcode.IsNull(1) {
+		t.Errorf("unexpected POSTCODE values")
+	}
+}
+
+func TestArrowWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.arrow")
+
+	w, err := NewArrowWriter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, w)
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := ipc.NewFileReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	record, err := r.Record(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecord(t, r.Schema(), record)
+}
+
+func TestParquetWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.parquet")
+
+	w, err := NewParquetWriter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, w)
+
+	pf, err := file.OpenParquetFile(fileName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+
+	if labels := pf.MetaData().KeyValueMetadata().FindValue(valueLabelsKey); labels == nil ||
+		*labels != `{"SEX":{"1":"Male","2":"Female"}}` {
+		t.Errorf("unexpected file value labels")
+	}
+
+	r, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := r.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, -1)
+	defer tr.Release()
+	tr.Next()
+
+	checkRecord(t, table.Schema(), tr.Record())
+}
@@ -0,0 +1,63 @@
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"services/types"
+	"strconv"
+)
+
+/*
+RowWriter writes a dataset to a CSV file a row at a time. Missing values are written as empty fields.
+*/
+type RowWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func NewRowWriter(fileName string) (*RowWriter, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create CSV file: %s, err: %w", fileName, err)
+	}
+
+	return &RowWriter{file: file, writer: csv.NewWriter(file)}, nil
+}
+
+func (c *RowWriter) WriteHeader(header []types.Header) error {
+	names := make([]string, len(header))
+	for i, h := range header {
+		names[i] = h.VariableName
+	}
+	return c.writer.Write(names)
+}
+
+func (c *RowWriter) WriteRow(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = formatValue(v)
+	}
+	return c.writer.Write(record)
+}
+
+func (c *RowWriter) Close() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		_ = c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
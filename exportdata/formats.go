@@ -0,0 +1,91 @@
+package exportdata
+
+import (
+	"services/exportdata/columnar"
+	"services/exportdata/csv"
+	"services/exportdata/sav"
+	"services/types"
+)
+
+/*
+RowWriter writes a dataset a row at a time. WriteHeader is called once before any rows. Each row has a value
+for every column in the header: a string, a float64 or nil for a missing value.
+*/
+type RowWriter interface {
+	WriteHeader(header []types.Header) error
+	WriteRow(row []interface{}) error
+	Close() error
+}
+
+/*
+LabelWriter is a RowWriter that keeps value labels, such as SAV. WriteLabels is called before WriteHeader with
+the label sets named by the LabelName of each header.
+*/
+type LabelWriter interface {
+	WriteLabels(labels map[string][]types.Labels) error
+}
+
+/*
+CountedWriter is a RowWriter that needs the number of rows before the first is written, such as SAV.
+WriteRowCount is called before WriteHeader and exactly that many rows are then written.
+*/
+type CountedWriter interface {
+	WriteRowCount(rows int) error
+}
+
+/*
+Format is a file format that datasets can be exported in. Omitted is what the format cannot hold of the
+dictionary of a dataset, which is reported with each export.
+*/
+type Format struct {
+	Extension   string
+	ContentType string
+	Omitted     string
+	NewWriter   func(fileName string) (RowWriter, error)
+}
+
+var Formats = map[string]Format{
+	"csv": {
+		Extension:   "csv",
+		ContentType: "text/csv",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return csv.NewRowWriter(fileName)
+		},
+	},
+	"parquet": {
+		Extension:   "parquet",
+		ContentType: "application/vnd.apache.parquet",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return columnar.NewParquetWriter(fileName)
+		},
+	},
+	"arrow": {
+		Extension:   "arrow",
+		ContentType: "application/vnd.apache.arrow.file",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return columnar.NewArrowWriter(fileName)
+		},
+	},
+	"dta": {
+		Extension:   "dta",
+		ContentType: "application/x-stata-dta",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return sav.NewDTAWriter(fileName)
+		},
+	},
+	"xpt": {
+		Extension:   "xpt",
+		ContentType: "application/x-sas-xport",
+		Omitted:     "SAS transport files have no value labels",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return sav.NewXPTWriter(fileName)
+		},
+	},
+	"sav": {
+		Extension:   "sav",
+		ContentType: "application/x-spss-sav",
+		NewWriter: func(fileName string) (RowWriter, error) {
+			return sav.NewRowWriter(fileName)
+		},
+	},
+}
@@ -0,0 +1,309 @@
+package sav
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"services/io/spss"
+	"services/types"
+	"strconv"
+)
+
+// the width of a string variable with no length set
+const defaultStringWidth = 255
+
+/*
+RowWriter writes a dataset to a SAV, Stata or SAS transport file with its dictionary: the description, width
+and precision of each variable and its value labels. The readstat writer needs the number of rows before it
+starts, so WriteRowCount must be called before WriteHeader. Each row is then written as it is given.
+*/
+type RowWriter struct {
+	format   FileFormat
+	fileName string
+	header   []types.Header
+	labels   map[string][]types.Labels
+	rowCount int
+	counted  bool
+	file     *File
+	written  int
+}
+
+func NewRowWriter(fileName string) (*RowWriter, error) {
+	return &RowWriter{format: SAV, fileName: fileName}, nil
+}
+
+func NewDTAWriter(fileName string) (*RowWriter, error) {
+	return &RowWriter{format: DTA, fileName: fileName}, nil
+}
+
+/*
+SAS keeps value labels as formats in a separate catalog, so transport files only have variable labels. The
+labels left out are exported separately.
+*/
+func NewXPTWriter(fileName string) (*RowWriter, error) {
+	return &RowWriter{format: XPT, fileName: fileName}, nil
+}
+
+func (s *RowWriter) WriteLabels(labels map[string][]types.Labels) error {
+	s.labels = labels
+	return nil
+}
+
+func (s *RowWriter) WriteRowCount(rows int) error {
+	s.rowCount = rows
+	s.counted = true
+	return nil
+}
+
+func (s *RowWriter) WriteHeader(header []types.Header) error {
+	if !s.counted {
+		return fmt.Errorf("cannot write %s, the number of rows must be given before the header", s.fileName)
+	}
+
+	s.header = header
+	variables, labelSets := s.dictionary()
+
+	file, err := Create(s.format, s.fileName, "LFS export", variables, labelSets, s.rowCount)
+	if err != nil {
+		return err
+	}
+	s.file = file
+
+	return nil
+}
+
+/*
+Convert each value to a string or a float64 as its variable needs. A value that is not a number is written as
+missing in a numeric variable.
+*/
+func (s *RowWriter) WriteRow(row []interface{}) error {
+	if s.file == nil {
+		return fmt.Errorf("cannot write to file: %s, the header has not been written", s.fileName)
+	}
+	if s.written == s.rowCount {
+		return fmt.Errorf("cannot write to file: %s, more than the %d rows given", s.fileName, s.rowCount)
+	}
+	if len(row) != len(s.header) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(s.header))
+	}
+
+	values := make([]interface{}, len(row))
+
+	for i, v := range row {
+		if v == nil {
+			continue
+		}
+
+		if s.header[i].VariableType == types.TypeString {
+			switch value := v.(type) {
+			case string:
+				values[i] = value
+			case float64:
+				values[i] = strconv.FormatFloat(value, 'f', -1, 64)
+			default:
+				values[i] = fmt.Sprint(v)
+			}
+			continue
+		}
+
+		switch value := v.(type) {
+		case float64:
+			values[i] = value
+		case string:
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				values[i] = f
+			}
+		}
+	}
+
+	if err := s.file.WriteRow(values); err != nil {
+		return fmt.Errorf("cannot write to file: %s, %s", s.fileName, err)
+	}
+	s.written++
+
+	return nil
+}
+
+// the widest string each format allows
+func (f FileFormat) maxStringWidth() int {
+	switch f {
+	case DTA:
+		return 2045
+	case XPT:
+		return 200
+	}
+	return 32767
+}
+
+func (f FileFormat) stringWidth(h types.Header) int {
+	switch {
+	case h.VariableLength <= 0:
+		if defaultStringWidth > f.maxStringWidth() {
+			return f.maxStringWidth()
+		}
+		return defaultStringWidth
+	case h.VariableLength > f.maxStringWidth():
+		return f.maxStringWidth()
+	}
+	return h.VariableLength
+}
+
+// the print format of a number, wide enough for its precision
+func (f FileFormat) numberFormat(width, precision int) string {
+	if width <= 0 {
+		width = 8
+	}
+	if precision > 0 && width < precision+2 {
+		width = precision + 2
+	}
+
+	switch f {
+	case DTA:
+		return fmt.Sprintf("%%%d.%df", width, precision)
+	case XPT:
+		return fmt.Sprintf("%d.%d", width, precision)
+	}
+	return fmt.Sprintf("F%d.%d", width, precision)
+}
+
+// Stata only labels whole numbers and SAS transport files have no value labels
+func (f FileFormat) valueLabels(str bool) bool {
+	switch f {
+	case DTA:
+		return !str
+	case XPT:
+		return false
+	}
+	return true
+}
+
+/*
+A label set of the type of the variable it labels. Labels read from a file can have integer, float or string
+values.
+*/
+func labelSet(name string, setType spss.ColumnType, labels []types.Labels) LabelSet {
+	set := LabelSet{Name: name, Type: setType}
+	str := setType == spss.ReadstatTypeString
+
+	for _, l := range labels {
+		var value interface{}
+
+		switch v := l.Value.(type) {
+		case int64:
+			value = float64(v)
+		case int:
+			value = float64(v)
+		case float32:
+			value = float64(v)
+		case float64:
+			value = v
+		case string:
+			value = v
+		default:
+			continue
+		}
+
+		if str {
+			if f, ok := value.(float64); ok {
+				value = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+		} else if v, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				log.Warn().
+					Str("labelSet", name).
+					Str("value", v).
+					Msg("Value label is not a number, ignored")
+				continue
+			}
+			value = f
+		}
+
+		set.Values = append(set.Values, value)
+		set.Labels = append(set.Labels, l.Label)
+	}
+
+	return set
+}
+
+/*
+The dictionary of the file, with the label sets used by its variables. SPSS and SAS numbers are doubles. Stata
+keeps the type of each number, except that a labelled number must be a whole number.
+*/
+func (s *RowWriter) dictionary() ([]Variable, []LabelSet) {
+	variables := make([]Variable, len(s.header))
+	var labelSets []LabelSet
+	sets := make(map[string]int)
+
+	for i, h := range s.header {
+		str := h.VariableType == types.TypeString
+		labels, labelled := s.labels[h.LabelName]
+		labelled = labelled && h.LabelName != "" && s.format.valueLabels(str)
+
+		v := Variable{Name: h.VariableName, Label: h.VariableDescription, Type: spss.ReadstatTypeDouble, LabelSet: -1}
+
+		switch {
+		case str:
+			v.Type = spss.ReadstatTypeString
+			v.Width = s.format.stringWidth(h)
+		case s.format == DTA && labelled:
+			v.Type = spss.ReadstatTypeInt32
+			v.Format = s.format.numberFormat(h.VariableLength, 0)
+		case s.format == DTA:
+			v.Type = stataType(h.VariableType)
+			v.Format = s.format.numberFormat(h.VariableLength, h.VariablePrecision)
+		default:
+			v.Format = s.format.numberFormat(h.VariableLength, h.VariablePrecision)
+		}
+
+		if labelled {
+			// a label set can only label variables of one type
+			key := fmt.Sprintf("%s:%d", h.LabelName, v.Type)
+			index, ok := sets[key]
+			if !ok {
+				index = len(labelSets)
+				sets[key] = index
+				labelSets = append(labelSets, labelSet(fmt.Sprintf("labels%d", index), v.Type, labels))
+			}
+			v.LabelSet = index
+		}
+
+		variables[i] = v
+	}
+
+	return variables, labelSets
+}
+
+/*
+Stata keeps the top of the range of each integer type for missing values, so the smaller integers are widened
+to hold any value of their type
+*/
+func stataType(t types.SavType) spss.ColumnType {
+	switch t {
+	case types.TypeInt8:
+		return spss.ReadstatTypeInt16
+	case types.TypeInt16, types.TypeInt32:
+		return spss.ReadstatTypeInt32
+	case types.TypeFloat:
+		return spss.ReadstatTypeFloat
+	}
+	return spss.ReadstatTypeDouble
+}
+
+// a file with fewer rows than it was created for is incomplete, so is reported as an error
+func (s *RowWriter) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+
+	if s.written != s.rowCount {
+		return fmt.Errorf("file: %s has %d rows, expected %d", s.fileName, s.written, s.rowCount)
+	}
+
+	return nil
+}
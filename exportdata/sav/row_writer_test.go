@@ -0,0 +1,132 @@
+package sav
+
+import (
+	"path/filepath"
+	importsav "services/importdata/sav"
+	"services/types"
+	"testing"
+)
+
+func testHeader() []types.Header {
+	return []types.Header{
+		{VariableName: "SEX", VariableDescription: "Sex of respondent", VariableType: types.TypeInt8, LabelName: "GB_SEX"},
+		{VariableName: "PWT", VariableDescription: "Person weight", VariableType: types.TypeDouble, VariableLength: 10, VariablePrecision: 2},
+		{VariableName: "POSTCODE", VariableDescription: "Postcode", VariableType: types.TypeString, VariableLength: 8},
+		{VariableName: "COUNTRY", VariableType: types.TypeString, VariableLength: 2, LabelName: "GB_COUNTRY"},
+	}
+}
+
+func testLabels() map[string][]types.Labels {
+	return map[string][]types.Labels{
+		"GB_SEX": {
+			{Name: "GB_SEX", Value: int64(1), Label: "Male"},
+			{Name: "GB_SEX", Value: int64(2), Label: "Female"},
+		},
+		"GB_COUNTRY": {
+			{Name: "GB_COUNTRY", Value: "EN", Label: "England"},
+			{Name: "GB_COUNTRY", Value: "WA", Label: "Wales"},
+		},
+	}
+}
+
+func TestRoundTripDictionary(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.sav")
+
+	w, err := NewRowWriter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteLabels(testLabels()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRowCount(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(testHeader()); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{1.0, 1234.5, "NP10 8XG", "WA"},
+		{2.0, nil, nil, "EN"},
+	}
+	for _, r := range rows {
+		if err := w.WriteRow(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := importsav.ImportSav(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.RowCount != 2 {
+		t.Errorf("expected 2 rows, got %d", data.RowCount)
+	}
+	if data.HeaderCount != 4 {
+		t.Fatalf("expected 4 variables, got %d", data.HeaderCount)
+	}
+
+	sex, pwt, postcode, country := data.Header[0], data.Header[1], data.Header[2], data.Header[3]
+
+	if sex.VariableDescription != "Sex of respondent" {
+		t.Errorf("unexpected description for SEX: %q", sex.VariableDescription)
+	}
+	if pwt.VariablePrecision != 2 {
+		t.Errorf("expected PWT to be written with 2 decimals, got %d", pwt.VariablePrecision)
+	}
+	if postcode.VariableType != types.TypeString || postcode.VariableLength != 8 {
+		t.Errorf("expected POSTCODE to be a string of 8, got %s of %d", postcode.VariableType, postcode.VariableLength)
+	}
+	if postcode.LabelName != "" {
+		t.Errorf("expected POSTCODE to have no value labels, got %s", postcode.LabelName)
+	}
+
+	checkLabels := func(name, set string, expected map[interface{}]string) {
+		if set == "" {
+			t.Errorf("expected %s to have value labels", name)
+			return
+		}
+		labels := data.Labels[set]
+		if len(labels) != len(expected) {
+			t.Errorf("expected %d value labels for %s, got %d", len(expected), name, len(labels))
+		}
+		for _, l := range labels {
+			if expected[l.Value] != l.Label {
+				t.Errorf("unexpected value label for %s: %v = %q", name, l.Value, l.Label)
+			}
+		}
+	}
+
+	checkLabels("SEX", sex.LabelName, map[interface{}]string{1.0: "Male", 2.0: "Female"})
+	checkLabels("COUNTRY", country.LabelName, map[interface{}]string{"EN": "England", "WA": "Wales"})
+
+	if sex.LabelName == country.LabelName {
+		t.Errorf("numeric and string variables share the label set %s", sex.LabelName)
+	}
+}
+
+func TestRowCount(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.sav")
+
+	w, _ := NewRowWriter(fileName)
+	if err := w.WriteHeader(testHeader()); err == nil {
+		t.Fatal("expected the header to be refused before the number of rows")
+	}
+
+	if err := w.WriteRowCount(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(testHeader()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]interface{}{1.0, 1.5, "NP10 8XG", "WA"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("expected a file with fewer rows than counted to be reported")
+	}
+}
@@ -0,0 +1,223 @@
+package sav
+
+// #cgo windows amd64 CFLAGS: -g -IC:/msys64/mingw64/include
+// #cgo windows LDFLAGS: -LC:/msys64/mingw64/lib -lreadstat
+// #cgo darwin amd64 CFLAGS: -g
+// #cgo darwin LDFLAGS: -lreadstat
+// #cgo linux amd64 CFLAGS: -I/usr/local/include -g
+// #cgo linux LDFLAGS: -L/usr/local/lib -lreadstat
+// #include "sav_writer.h"
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"services/io/spss"
+	"unsafe"
+)
+
+/*
+FileFormat is a file format readstat writes: SPSS, Stata or SAS transport
+*/
+type FileFormat int
+
+// the same order as export_format in sav_writer.h
+const (
+	SAV FileFormat = iota
+	DTA
+	XPT
+)
+
+/*
+Variable is an entry in the dictionary of a file. Width is the storage width of a string and Format the print
+format in the style of the file format, F8.2 for SPSS for example. LabelSet is the index of the variable's
+value labels, or -1 if it has none.
+*/
+type Variable struct {
+	Name     string
+	Label    string
+	Type     spss.ColumnType
+	Width    int
+	Format   string
+	LabelSet int
+}
+
+/*
+LabelSet is a set of value labels. The values are float64s, or strings if Type is a string. They are written
+as the type of the variables they label.
+*/
+type LabelSet struct {
+	Name   string
+	Type   spss.ColumnType
+	Values []interface{}
+	Labels []string
+}
+
+/*
+File is a file being written with its dictionary. The readstat writer needs the number of rows before the
+first row is written.
+*/
+type File struct {
+	file      *C.export_file
+	variables []Variable
+	values    unsafe.Pointer
+}
+
+func readstatError(err C.int) error {
+	return fmt.Errorf("%s", C.GoString(C.readstat_error_message(C.readstat_error_t(err))))
+}
+
+func Create(format FileFormat, fileName, label string, variables []Variable, labelSets []LabelSet, rows int) (*File, error) {
+	var allocated []unsafe.Pointer
+	cString := func(s string) *C.char {
+		c := C.CString(s)
+		allocated = append(allocated, unsafe.Pointer(c))
+		return c
+	}
+	cAlloc := func(size int) unsafe.Pointer {
+		if size == 0 {
+			size = 1
+		}
+		p := C.malloc(C.size_t(size))
+		allocated = append(allocated, p)
+		return p
+	}
+
+	// readstat copies the dictionary so everything allocated for it is freed once the file is open
+	defer func() {
+		for _, p := range allocated {
+			C.free(p)
+		}
+	}()
+
+	cVariables := (*[1 << 20]C.variable_info)(cAlloc(C.sizeof_variable_info * len(variables)))[:len(variables):len(variables)]
+	for i, v := range variables {
+		cVariables[i].sav_type = C.int(v.Type)
+		cVariables[i].name = cString(v.Name)
+		cVariables[i].label = cString(v.Label)
+		cVariables[i].format = cString(v.Format)
+		cVariables[i].width = C.int(v.Width)
+		cVariables[i].label_set = C.int(v.LabelSet)
+	}
+
+	cSets := (*[1 << 20]C.label_set_info)(cAlloc(C.sizeof_label_set_info * len(labelSets)))[:len(labelSets):len(labelSets)]
+	for i, l := range labelSets {
+		n := len(l.Values)
+		cSets[i].sav_type = C.int(l.Type)
+		cSets[i].name = cString(l.Name)
+		cSets[i].count = C.int(n)
+		if n == 0 {
+			continue
+		}
+
+		labels := (*[1 << 20]*C.char)(cAlloc(int(unsafe.Sizeof((*C.char)(nil))) * n))[:n:n]
+		for j, label := range l.Labels {
+			labels[j] = cString(label)
+		}
+		cSets[i].labels = &labels[0]
+
+		if l.Type == spss.ReadstatTypeString {
+			values := (*[1 << 20]*C.char)(cAlloc(int(unsafe.Sizeof((*C.char)(nil))) * n))[:n:n]
+			for j, v := range l.Values {
+				values[j] = cString(v.(string))
+			}
+			cSets[i].string_values = &values[0]
+		} else {
+			values := (*[1 << 20]C.double)(cAlloc(C.sizeof_double * n))[:n:n]
+			for j, v := range l.Values {
+				values[j] = C.double(v.(float64))
+			}
+			cSets[i].double_values = &values[0]
+		}
+	}
+
+	var variablesPtr *C.variable_info
+	if len(cVariables) > 0 {
+		variablesPtr = &cVariables[0]
+	}
+	var setsPtr *C.label_set_info
+	if len(cSets) > 0 {
+		setsPtr = &cSets[0]
+	}
+
+	var cErr C.int
+	file := C.open_export(C.export_format(format), cString(fileName), cString(label), variablesPtr, C.int(len(variables)),
+		setsPtr, C.int(len(labelSets)), C.int(rows), &cErr)
+	if file == nil {
+		return nil, fmt.Errorf("cannot create %s: %s", fileName, readstatError(cErr))
+	}
+
+	size := C.sizeof_value_info * len(variables)
+	if size == 0 {
+		size = 1
+	}
+
+	return &File{
+		file:      file,
+		variables: variables,
+		values:    C.malloc(C.size_t(size)),
+	}, nil
+}
+
+/*
+WriteRow writes a row of float64s for numeric variables and strings for string variables. A nil value is
+written as missing. A string longer than its variable's width is truncated.
+*/
+func (f *File) WriteRow(row []interface{}) error {
+	if len(row) != len(f.variables) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(f.variables))
+	}
+
+	n := len(f.variables)
+	values := (*[1 << 20]C.value_info)(f.values)[:n:n]
+
+	var cStrings []*C.char
+	defer func() {
+		for _, s := range cStrings {
+			C.free(unsafe.Pointer(s))
+		}
+	}()
+
+	for i, v := range f.variables {
+		values[i] = C.value_info{}
+
+		if row[i] == nil {
+			values[i].missing = 1
+			continue
+		}
+
+		if v.Type == spss.ReadstatTypeString {
+			s, ok := row[i].(string)
+			if !ok {
+				return fmt.Errorf("invalid value for %s, string expected: %v", v.Name, row[i])
+			}
+			if v.Width > 0 && len(s) > v.Width {
+				s = s[:v.Width]
+			}
+			c := C.CString(s)
+			cStrings = append(cStrings, c)
+			values[i].string_value = c
+			continue
+		}
+
+		d, ok := row[i].(float64)
+		if !ok {
+			return fmt.Errorf("invalid value for %s, number expected: %v", v.Name, row[i])
+		}
+		values[i].double_value = C.double(d)
+	}
+
+	if err := C.write_export_row(f.file, (*C.value_info)(f.values)); err != 0 {
+		return readstatError(err)
+	}
+
+	return nil
+}
+
+func (f *File) Close() error {
+	err := C.close_export(f.file)
+	C.free(f.values)
+	if err != 0 {
+		return readstatError(err)
+	}
+	return nil
+}
@@ -135,3 +135,177 @@ int save_sav(const char *output_file, const char *label, file_header **sav_heade
 
     return 0;
 }
+
+struct export_file {
+    readstat_writer_t *writer;
+    readstat_variable_t **variables;
+    int column_cnt;
+    int fd;
+    int writing;
+};
+
+static readstat_error_t begin_writing(export_format format, readstat_writer_t *writer, void *fd, int row_count) {
+    switch (format) {
+        case EXPORT_DTA:
+            return readstat_begin_writing_dta(writer, fd, row_count);
+        case EXPORT_XPORT:
+            return readstat_begin_writing_xport(writer, fd, row_count);
+        default:
+            return readstat_begin_writing_sav(writer, fd, row_count);
+    }
+}
+
+export_file *open_export(const export_format format, const char *output_file, const char *label,
+                         const variable_info *variables, const int column_cnt,
+                         const label_set_info *label_sets, const int label_set_cnt, const int row_count, int *error) {
+
+    export_file *file = calloc(1, sizeof(export_file));
+    if (!file) {
+        *error = READSTAT_ERROR_MALLOC;
+        return NULL;
+    }
+
+    file->fd = open(output_file, O_WRONLY | O_CREAT | O_TRUNC, 0666);
+    if (file->fd == -1) {
+        free(file);
+        *error = READSTAT_ERROR_OPEN;
+        return NULL;
+    }
+
+    file->writer = readstat_writer_init();
+    readstat_set_data_writer(file->writer, &write_bytes);
+    readstat_writer_set_file_label(file->writer, label);
+
+    switch (format) {
+        case EXPORT_SAV:
+            readstat_writer_set_compression(file->writer, READSTAT_COMPRESS_ROWS);
+            break;
+        case EXPORT_XPORT:
+            // version 8 allows variable names of up to 32 characters
+            readstat_writer_set_file_format_version(file->writer, 8);
+            readstat_writer_set_table_name(file->writer, "LFS");
+            break;
+        default:
+            break;
+    }
+
+    readstat_label_set_t **sets = malloc(sizeof(readstat_label_set_t *) * (label_set_cnt > 0 ? label_set_cnt : 1));
+    file->variables = malloc(sizeof(readstat_variable_t *) * (column_cnt > 0 ? column_cnt : 1));
+    file->column_cnt = column_cnt;
+
+    if (!sets || !file->variables) {
+        free(sets);
+        close_export(file);
+        *error = READSTAT_ERROR_MALLOC;
+        return NULL;
+    }
+
+    for (int i = 0; i < label_set_cnt; i++) {
+        const label_set_info *info = &label_sets[i];
+        readstat_label_set_t *set = readstat_add_label_set(file->writer, info->sav_type, info->name);
+        for (int j = 0; j < info->count; j++) {
+            if (info->sav_type == READSTAT_TYPE_STRING) {
+                readstat_label_string_value(set, info->string_values[j], info->labels[j]);
+            } else if (info->sav_type == READSTAT_TYPE_INT32) {
+                readstat_label_int32_value(set, (int32_t) info->double_values[j], info->labels[j]);
+            } else {
+                readstat_label_double_value(set, info->double_values[j], info->labels[j]);
+            }
+        }
+        sets[i] = set;
+    }
+
+    for (int i = 0; i < column_cnt; i++) {
+        const variable_info *info = &variables[i];
+        size_t width = info->sav_type == READSTAT_TYPE_STRING ? info->width : 0;
+
+        readstat_variable_t *variable = readstat_add_variable(file->writer, info->name, info->sav_type, width);
+        readstat_variable_set_label(variable, info->label);
+        if (info->format[0] != 0) {
+            readstat_variable_set_format(variable, info->format);
+        }
+        if (info->label_set >= 0 && info->label_set < label_set_cnt) {
+            readstat_variable_set_label_set(variable, sets[info->label_set]);
+        }
+        file->variables[i] = variable;
+    }
+
+    free(sets);
+
+    readstat_error_t err = begin_writing(format, file->writer, &file->fd, row_count);
+    if (err != READSTAT_OK) {
+        close_export(file);
+        *error = err;
+        return NULL;
+    }
+
+    file->writing = 1;
+    *error = READSTAT_OK;
+
+    return file;
+}
+
+int write_export_row(export_file *file, const value_info *values) {
+
+    readstat_error_t err = readstat_begin_row(file->writer);
+    if (err != READSTAT_OK) {
+        return err;
+    }
+
+    for (int i = 0; i < file->column_cnt; i++) {
+        readstat_variable_t *variable = file->variables[i];
+
+        if (values[i].missing) {
+            err = readstat_insert_missing_value(file->writer, variable);
+            if (err != READSTAT_OK) {
+                return err;
+            }
+            continue;
+        }
+
+        double d = values[i].double_value;
+
+        switch (readstat_variable_get_type(variable)) {
+            case READSTAT_TYPE_STRING:
+                err = readstat_insert_string_value(file->writer, variable, values[i].string_value);
+                break;
+            case READSTAT_TYPE_INT8:
+                err = readstat_insert_int8_value(file->writer, variable, (int8_t) d);
+                break;
+            case READSTAT_TYPE_INT16:
+                err = readstat_insert_int16_value(file->writer, variable, (int16_t) d);
+                break;
+            case READSTAT_TYPE_INT32:
+                err = readstat_insert_int32_value(file->writer, variable, (int32_t) d);
+                break;
+            case READSTAT_TYPE_FLOAT:
+                err = readstat_insert_float_value(file->writer, variable, (float) d);
+                break;
+            default:
+                err = readstat_insert_double_value(file->writer, variable, d);
+                break;
+        }
+
+        if (err != READSTAT_OK) {
+            return err;
+        }
+    }
+
+    return readstat_end_row(file->writer);
+}
+
+int close_export(export_file *file) {
+
+    readstat_error_t err = READSTAT_OK;
+
+    if (file->writing) {
+        err = readstat_end_writing(file->writer);
+    }
+
+    readstat_writer_free(file->writer);
+    close(file->fd);
+    free(file->variables);
+    free(file);
+
+    return err;
+}
@@ -24,4 +24,47 @@ typedef struct {
 int save_sav(const char *output_file, const char *label,
              file_header **sav_header, const int column_cnt, const int data_rows, const data_item **sav_data);
 
+/*
+ * Writing a SAV, Stata or SAS transport file with its dictionary: each variable's label, width, format and
+ * value labels. Rows are written one at a time, numbers given as doubles, and a missing value is marked
+ * rather than written as 0.
+ */
+typedef enum {
+    EXPORT_SAV,
+    EXPORT_DTA,
+    EXPORT_XPORT
+} export_format;
+
+typedef struct {
+    int sav_type;
+    const char *name;
+    const char *label;
+    const char *format;
+    int width;
+    int label_set;
+} variable_info;
+
+typedef struct {
+    int sav_type;
+    const char *name;
+    int count;
+    double *double_values;
+    char **string_values;
+    char **labels;
+} label_set_info;
+
+typedef struct {
+    int missing;
+    double double_value;
+    const char *string_value;
+} value_info;
+
+typedef struct export_file export_file;
+
+export_file *open_export(export_format format, const char *output_file, const char *label,
+                         const variable_info *variables, int column_cnt,
+                         const label_set_info *label_sets, int label_set_cnt, int row_count, int *error);
+int write_export_row(export_file *file, const value_info *values);
+int close_export(export_file *file);
+
 #endif
\ No newline at end of file
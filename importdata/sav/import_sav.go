@@ -47,7 +47,33 @@ func ImportSav(fileName string) (types.SavImportData, error) {
 	labelsCount := int(v.labels_count)
 	rowCount := int(v.row_count)
 
-	// Get headers
+	header := readHeader(&v)
+	labelsMap := readLabels(&v)
+
+	// get rows
+	var savRows = make([]types.Rows, rowCount)
+
+	for i := 0; i < rowCount; i++ {
+		var rows **C.struct_Rows = v.rows
+		r := (*[1 << 30]*C.struct_Rows)((unsafe.Pointer(rows)))[i]
+		savRows[i] = types.Rows{RowData: readRow(r)}
+	}
+
+	savImportData := types.SavImportData{
+		Header:      header,
+		HeaderCount: headerCount,
+		Labels:      labelsMap,
+		LabelsCount: labelsCount,
+		Rows:        savRows,
+		RowCount:    rowCount,
+	}
+
+	return savImportData, nil
+}
+
+func readHeader(v *C.struct_Data) []types.Header {
+	headerCount := int(v.header_count)
+
 	var header = make([]types.Header, headerCount)
 	var head **C.struct_Header = v.header
 
@@ -67,7 +93,12 @@ func ImportSav(fileName string) (types.SavImportData, error) {
 		}
 	}
 
-	// get labels
+	return header
+}
+
+func readLabels(v *C.struct_Data) map[string][]types.Labels {
+	labelsCount := int(v.labels_count)
+
 	labelsMap := make(map[string][]types.Labels, labelsCount)
 	var labels **C.struct_Labels = v.labels
 	for i := 0; i < labelsCount; i++ {
@@ -114,36 +145,22 @@ func ImportSav(fileName string) (types.SavImportData, error) {
 		}
 	}
 
-	// get rows
-	var savRows = make([]types.Rows, rowCount)
-
-	for i := 0; i < rowCount; i++ {
-		var rows **C.struct_Rows = v.rows
-		r := (*[1 << 30]*C.struct_Rows)((unsafe.Pointer(rows)))[i]
-
-		length := int(r.row_length)
-
-		var rowValues = make([]string, length)
+	return labelsMap
+}
 
-		rowData := r.row_data
-		for j := 0; j < length; j++ {
-			s := (*[1 << 30]*C.char)((unsafe.Pointer(rowData)))[j]
-			rowValues[j] = C.GoString(s)
-		}
+// the values of a row read from a sav file
+func readRow(r *C.struct_Rows) []string {
+	length := int(r.row_length)
 
-		savRows[i] = types.Rows{RowData: rowValues}
-	}
+	var rowValues = make([]string, length)
 
-	savImportData := types.SavImportData{
-		Header:      header,
-		HeaderCount: headerCount,
-		Labels:      labelsMap,
-		LabelsCount: labelsCount,
-		Rows:        savRows,
-		RowCount:    rowCount,
+	rowData := r.row_data
+	for j := 0; j < length; j++ {
+		s := (*[1 << 30]*C.char)((unsafe.Pointer(rowData)))[j]
+		rowValues[j] = C.GoString(s)
 	}
 
-	return savImportData, nil
+	return rowValues
 }
 
 func getType(savType int) types.SavType {
@@ -24,9 +24,28 @@ void add_new_row(struct Data *data)  {
     data->row_count++;
 }
 
+// a streamed row is reused, so the previous row's values are freed
+void reset_stream_row(struct Data *data) {
+
+    struct Rows *row = data->row;
+    if (row == NULL) {
+        row = malloc(sizeof(struct Rows));
+        row->row_data = malloc(sizeof(char *) * data->header_count);
+        data->row = row;
+    } else {
+        for (int j = 0; j < row->row_length; j++) {
+            if (row->row_data[j] != 0) free(row->row_data[j]);
+        }
+    }
+
+    row->row_position = 0;
+    row->row_length = 0;
+    data->row_count++;
+}
+
 void add_to_row(struct Data *data, const char *value) {
 
-    struct Rows *current_row = data->rows[data->row_count - 1];
+    struct Rows *current_row = data->streaming ? data->row : data->rows[data->row_count - 1];
     int position = current_row->row_position;
 
     char *var = malloc(strlen(value) + 1);
@@ -151,7 +170,11 @@ int handle_value(int obs_index, readstat_variable_t *variable, readstat_value_t
     int var_index = readstat_variable_get_index(variable);
 
     if (var_index == 0) {
-        add_new_row(data);
+        if (data->streaming) {
+            reset_stream_row(data);
+        } else {
+            add_new_row(data);
+        }
     }
 
     readstat_type_t type = readstat_value_type(value);
@@ -225,7 +248,14 @@ int handle_value(int obs_index, readstat_variable_t *variable, readstat_value_t
             break;
 
         default:
-            return READSTAT_HANDLER_OK;
+            break;
+    }
+
+    // the row is complete once the last variable's value has been read
+    if (data->streaming && var_index == data->header_count - 1) {
+        if (goSavRow(data->handle, data) != 0) {
+            return READSTAT_HANDLER_ABORT;
+        }
     }
 
     return READSTAT_HANDLER_OK;
@@ -262,11 +292,20 @@ void cleanup(struct Data *data) {
    }
 
    if (data->rows != NULL) free(data->rows);
+
+   if (data->row != NULL) {
+       for (int j = 0; j < data->row->row_length; j++) {
+          if (data->row->row_data[j] != 0) free(data->row->row_data[j]);
+       }
+       free(data->row->row_data);
+       free(data->row);
+   }
+
    if (data->buffer != NULL) free(data->buffer);
 
 }
 
-struct Data *parse_sav(const char *input_file) {
+struct Data *read_sav(const char *input_file, bool streaming, uintptr_t handle) {
 
     if (input_file == 0) {
         return NULL;
@@ -292,14 +331,32 @@ struct Data *parse_sav(const char *input_file) {
     sav_data->labels = NULL;
     sav_data->labels_count = 0;
 
+    sav_data->streaming = streaming;
+    sav_data->handle = handle;
+    sav_data->row = NULL;
+
     error = readstat_parse_sav(parser, input_file, sav_data);
 
     readstat_parser_free(parser);
 
     if (error != READSTAT_OK) {
+      cleanup(sav_data);
+      free(sav_data);
       return NULL;
     }
 
     return sav_data;
 
 }
+
+struct Data *parse_sav(const char *input_file) {
+    return read_sav(input_file, false, 0);
+}
+
+/*
+Read a sav file without keeping its rows. Each row is passed to goSavRow as soon as it has been read, so only
+the header and value labels are held in the Data returned.
+*/
+struct Data *stream_sav(const char *input_file, uintptr_t handle) {
+    return read_sav(input_file, true, handle);
+}
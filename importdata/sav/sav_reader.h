@@ -2,9 +2,11 @@
 #define _SAV_READER_H
 
 #include <stdbool.h>
+#include <stdint.h>
 #include "readstat.h"
 
 struct Data* parse_sav(const char *input_file);
+struct Data* stream_sav(const char *input_file, uintptr_t handle);
 void cleanup(struct Data*);
 
 struct Header {
@@ -53,7 +55,15 @@ struct Data {
     unsigned long buffer_size;
 
     int variable_count;
+
+    // when streaming, rows are passed to goSavRow as they are read instead of being kept
+    bool streaming;
+    uintptr_t handle;
+    struct Rows *row;
 };
 
+// implemented in Go, returns non zero to stop reading
+extern int goSavRow(uintptr_t handle, struct Data *data);
+
 
 #endif
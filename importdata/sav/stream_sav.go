@@ -0,0 +1,124 @@
+package sav
+
+// #include <stdlib.h>
+// #include "sav_reader.h"
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"services/types"
+	"sync"
+	"unsafe"
+)
+
+type savStream struct {
+	handler types.RowHandler
+	header  bool
+	err     error
+}
+
+// streams in progress, keyed by the handle passed through readstat to goSavRow
+var streams = struct {
+	sync.Mutex
+	next    uintptr
+	streams map[uintptr]*savStream
+}{streams: make(map[uintptr]*savStream)}
+
+func registerStream(s *savStream) uintptr {
+	streams.Lock()
+	defer streams.Unlock()
+	streams.next++
+	streams.streams[streams.next] = s
+	return streams.next
+}
+
+func lookupStream(handle uintptr) *savStream {
+	streams.Lock()
+	defer streams.Unlock()
+	return streams.streams[handle]
+}
+
+func removeStream(handle uintptr) {
+	streams.Lock()
+	defer streams.Unlock()
+	delete(streams.streams, handle)
+}
+
+/*
+StreamSav reads a sav file a row at a time, passing each row to the handler rather than holding the file in
+memory. The data returned has the file's header, value labels and row count but no rows.
+*/
+func StreamSav(fileName string, handler types.RowHandler) (types.SavImportData, error) {
+
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return types.SavImportData{}, fmt.Errorf(" -> Import: file %s not found", fileName)
+	}
+
+	stream := &savStream{handler: handler}
+	handle := registerStream(stream)
+	defer removeStream(handle)
+
+	name := C.CString(fileName)
+	defer C.free(unsafe.Pointer(name))
+
+	var res = C.stream_sav(name, C.uintptr_t(handle))
+
+	if stream.err != nil {
+		if res != nil {
+			C.cleanup(res)
+			C.free(unsafe.Pointer(res))
+		}
+		return types.SavImportData{}, stream.err
+	}
+
+	if res == nil {
+		return types.SavImportData{}, errors.New("read from sav file failed")
+	}
+
+	defer func() {
+		C.cleanup(res)
+		C.free(unsafe.Pointer(res))
+	}()
+
+	v := C.struct_Data(*res)
+
+	savImportData := types.SavImportData{
+		Header:      readHeader(&v),
+		HeaderCount: int(v.header_count),
+		Labels:      readLabels(&v),
+		LabelsCount: int(v.labels_count),
+		RowCount:    int(v.row_count),
+	}
+
+	// a file without rows never reaches goSavRow
+	if !stream.header {
+		if err := handler.Header(savImportData.Header); err != nil {
+			return types.SavImportData{}, err
+		}
+	}
+
+	return savImportData, nil
+}
+
+//export goSavRow
+func goSavRow(handle C.uintptr_t, data *C.struct_Data) C.int {
+	stream := lookupStream(uintptr(handle))
+	if stream == nil {
+		return 1
+	}
+
+	if !stream.header {
+		stream.header = true
+		if stream.err = stream.handler.Header(readHeader(data)); stream.err != nil {
+			return 1
+		}
+	}
+
+	if stream.err = stream.handler.Row(readRow(data.row)); stream.err != nil {
+		return 1
+	}
+
+	return 0
+}
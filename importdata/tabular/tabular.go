@@ -0,0 +1,238 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math"
+	"os"
+	"services/types"
+	"strconv"
+	"strings"
+)
+
+/*
+Schema types the columns of a CSV or Excel file, which unlike a SAV file has no dictionary of its own. Each
+column takes its type, description, length, precision and label set from its variable definition, so loading
+the file leaves the definitions as they are. A column without a definition is read as a string.
+*/
+type Schema map[string]types.VariableDefinitions
+
+func NewSchema(definitions []types.VariableDefinitions) Schema {
+	s := make(Schema, len(definitions))
+	for _, d := range definitions {
+		s[strings.ToUpper(d.Variable)] = d
+	}
+	return s
+}
+
+func (s Schema) header(names []string) ([]types.Header, error) {
+	header := make([]types.Header, len(names))
+	seen := make(map[string]bool, len(names))
+	var undefined []string
+
+	for i, n := range names {
+		name := strings.ToUpper(strings.TrimSpace(n))
+		if name == "" {
+			return nil, fmt.Errorf("column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %s appears more than once", name)
+		}
+		seen[name] = true
+
+		d, ok := s[name]
+		if !ok {
+			undefined = append(undefined, name)
+			header[i] = types.Header{VariableName: name, VariableType: types.TypeString}
+			continue
+		}
+
+		header[i] = types.Header{
+			VariableName:        name,
+			VariableDescription: d.Description.String,
+			VariableType:        d.VariableType,
+			VariableLength:      d.VariableLength,
+			VariablePrecision:   d.Precision,
+			LabelName:           strings.ToUpper(d.Label.String),
+		}
+	}
+
+	if len(undefined) > 0 {
+		log.Warn().
+			Strs("variables", undefined).
+			Msg("Variables without a definition are read as strings")
+	}
+
+	return header, nil
+}
+
+/*
+A value as the SAV reader gives it: a missing number is NaN and whole numbers have no decimal places
+*/
+func value(h types.Header, raw string) (string, error) {
+	if h.VariableType == types.TypeString {
+		return strings.TrimRight(raw, " "), nil
+	}
+
+	v := strings.TrimSpace(raw)
+	if v == "" || v == "." || strings.EqualFold(v, "NaN") {
+		return "NaN", nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return "", fmt.Errorf("column %s: %q is not a number", h.VariableName, raw)
+	}
+
+	switch h.VariableType {
+	case types.TypeInt8, types.TypeInt16, types.TypeInt32:
+		if f != math.Trunc(f) {
+			return "", fmt.Errorf("column %s: %q is not a whole number", h.VariableName, raw)
+		}
+		return strconv.FormatInt(int64(f), 10), nil
+	}
+
+	return v, nil
+}
+
+// a file read a record at a time, the first record naming the columns
+type source func(record func(fields []string) error) error
+
+func read(schema Schema, src source, handler types.RowHandler) (types.SavImportData, error) {
+	var header []types.Header
+	line := 0
+	rows := 0
+
+	err := src(func(fields []string) error {
+		line++
+
+		if header == nil {
+			h, err := schema.header(fields)
+			if err != nil {
+				return err
+			}
+			header = h
+			return handler.Header(header)
+		}
+
+		empty := true
+		for _, f := range fields {
+			if strings.TrimSpace(f) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			return nil
+		}
+
+		if len(fields) > len(header) {
+			for _, f := range fields[len(header):] {
+				if strings.TrimSpace(f) != "" {
+					return fmt.Errorf("row %d has %d values, expected %d", line, len(fields), len(header))
+				}
+			}
+		}
+
+		row := make([]string, len(header))
+		for i, h := range header {
+			raw := ""
+			if i < len(fields) {
+				raw = fields[i]
+			}
+			v, err := value(h, raw)
+			if err != nil {
+				return fmt.Errorf("row %d, %s", line, err)
+			}
+			row[i] = v
+		}
+
+		rows++
+		return handler.Row(row)
+	})
+
+	if err != nil {
+		return types.SavImportData{}, err
+	}
+
+	if header == nil {
+		return types.SavImportData{}, fmt.Errorf("the file has no header row")
+	}
+
+	return types.SavImportData{Header: header, HeaderCount: len(header), RowCount: rows}, nil
+}
+
+// CSVReader streams a CSV file with a header row
+func CSVReader(fileName string, schema Schema) types.RowReader {
+	return func(handler types.RowHandler) (types.SavImportData, error) {
+		return read(schema, func(record func([]string) error) error {
+			f, err := os.Open(fileName)
+			if err != nil {
+				return fmt.Errorf("cannot open CSV file: %s", err)
+			}
+			defer func() { _ = f.Close() }()
+
+			r := csv.NewReader(f)
+			r.ReuseRecord = true
+
+			first := true
+			for {
+				fields, err := r.Read()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				// files saved by Excel start with a byte order mark
+				if first {
+					fields[0] = strings.TrimPrefix(fields[0], "\ufeff")
+					first = false
+				}
+				if err := record(fields); err != nil {
+					return err
+				}
+			}
+		}, handler)
+	}
+}
+
+/*
+XLSXReader reads the first sheet of an Excel file with a header row. The rows are read one at a time but the
+file itself is opened in memory.
+*/
+func XLSXReader(fileName string, schema Schema) types.RowReader {
+	return func(handler types.RowHandler) (types.SavImportData, error) {
+		return read(schema, func(record func([]string) error) error {
+			f, err := excelize.OpenFile(fileName)
+			if err != nil {
+				return fmt.Errorf("cannot open Excel file: %s", err)
+			}
+
+			sheets := f.GetSheetList()
+			if len(sheets) == 0 {
+				return fmt.Errorf("the Excel file has no sheets")
+			}
+
+			rows, err := f.Rows(sheets[0])
+			if err != nil {
+				return err
+			}
+
+			for rows.Next() {
+				fields, err := rows.Columns()
+				if err != nil {
+					return err
+				}
+				if err := record(fields); err != nil {
+					return err
+				}
+			}
+
+			return rows.Error()
+		}, handler)
+	}
+}
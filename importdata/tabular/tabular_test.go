@@ -0,0 +1,111 @@
+package tabular
+
+import (
+	"database/sql"
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"services/types"
+	"strings"
+	"testing"
+)
+
+type collector struct {
+	header []types.Header
+	rows   [][]string
+}
+
+func (c *collector) Header(header []types.Header) error {
+	c.header = header
+	return nil
+}
+
+func (c *collector) Row(row []string) error {
+	r := make([]string, len(row))
+	copy(r, row)
+	c.rows = append(c.rows, r)
+	return nil
+}
+
+func testSchema() Schema {
+	return NewSchema([]types.VariableDefinitions{
+		{Variable: "SEX", VariableType: types.TypeInt8, Label: sql.NullString{String: "labels0", Valid: true}},
+		{Variable: "PWT", VariableType: types.TypeDouble, Description: sql.NullString{String: "Person weight", Valid: true}},
+		{Variable: "POSTCODE", VariableType: types.TypeString, VariableLength: 8},
+	})
+}
+
+var expectedRows = [][]string{
+	{"1", "1234.5", "NP10 8XG", "A"},
+	{"NaN", "NaN", "", ""},
+}
+
+func checkImport(t *testing.T, c *collector, data types.SavImportData) {
+	if data.RowCount != 2 || data.HeaderCount != 4 {
+		t.Fatalf("expected 2 rows and 4 columns, got %d and %d", data.RowCount, data.HeaderCount)
+	}
+
+	if c.header[0].VariableName != "SEX" || c.header[0].VariableType != types.TypeInt8 || c.header[0].LabelName != "LABELS0" {
+		t.Errorf("SEX not typed from its definition: %+v", c.header[0])
+	}
+	if c.header[1].VariableDescription != "Person weight" {
+		t.Errorf("PWT description not taken from its definition: %+v", c.header[1])
+	}
+	if c.header[3].VariableName != "NEWVAR" || c.header[3].VariableType != types.TypeString {
+		t.Errorf("an undefined variable should be a string: %+v", c.header[3])
+	}
+
+	if !reflect.DeepEqual(c.rows, expectedRows) {
+		t.Errorf("expected rows %v, got %v", expectedRows, c.rows)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "survey.csv")
+	csv := "\ufeffsex,PWT,postcode,newvar\n1.0,1234.5,NP10 8XG,A\n,,,\n ,NaN,,\n"
+	if err := ioutil.WriteFile(fileName, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{}
+	data, err := CSVReader(fileName, testSchema())(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImport(t, c, data)
+}
+
+func TestXLSXReader(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "survey.xlsx")
+
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"SEX", "PWT", "POSTCODE", "NEWVAR"})
+	_ = f.SetSheetRow(sheet, "A2", &[]interface{}{1, 1234.5, "NP10 8XG", "A"})
+	_ = f.SetSheetRow(sheet, "A4", &[]interface{}{"", "NaN"})
+	if err := f.SaveAs(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{}
+	data, err := XLSXReader(fileName, testSchema())(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImport(t, c, data)
+}
+
+func TestCSVReaderInvalidNumber(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "survey.csv")
+	if err := ioutil.WriteFile(fileName, []byte("SEX,PWT\n1,heavy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := CSVReader(fileName, testSchema())(&collector{})
+	if err == nil || !strings.Contains(err.Error(), "row 2, column PWT") {
+		t.Errorf("expected an error for row 2, column PWT, got %v", err)
+	}
+}
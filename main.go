@@ -9,9 +9,10 @@ import (
 	"net/http"
 	"os"
 	"services/api"
+	"services/api/jobs"
 	"services/api/ws"
 	"services/config"
-	"services/util"
+	"services/types"
 	"time"
 )
 
@@ -31,7 +32,7 @@ func main() {
 	flag.Parse()
 	if *debug || config.Config.LogLevel == "Debug" {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-		router.Use(loggingMiddleware)
+		router.Use(api.LoggingMiddleware)
 	} else {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
@@ -40,53 +41,124 @@ func main() {
 		Str("startTime", time.Now().String()).
 		Msg("LFS Imports: Starting up")
 
+	jobQueue := jobs.NewQueue()
+
 	batchHandler := api.NewBatchHandler()
 	dashboardHandler := api.NewDashboardHandler()
 	idHandler := api.NewIdHandler()
-	surveyHandler := api.NewSurveyHandler()
-	addressesHandler := api.NewAddressImportHandler()
+	surveyHandler := api.NewSurveyHandler(jobQueue)
+	addressesHandler := api.NewAddressImportHandler(jobQueue)
 	auditHandler := api.NewAuditHandler()
 	loginHandler := api.NewLoginHandler()
-	vdHandler := api.NewVariableDefinitionsHandler()
-	varLabHandler := api.NewValueLabelsHandler()
+	vdHandler := api.NewVariableDefinitionsHandler(jobQueue)
+	varLabHandler := api.NewValueLabelsHandler(jobQueue)
+	jobsHandler := api.NewJobsHandler(jobQueue)
+	uploadStatusHandler := api.NewUploadStatusHandler()
+	surveyVersionHandler := api.NewSurveyVersionHandler()
+	surveyQueryHandler := api.NewSurveyQueryHandler()
+	surveyTabulationHandler := api.NewSurveyTabulationHandler()
+	exportHandler := api.NewExportHandler()
+	exportDefinitionsHandler := api.NewExportDefinitionsHandler()
+	usersHandler := api.NewUsersHandler(loginHandler)
 
 	// Dashboard
-	router.HandleFunc("/dashboard", dashboardHandler.HandleDashboardRequest).Methods(http.MethodGet)
+	router.HandleFunc("/dashboard", api.Authorise(dashboardHandler.HandleDashboardRequest, types.Viewer)).Methods(http.MethodGet)
 
 	// Create New Batches Handlers
-	router.HandleFunc("/batches/monthly/{year}/{month}", batchHandler.CreateMonthlyBatchHandler).Methods(http.MethodPost)
-	router.HandleFunc("/batches/quarterly/{year}/{quarter}", batchHandler.CreateQuarterlyBatchHandler).Methods(http.MethodPost)
-	router.HandleFunc("/batches/annual/{year}", batchHandler.CreateAnnualBatchHandler).Methods(http.MethodPost)
+	router.HandleFunc("/batches/monthly/{year}/{month}", api.Authorise(batchHandler.CreateMonthlyBatchHandler, types.BatchManager)).Methods(http.MethodPost)
+	router.HandleFunc("/batches/monthly/{year}/{month}/uk", api.Authorise(batchHandler.HarmoniseMonthlyBatchHandler, types.BatchManager)).Methods(http.MethodPost)
+	router.HandleFunc("/batches/quarterly/{year}/{quarter}", api.Authorise(batchHandler.CreateQuarterlyBatchHandler, types.BatchManager)).Methods(http.MethodPost)
+	router.HandleFunc("/batches/annual/{year}", api.Authorise(batchHandler.CreateAnnualBatchHandler, types.BatchManager)).Methods(http.MethodPost)
 
 	// Batch info
-	router.HandleFunc("/batches/display/annual/{year}", idHandler.HandleAnnualBatchIdsRequest).Methods(http.MethodGet)
-	router.HandleFunc("/batches/display/quarterly/{year}/{quarter}", idHandler.HandleQuarterlyBatchIdsRequest).Methods(http.MethodGet)
-	router.HandleFunc("/batches/display/monthly/{year}/{month}", idHandler.HandleMonthlyBatchIdsRequest).Methods(http.MethodGet)
+	router.HandleFunc("/batches/display/annual/{year}", api.Authorise(idHandler.HandleAnnualBatchIdsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/batches/display/quarterly/{year}/{quarter}", api.Authorise(idHandler.HandleQuarterlyBatchIdsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/batches/display/monthly/{year}/{month}", api.Authorise(idHandler.HandleMonthlyBatchIdsRequest, types.Viewer)).Methods(http.MethodGet)
 
 	// Imports
-	router.HandleFunc("/imports/survey/gb/{year}/{week}", surveyHandler.SurveyUploadGBHandler).Methods(http.MethodPost)
-	router.HandleFunc("/imports/survey/ni/{year}/{month}", surveyHandler.SurveyUploadNIHandler).Methods(http.MethodPost)
-	router.HandleFunc("/imports/address", addressesHandler.AddressUploadHandler).Methods(http.MethodPost)
-	router.HandleFunc("/imports/variable/definitions", vdHandler.HandleRequestVariableUpload).Methods(http.MethodPost)
-	router.HandleFunc("/imports/value/labels/{source}", varLabHandler.HandleValLabRequestlUpload).Methods(http.MethodPost)
+	router.HandleFunc("/imports/survey/gb/{year}/{week}", api.Authorise(surveyHandler.SurveyUploadGBHandler, types.Uploader)).Methods(http.MethodPost)
+	router.HandleFunc("/imports/survey/ni/{year}/{month}", api.Authorise(surveyHandler.SurveyUploadNIHandler, types.Uploader)).Methods(http.MethodPost)
+	router.HandleFunc("/imports/address", api.Authorise(addressesHandler.AddressUploadHandler, types.Uploader)).Methods(http.MethodPost)
+	router.HandleFunc("/imports/variable/definitions", api.Authorise(vdHandler.HandleRequestVariableUpload, types.MetadataEditor)).Methods(http.MethodPost)
+	router.HandleFunc("/imports/value/labels/{source}", api.Authorise(varLabHandler.HandleValLabRequestlUpload, types.MetadataEditor)).Methods(http.MethodPost)
+	router.HandleFunc("/imports/export/definitions", api.Authorise(exportDefinitionsHandler.HandleImportRequest, types.MetadataEditor)).Methods(http.MethodPost)
+
+	// Upload jobs
+	router.HandleFunc("/jobs", api.Authorise(jobsHandler.HandleAllJobsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{id}", api.Authorise(jobsHandler.HandleJobRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{id}/retry", api.Authorise(jobsHandler.HandleRetryJobRequest, types.Uploader)).Methods(http.MethodPost)
+	router.HandleFunc("/jobs/{id}/cancel", api.Authorise(jobsHandler.HandleCancelJobRequest, types.Uploader)).Methods(http.MethodPost)
+
+	// Upload status
+	router.HandleFunc("/uploads/{jobId:[0-9]+}/status", api.Authorise(uploadStatusHandler.HandleUploadStatusRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/uploads/{fileName}/status", api.Authorise(uploadStatusHandler.HandleFileUploadStatusRequest, types.Viewer)).Methods(http.MethodGet)
+
+	// Survey versions
+	router.HandleFunc("/survey/versions/gb/{year}/{week}", api.Authorise(surveyVersionHandler.HandleGBVersionsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/versions/ni/{year}/{month}", api.Authorise(surveyVersionHandler.HandleNIVersionsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/versions/{versionId:[0-9]+}/diff/{otherId:[0-9]+}", api.Authorise(surveyVersionHandler.HandleDiffRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/versions/{versionId:[0-9]+}/rollback", api.Authorise(surveyVersionHandler.HandleRollbackRequest, types.BatchManager)).Methods(http.MethodPost)
+
+	// Survey queries
+	router.HandleFunc("/survey/{year:[0-9]{4}}", api.Authorise(surveyQueryHandler.HandleQueryRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/{year:[0-9]{4}}/tabulation", api.Authorise(surveyTabulationHandler.HandleTabulationRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/{year:[0-9]{4}}/{period}", api.Authorise(surveyQueryHandler.HandleQueryRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/survey/{year:[0-9]{4}}/{period}/tabulation", api.Authorise(surveyTabulationHandler.HandleTabulationRequest, types.Viewer)).Methods(http.MethodGet)
+
+	// Export Definitions
+	router.HandleFunc("/exports/definitions/audit", api.Authorise(exportDefinitionsHandler.HandleAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/exports/definitions", api.Authorise(exportDefinitionsHandler.HandleAllRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/exports/definitions", api.Authorise(exportDefinitionsHandler.HandleCreateRequest, types.MetadataEditor)).Methods(http.MethodPost)
+	router.HandleFunc("/exports/definitions/{variable}", api.Authorise(exportDefinitionsHandler.HandleVariableRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/exports/definitions/{variable}", api.Authorise(exportDefinitionsHandler.HandleUpdateRequest, types.MetadataEditor)).Methods(http.MethodPut)
+	router.HandleFunc("/exports/definitions/{variable}", api.Authorise(exportDefinitionsHandler.HandleDeleteRequest, types.MetadataEditor)).Methods(http.MethodDelete)
+
+	// Exports
+	router.HandleFunc("/exports/{audience}/labels", api.Authorise(exportHandler.HandleLabelsRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/exports/{audience}/{period}", api.Authorise(exportHandler.HandleExportRequest, types.Viewer)).Methods(http.MethodGet)
 
 	// Audits
-	router.HandleFunc("/audits", auditHandler.HandleAllAuditRequest).Methods(http.MethodGet)
-	router.HandleFunc("/audits/year/{year}", auditHandler.HandleYearAuditRequest).Methods(http.MethodGet)
-	router.HandleFunc("/audits/month/{year}/{month}", auditHandler.HandleMonthAuditRequest).Methods(http.MethodGet)
-	router.HandleFunc("/audits/week/{year}/{week}", auditHandler.HandleWeekAuditRequest).Methods(http.MethodGet)
+	router.HandleFunc("/audits", api.Authorise(auditHandler.HandleAllAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/logins", api.Authorise(auditHandler.HandleLoginAuditRequest, types.Admin)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/batches", api.Authorise(auditHandler.HandleBatchAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/metadata", api.Authorise(auditHandler.HandleMetadataAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/year/{year}", api.Authorise(auditHandler.HandleYearAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/month/{year}/{month}", api.Authorise(auditHandler.HandleMonthAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/week/{year}/{week}", api.Authorise(auditHandler.HandleWeekAuditRequest, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/audits/{auditId:[0-9]+}/validation", api.Authorise(auditHandler.HandleValidationReportRequest, types.Viewer)).Methods(http.MethodGet)
 
 	// Variable Definitions
-	router.HandleFunc("/variable/definitions/{variable}", vdHandler.HandleRequestVariable).Methods(http.MethodGet)
-	router.HandleFunc("/variable/definitions", vdHandler.HandleRequestAll).Methods(http.MethodGet)
+	router.HandleFunc("/variable/definitions/{variable}", api.Authorise(vdHandler.HandleRequestVariable, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/variable/definitions", api.Authorise(vdHandler.HandleRequestAll, types.Viewer)).Methods(http.MethodGet)
 
 	// Value labels
-	router.HandleFunc("/value/labels/{value}", varLabHandler.HandleValLabRequestValue).Methods(http.MethodGet)
-	router.HandleFunc("/value/labels", varLabHandler.HandleValLabRequestAll).Methods(http.MethodGet)
+	router.HandleFunc("/value/labels/{value}", api.Authorise(varLabHandler.HandleValLabRequestValue, types.Viewer)).Methods(http.MethodGet)
+	router.HandleFunc("/value/labels", api.Authorise(varLabHandler.HandleValLabRequestAll, types.Viewer)).Methods(http.MethodGet)
 
-	// Other
+	// Login
 	router.HandleFunc("/login/{user}", loginHandler.LoginHandler).Methods(http.MethodGet)
-	router.HandleFunc("/ws", ws.WebSocketHandler{}.ServeWs).Methods(http.MethodGet)
+	router.HandleFunc("/refresh", loginHandler.RefreshHandler).Methods(http.MethodPost)
+	router.HandleFunc("/logout", loginHandler.LogoutHandler).Methods(http.MethodPost)
+	router.HandleFunc("/password", usersHandler.HandleChangePasswordRequest).Methods(http.MethodPut)
+
+	// Users
+	router.HandleFunc("/users", api.Authorise(usersHandler.HandleAllRequest, types.Admin)).Methods(http.MethodGet)
+	router.HandleFunc("/users", api.Authorise(usersHandler.HandleCreateRequest, types.Admin)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{user}", api.Authorise(usersHandler.HandleUserRequest, types.Admin)).Methods(http.MethodGet)
+	router.HandleFunc("/users/{user}/roles", api.Authorise(usersHandler.HandleRolesRequest, types.Admin)).Methods(http.MethodPut)
+	router.HandleFunc("/users/{user}/password", api.Authorise(usersHandler.HandleResetPasswordRequest, types.Admin)).Methods(http.MethodPut)
+	router.HandleFunc("/users/{user}/disable", api.Authorise(usersHandler.HandleDisableRequest, types.Admin)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{user}/enable", api.Authorise(usersHandler.HandleEnableRequest, types.Admin)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{user}/unlock", api.Authorise(usersHandler.HandleUnlockRequest, types.Admin)).Methods(http.MethodPost)
+
+	// Other
+	router.HandleFunc("/ws", api.Authorise(ws.WebSocketHandler{}.ServeWs, types.Viewer)).Methods(http.MethodGet)
+
+	// every route but login needs the token issued at login, and the routes above check the roles of its user
+	router.Use(loginHandler.Authenticate)
+
+	// workers are started once every handler has registered its job processor
+	jobQueue.Start()
 
 	listenAddress := config.Config.Service.ListenAddress
 
@@ -131,22 +203,3 @@ func main() {
 		Str("service", "LFS").
 		Msgf("ListenAndServe failed")
 }
-
-func loggingMiddleware(next http.Handler) http.Handler {
-
-	log.Info().Msg("Logging middleware registered")
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Debug().
-			Str("URI:", r.RequestURI).
-			Str("client", r.RemoteAddr).
-			Msg("-> Received request")
-		startTime := time.Now()
-		next.ServeHTTP(w, r)
-
-		log.Debug().
-			Str("URI:", r.RequestURI).
-			Str("elapsedTime", util.FmtDuration(startTime)).
-			Msg("<- Request Completed")
-	})
-}
@@ -1,12 +1,24 @@
+drop view if exists survey_current;
+drop table if exists upload_jobs;
+drop table if exists upload_status;
 drop table if exists addresses;
+drop table if exists auth_audit;
+drop table if exists sessions;
+drop table if exists user_roles;
 drop table if exists users;
+drop table if exists export_definitions_audit;
 drop table if exists export_definitions;
+drop table if exists batch_conflicts;
+drop table if exists batch_audit;
+drop table if exists batch_survey;
 drop table if exists annual_batch;
 drop table if exists quarterly_batch;
 drop table if exists survey;
+drop table if exists survey_version;
 drop table if exists ni_batch_item;
 drop table if exists gb_batch_items;
 drop table if exists monthly_batch;
+drop table if exists validation_report;
 drop table if exists survey_audit;
 drop table if exists status_values;
 drop table if exists definitions;
@@ -105,6 +117,23 @@ create table export_definitions
 alter table export_definitions
     owner to lfs;
 
+-- changes made to the audience flags of export_definitions
+create table export_definitions_audit
+(
+    id         integer generated always as identity primary key,
+    variable   varchar(10) not null,
+    action     varchar(16) not null,
+    changes    text        not null default '',
+    changed_by text        not null,
+    changed_at timestamp   not null default NOW()
+);
+
+create index export_definitions_audit_variable_idx
+    on export_definitions_audit (variable, changed_at);
+
+alter table export_definitions_audit
+    owner to lfs;
+
 create table status_values
 (
     id          integer primary key,
@@ -126,6 +155,9 @@ values (2, 'File Reloaded');
 insert into status_values(id, description)
 values (3, 'Upload Failed');
 
+insert into status_values(id, description)
+values (4, 'Version Rolled Back');
+
 create table monthly_batch
 (
     id          integer generated always as identity primary key,
@@ -133,6 +165,7 @@ create table monthly_batch
     year        integer           not null,
     status      integer default 0 not null,
     description text,
+    created_by  text    default '' not null,
     foreign key (status) references status_values (id)
 );
 
@@ -159,6 +192,7 @@ create table annual_batch
     year        integer null,
     status      integer null,
     description text    null,
+    created_by  text    not null default '',
 
     foreign key (status) references status_values (id)
 );
@@ -170,6 +204,7 @@ create table quarterly_batch
     year        integer,
     status      integer,
     description text,
+    created_by  text    not null default '',
 
     foreign key (status) references status_values (id)
 );
@@ -177,6 +212,65 @@ create table quarterly_batch
 alter table quarterly_batch
     owner to lfs;
 
+-- the combined GB and NI dataset of each batch: the UK dataset of a month or the dataset of a quarter or year
+create table batch_survey
+(
+    row_id      bigint generated always as identity primary key,
+    batch_type  varchar(10) not null,
+    batch_id    integer     not null,
+    file_source char(2)     not null,
+    columns     jsonb       not null
+);
+
+create index batch_survey_batch_idx
+    on batch_survey (batch_type, batch_id);
+
+alter table batch_survey
+    owner to lfs;
+
+-- the rows and variables that went into the dataset of each batch
+create table batch_audit
+(
+    id           integer generated always as identity primary key,
+    batch_type   varchar(10) not null,
+    batch_id     integer     not null,
+    year         integer     not null,
+    period       integer     not null default 0,
+    gb_rows      integer     not null default 0,
+    ni_rows      integer     not null default 0,
+    duplicates   integer     not null default 0,
+    rows         integer     not null default 0,
+    variables    integer     not null default 0,
+    converted    integer     not null default 0,
+    assembled_at timestamp   not null default NOW(),
+    assembled_by text        not null default ''
+);
+
+create index batch_audit_batch_idx
+    on batch_audit (batch_type, batch_id);
+
+alter table batch_audit
+    owner to lfs;
+
+-- the differences between GB and NI data settled in assembling each batch dataset
+create table batch_conflicts
+(
+    id       integer generated always as identity primary key,
+    audit_id integer      not null,
+    variable varchar(255) not null,
+    source   char(2)      not null,
+    conflict text         not null,
+    rows     integer      not null default 0,
+
+    foreign key (audit_id) references batch_audit (id) on delete cascade
+);
+
+create index batch_conflicts_audit_idx
+    on batch_conflicts (audit_id);
+
+alter table batch_conflicts
+    owner to lfs;
+
 create table gb_batch_items
 (
     id     integer not null,
@@ -195,12 +289,14 @@ alter table gb_batch_items
 
 create table survey
 (
+    row_id      bigint generated always as identity primary key,
     id          integer      not null,
     file_name   varchar(255) not null,
     file_source char(2),
     week        integer      not null,
     month       integer      not null,
     year        integer      not null,
+    version_id  integer      not null,
     columns     jsonb        not null,
 
     foreign key (week, id) references gb_batch_items (week, id) on delete cascade,
@@ -219,8 +315,12 @@ create index survey_period_idx
 create index survey_columns_idx
     on survey using gin (columns);
 
+create index survey_version_id_idx
+    on survey (version_id);
+
 create table survey_audit
 (
+    audit_id       integer generated always as identity primary key,
     id             integer       not null,
     file_name      varchar(1024) not null,
     file_source    char(2)       not null,
@@ -234,6 +334,8 @@ create table survey_audit
     num_ob_loaded  integer       not null default 0,
     status         integer       not null,
     message        text          null,
+    skip_counts    jsonb         not null default '[]',
+    username       text          not null default '',
 
     foreign key (status) references status_values (id)
 );
@@ -241,15 +343,182 @@ create table survey_audit
 create index survey_audit_file_name_idx
     on survey_audit (file_name);
 
+create index survey_audit_username_idx
+    on survey_audit (username);
+
+-- each load of a GB week or NI month is kept as a new version. Only one version of a period is current.
+create table survey_version
+(
+    version_id  integer generated always as identity primary key,
+    id          integer      not null,
+    file_name   varchar(255) not null,
+    file_source char(2)      not null,
+    week        integer      not null,
+    month       integer      not null,
+    year        integer      not null,
+    version     integer      not null,
+    is_current  boolean      not null default false,
+    rows        integer      not null default 0,
+    audit_id    integer      not null,
+    loaded_at   timestamp    not null,
+
+    unique (file_source, year, month, week, version),
+    foreign key (audit_id) references survey_audit (audit_id)
+);
+
+create unique index survey_version_current_idx
+    on survey_version (file_source, year, month, week) where is_current;
+
+alter table survey_version
+    owner to lfs;
+
+alter table survey
+    add foreign key (version_id) references survey_version (version_id) on delete cascade;
+
+-- the survey rows of the current version of each period
+create view survey_current as
+select s.*
+from survey s
+         join survey_version v on v.version_id = s.version_id
+where v.is_current;
+
+create table validation_report
+(
+    id          integer generated always as identity primary key,
+    audit_id    integer      not null,
+    rule_name   varchar(255) not null default '',
+    rule        varchar(64)  not null,
+    column_name varchar(255) not null default '',
+    row_number  integer      not null default 0,
+    caseno      varchar(32)  not null default '',
+    value       text         not null default '',
+    message     text         not null default '',
+
+    foreign key (audit_id) references survey_audit (audit_id) on delete cascade
+);
+
+create index validation_report_audit_idx
+    on validation_report (audit_id, row_number);
+
+alter table validation_report
+    owner to lfs;
+
+create table upload_jobs
+(
+    id            integer generated always as identity primary key,
+    job_type      varchar(32)   not null,
+    file_name     varchar(1024) not null,
+    file_path     text          not null,
+    file_source   varchar(2)    not null default '',
+    batch_id      integer       not null default 0,
+    week          integer       not null default 0,
+    month         integer       not null default 0,
+    year          integer       not null default 0,
+    status        varchar(16)   not null default 'queued',
+    attempts      integer       not null default 0,
+    error_message text          not null default '',
+    created_at    timestamp     not null default NOW(),
+    started_at    timestamp     null,
+    finished_at   timestamp     null,
+    heartbeat     timestamp     null,
+    submitted_by  text          not null default '',
+    result        jsonb         null
+);
+
+create index upload_jobs_status_idx
+    on upload_jobs (status, id);
+
+-- only one job of a type runs for a period at a time
+create unique index upload_jobs_running_idx
+    on upload_jobs (job_type, year, month, week)
+    where status = 'running';
+
+alter table upload_jobs
+    owner to lfs;
+
+create table upload_status
+(
+    job_id        integer       primary key,
+    file_name     varchar(1024) not null,
+    percentage    float8        not null default 0,
+    status        integer       not null default 0,
+    error_message text          not null default '',
+    rows_in_file  integer       not null default 0,
+    rows_loaded   integer       not null default 0,
+    updated_at    timestamp     not null default NOW()
+);
+
+create index upload_status_file_name_idx
+    on upload_status (file_name, job_id);
+
+alter table upload_status
+    owner to lfs;
+
 create table users
 (
-    username text primary key,
-    password text not null
+    username            text primary key,
+    password            text      not null,
+    disabled            boolean   not null default false,
+    created_at          timestamp not null default (now() at time zone 'utc'),
+    password_changed_at timestamp not null default (now() at time zone 'utc'),
+    last_login          timestamp,
+    failed_logins       integer   not null default 0,
+    locked_at           timestamp
 );
 
 alter table users
     owner to lfs;
 
+-- the roles of each user: viewer, uploader, batch-manager, metadata-editor or admin
+create table user_roles
+(
+    username text        not null,
+    role     varchar(20) not null,
+
+    primary key (username, role),
+    foreign key (username) references users (username) on delete cascade
+);
+
+alter table user_roles
+    owner to lfs;
+
+-- the tokens issued at login. Only a hash of each token is kept
+create table sessions
+(
+    token_hash char(64) primary key,
+    username   text      not null,
+    created_at timestamp not null,
+    expires_at timestamp not null,
+
+    foreign key (username) references users (username) on delete cascade
+);
+
+create index sessions_username_idx
+    on sessions (username);
+
+alter table sessions
+    owner to lfs;
+
+-- every login, failed login, lockout, unlock and logout. The username is kept even when no such user exists
+create table auth_audit
+(
+    id          integer generated always as identity primary key,
+    username    text        not null,
+    client      text        not null,
+    event       varchar(20) not null,
+    message     text        not null default '',
+    occurred_at timestamp   not null
+);
+
+create index auth_audit_username_idx
+    on auth_audit (username, occurred_at);
+
+create index auth_audit_client_idx
+    on auth_audit (client, occurred_at);
+
+alter table auth_audit
+    owner to lfs;
+
 CREATE TYPE spss_types AS ENUM ('string', 'int8', 'int16', 'int32', 'float', 'double');
 
 create table value_labels
@@ -260,7 +529,8 @@ create table value_labels
     value        int8       not null,
     source       varchar(2) not null,
     type         spss_types not null default 'string',
-    last_updated timestamp           default NOW()
+    last_updated timestamp           default NOW(),
+    updated_by   text       not null default ''
 );
 
 create index labels_name_idx
@@ -284,7 +554,8 @@ create table variable_definitions
     alias       text,
     editable    bool                default false,
     imputation  bool                default false,
-    dv          bool                default false
+    dv          bool                default false,
+    changed_by  text       not null default ''
 
 --     foreign key (label) references value_labels (name)
 );
@@ -301,7 +572,8 @@ select vd.variable,
        vl.source,
        vl.value label_value,
        vl.label label_description,
-       vl.last_updated
+       vl.last_updated,
+       vl.updated_by
 from variable_definitions vd,
      value_labels vl
 where vl.name = vd.label
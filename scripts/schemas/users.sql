@@ -1,2 +1,4 @@
 insert into users(username, password)
-values ('Admin', '$2a$04$Su7c9o6E9pLaGut2Nv9FqO2ZUbntDmUweOlO/Vj3hczi86qrnbKK2');
\ No newline at end of file
+values ('Admin', '$2a$04$Su7c9o6E9pLaGut2Nv9FqO2ZUbntDmUweOlO/Vj3hczi86qrnbKK2');
+insert into user_roles(username, role)
+values ('Admin', 'admin');
@@ -1,6 +1,11 @@
 package types
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type AuditStatus int
 
@@ -9,9 +14,11 @@ const (
 	FileUploaded AuditStatus = 1
 	FileReloaded AuditStatus = 2
 	UploadFailed AuditStatus = 3
+	RolledBack   AuditStatus = 4
 )
 
 type Audit struct {
+	AuditId       int         `db:"audit_id,omitempty" json:"auditId"`
 	Id            int         `db:"id" json:"id"`
 	FileName      string      `db:"file_name" json:"fileName"`
 	FileSource    FileSource  `db:"file_source" json:"fileSource"`
@@ -25,6 +32,42 @@ type Audit struct {
 	NumObLoaded   int         `db:"num_ob_loaded" json:"numObLoaded"`
 	Status        AuditStatus `db:"status" json:"status"`
 	Message       string      `db:"message" json:"message"`
+	SkipCounts    SkipCounts  `db:"skip_counts" json:"skipCounts"`
+	Username      string      `db:"username" json:"username"`
+}
+
+// SkipCount is the number of rows a skip rule dropped from a file
+type SkipCount struct {
+	Rule string `json:"rule"`
+	Skip string `json:"skip"`
+	Rows int    `json:"rows"`
+}
+
+// SkipCounts is stored as a JSON array, in the order the rules were applied
+type SkipCounts []SkipCount
+
+func (s SkipCounts) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *SkipCounts) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("cannot scan %T into SkipCounts", src)
 }
 
 type ErrorResponse struct {
@@ -35,3 +78,20 @@ type ErrorResponse struct {
 type OkayResponse struct {
 	Status string `json:"status"`
 }
+
+// the metadata a change was made to
+const (
+	VariableDefinitionChange = "variable_definitions"
+	ValueLabelsChange        = "value_labels"
+)
+
+/*
+MetadataChange is the loading of the variable definition or value labels Name of a source, when and by whom
+*/
+type MetadataChange struct {
+	Kind      string     `db:"kind" json:"kind"`
+	Name      string     `db:"name" json:"name"`
+	Source    string     `db:"source" json:"source"`
+	ChangedAt *time.Time `db:"changed_at" json:"changedAt"`
+	ChangedBy string     `db:"changed_by" json:"changedBy"`
+}
@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+type AuthEvent string
+
+const (
+	LoginSucceeded  AuthEvent = "login"
+	LoginFailed     AuthEvent = "login-failed"
+	LoginThrottled  AuthEvent = "login-throttled"
+	AccountLocked   AuthEvent = "locked"
+	AccountUnlocked AuthEvent = "unlocked"
+	Logout          AuthEvent = "logout"
+)
+
+// AuthAudit is a login, failed login, lockout, unlock or logout of a username from a client address
+type AuthAudit struct {
+	Id         int       `db:"id,omitempty" json:"id"`
+	Username   string    `db:"username" json:"username"`
+	Client     string    `db:"client" json:"client"`
+	Event      AuthEvent `db:"event" json:"event"`
+	Message    string    `db:"message" json:"message,omitempty"`
+	OccurredAt time.Time `db:"occurred_at" json:"occurredAt"`
+}
+
+// AuthAuditQuery selects the latest authentication events, of a username or client if set
+type AuthAuditQuery struct {
+	Username string
+	Client   string
+	Event    AuthEvent
+	Limit    int
+}
+
+/*
+LoginFailures counts the recent failed logins of a username, since it last logged in or was unlocked, and of a
+client, with the time of the latest of each
+*/
+type LoginFailures struct {
+	User       int
+	UserLast   *time.Time
+	Client     int
+	ClientLast *time.Time
+}
@@ -6,6 +6,7 @@ type MonthlyBatch struct {
 	Month       int    `db:"month"`
 	Status      int    `db:"status"`
 	Description string `db:"description"`
+	CreatedBy   string `db:"created_by"`
 }
 
 type GBBatchItem struct {
@@ -29,6 +30,7 @@ type QuarterlyBatch struct {
 	Year        int    `db:"year"`
 	Status      int    `db:"status"`
 	Description string `db:"description"`
+	CreatedBy   string `db:"created_by"`
 }
 
 type AnnualBatch struct {
@@ -36,4 +38,5 @@ type AnnualBatch struct {
 	Year        int    `db:"year"`
 	Status      int    `db:"status"`
 	Description string `db:"description"`
+	CreatedBy   string `db:"created_by"`
 }
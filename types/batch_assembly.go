@@ -0,0 +1,72 @@
+package types
+
+import "time"
+
+type BatchType string
+
+const (
+	MonthlyBatchType   BatchType = "monthly"
+	QuarterlyBatchType BatchType = "quarterly"
+	AnnualBatchType    BatchType = "annual"
+)
+
+/*
+Assembler combines the GB and NI rows of the monthly batches of a month, quarter or year into one dataset. Rows are
+added latest first and Add returns the row as it is to be stored, or false if it is left out of the dataset.
+*/
+type Assembler interface {
+	Add(source FileSource, columns map[string]interface{}) (map[string]interface{}, bool)
+	Audit() BatchAudit
+	Conflicts() []BatchConflict
+}
+
+/*
+BatchAudit records what went into the dataset of a batch: the UK dataset of a month or the dataset of a quarter
+or year. Period is the month or quarter, or 0 for a year.
+*/
+type BatchAudit struct {
+	Id          int       `db:"id,omitempty" json:"id"`
+	BatchType   BatchType `db:"batch_type" json:"batchType"`
+	BatchId     int       `db:"batch_id" json:"batchId"`
+	Year        int       `db:"year" json:"year"`
+	Period      int       `db:"period" json:"period"`
+	GBRows      int       `db:"gb_rows" json:"gbRows"`
+	NIRows      int       `db:"ni_rows" json:"niRows"`
+	Duplicates  int       `db:"duplicates" json:"duplicates"`
+	Rows        int       `db:"rows" json:"rows"`
+	Variables   int       `db:"variables" json:"variables"`
+	Converted   int       `db:"converted" json:"converted"`
+	AssembledAt time.Time `db:"assembled_at" json:"assembledAt"`
+	AssembledBy string    `db:"assembled_by" json:"assembledBy"`
+
+	Conflicts []BatchConflict `db:"-" json:"conflicts,omitempty"`
+}
+
+/*
+BatchConflict is a difference between the GB and NI data that the dataset of a batch had to settle, with the
+number of rows it affected
+*/
+type BatchConflict struct {
+	Id       int        `db:"id,omitempty" json:"-"`
+	AuditId  int        `db:"audit_id" json:"-"`
+	Variable string     `db:"variable" json:"variable"`
+	Source   FileSource `db:"source" json:"source"`
+	Conflict string     `db:"conflict" json:"conflict"`
+	Rows     int        `db:"rows" json:"rows"`
+}
+
+// BatchCreated is a batch and who created it. Period is the month or quarter, or 0 for a year.
+type BatchCreated struct {
+	BatchType   BatchType `db:"batch_type" json:"batchType"`
+	Id          int       `db:"id" json:"id"`
+	Year        int       `db:"year" json:"year"`
+	Period      int       `db:"period" json:"period"`
+	Description string    `db:"description" json:"description"`
+	CreatedBy   string    `db:"created_by" json:"createdBy"`
+}
+
+// BatchAudits are the batches created and the datasets assembled for them, each with its conflicts
+type BatchAudits struct {
+	Batches    []BatchCreated `json:"batches"`
+	Assemblies []BatchAudit   `json:"assemblies"`
+}
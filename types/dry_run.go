@@ -0,0 +1,30 @@
+package types
+
+type RenamedColumn struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+/*
+DryRunSummary describes what loading a survey file would do without loading it. NewVariables are in the file
+but not in variable_definitions and MissingVariables are in variable_definitions but not in the file.
+*/
+type DryRunSummary struct {
+	FileName          string              `json:"fileName"`
+	FileSource        FileSource          `json:"fileSource"`
+	Week              int                 `json:"week"`
+	Month             int                 `json:"month"`
+	Year              int                 `json:"year"`
+	Valid             bool                `json:"valid"`
+	ValidationMessage string              `json:"validationMessage"`
+	ValidationReport  []ValidationFailure `json:"validationReport"`
+	RowsIn            int                 `json:"rowsIn"`
+	RowsOut           int                 `json:"rowsOut"`
+	VariablesIn       int                 `json:"variablesIn"`
+	VariablesOut      int                 `json:"variablesOut"`
+	SkipCounts        SkipCounts          `json:"skipCounts"`
+	Renamed           []RenamedColumn     `json:"renamed"`
+	Dropped           []string            `json:"dropped"`
+	NewVariables      []string            `json:"newVariables"`
+	MissingVariables  []string            `json:"missingVariables"`
+}
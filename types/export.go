@@ -0,0 +1,119 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+Audience is who a dataset is exported for. Each audience is a column of the export_definitions table that
+says whether a variable can be given to them.
+*/
+type Audience string
+
+const (
+	Research       Audience = "research"
+	RegionalClient Audience = "regional_client"
+	Government     Audience = "government"
+	SpecialLicense Audience = "special_license"
+	EndUser        Audience = "end_user"
+	Adhoc          Audience = "adhoc"
+)
+
+var Audiences = []Audience{Research, RegionalClient, Government, SpecialLicense, EndUser, Adhoc}
+
+type ExportDefinition struct {
+	Variable       string `db:"variables" json:"variable"`
+	Research       bool   `db:"research" json:"research"`
+	RegionalClient bool   `db:"regional_client" json:"regionalClient"`
+	Government     bool   `db:"government" json:"government"`
+	SpecialLicense bool   `db:"special_license" json:"specialLicense"`
+	EndUser        bool   `db:"end_user" json:"endUser"`
+	Adhoc          bool   `db:"adhoc" json:"adhoc"`
+}
+
+/*
+ExportPeriod is the month, quarter or year of monthly batches that are exported. A year has neither Quarter
+nor Month set.
+*/
+type ExportPeriod struct {
+	Year    int
+	Quarter int
+	Month   int
+}
+
+// Months returns the first and last month of the period
+func (p ExportPeriod) Months() (int, int) {
+	switch {
+	case p.Month > 0:
+		return p.Month, p.Month
+	case p.Quarter > 0:
+		return p.Quarter*3 - 2, p.Quarter * 3
+	}
+	return 1, 12
+}
+
+func (p ExportPeriod) String() string {
+	switch {
+	case p.Month > 0:
+		return fmt.Sprintf("%d-%02d", p.Year, p.Month)
+	case p.Quarter > 0:
+		return fmt.Sprintf("%d-Q%d", p.Year, p.Quarter)
+	}
+	return fmt.Sprintf("%d", p.Year)
+}
+
+// Allowed reports whether the variable can be exported to an audience
+func (d ExportDefinition) Allowed(audience Audience) bool {
+	switch audience {
+	case Research:
+		return d.Research
+	case RegionalClient:
+		return d.RegionalClient
+	case Government:
+		return d.Government
+	case SpecialLicense:
+		return d.SpecialLicense
+	case EndUser:
+		return d.EndUser
+	case Adhoc:
+		return d.Adhoc
+	}
+	return false
+}
+
+// the layout of the export definitions CSV file, with each audience flag as 1 or 0
+type ExportDefinitionCSV struct {
+	Variable       string `csv:"VARIABLES"`
+	Research       int    `csv:"RESEARCH"`
+	RegionalClient int    `csv:"REGIONAL_CLIENT"`
+	Government     int    `csv:"GOVERNMENT"`
+	SpecialLicense int    `csv:"SPECIAL_LICENSE"`
+	EndUser        int    `csv:"END_USER"`
+	Adhoc          int    `csv:"ADHOC"`
+}
+
+type ExportDefinitionAction string
+
+const (
+	ExportDefinitionCreated ExportDefinitionAction = "created"
+	ExportDefinitionUpdated ExportDefinitionAction = "updated"
+	ExportDefinitionDeleted ExportDefinitionAction = "deleted"
+)
+
+// a change to a variable's audience flags
+type ExportDefinitionAudit struct {
+	Id        int                    `db:"id,omitempty" json:"id"`
+	Variable  string                 `db:"variable" json:"variable"`
+	Action    ExportDefinitionAction `db:"action" json:"action"`
+	Changes   string                 `db:"changes" json:"changes"`
+	ChangedBy string                 `db:"changed_by" json:"changedBy"`
+	ChangedAt time.Time              `db:"changed_at" json:"changedAt"`
+}
+
+// the result of importing an export definitions file
+type ExportDefinitionsImport struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
@@ -0,0 +1,33 @@
+package types
+
+/*
+Role is what a user is allowed to do. Every role can view the data, and an admin can do everything.
+*/
+type Role string
+
+const (
+	Viewer         Role = "viewer"
+	Uploader       Role = "uploader"
+	BatchManager   Role = "batch-manager"
+	MetadataEditor Role = "metadata-editor"
+	Admin          Role = "admin"
+)
+
+var Roles = []Role{Viewer, Uploader, BatchManager, MetadataEditor, Admin}
+
+/*
+HasRole reports whether roles allow what needs one of allowed. Any role allows viewing and admin allows anything.
+*/
+func HasRole(roles []Role, allowed ...Role) bool {
+	for _, r := range roles {
+		if r == Admin {
+			return true
+		}
+		for _, a := range allowed {
+			if r == a || a == Viewer {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package types
+
+import "time"
+
+/*
+Session is a login. The token is only known when it is issued, as only its hash is stored. ExpiresAt is moved on
+each time the token is refreshed, up to the session lifetime after CreatedAt. Roles are those of the user when
+the session was last used.
+*/
+type Session struct {
+	Token     string    `db:"-" json:"token,omitempty"`
+	TokenHash string    `db:"token_hash" json:"-"`
+	Username  string    `db:"username" json:"username"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ExpiresAt time.Time `db:"expires_at" json:"expiresAt"`
+	Roles     []Role    `db:"-" json:"roles"`
+}
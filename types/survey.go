@@ -56,10 +56,28 @@ type SavImportData struct {
 	RowCount int
 }
 
+/*
+RowHandler receives a survey file as it is streamed. Header is called once, before the first row, and Row is
+then called with each row in the order they are in the file. Returning an error from either stops the file
+being read.
+*/
+type RowHandler interface {
+	Header(header []Header) error
+	Row(row []string) error
+}
+
+// RowReader streams a file to a RowHandler, returning the file's header, labels and row count but no rows
+type RowReader func(handler RowHandler) (SavImportData, error)
+
+// RowSource passes each row of a survey to emit, in order
+type RowSource func(emit func(row []string) error) error
+
 type SurveyVO struct {
-	Audit  *Audit
-	Data   SavImportData
-	Status *WSMessage
+	Audit    *Audit
+	Header   []Header
+	RowCount int
+	Rows     RowSource
+	Status   *WSMessage
 }
 
 type SurveyRow struct {
@@ -69,6 +87,7 @@ type SurveyRow struct {
 	Week       int        `db:"week"`
 	Month      int        `db:"month"`
 	Year       int        `db:"year"`
+	VersionId  int        `db:"version_id"`
 	Columns    string     `db:"columns"`
 }
 
@@ -0,0 +1,53 @@
+package types
+
+/*
+Dataset is the data a query, tabulation or export reads: the current survey rows as loaded, the UK dataset of
+each month or the dataset of a quarterly or annual batch
+*/
+type Dataset string
+
+const (
+	SurveyDataset    Dataset = "survey"
+	UKDataset        Dataset = "uk"
+	QuarterlyDataset Dataset = "quarterly"
+	AnnualDataset    Dataset = "annual"
+)
+
+var Datasets = []Dataset{SurveyDataset, UKDataset, QuarterlyDataset, AnnualDataset}
+
+// Operator is a comparison a survey query can filter on
+type Operator string
+
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "!="
+	Less           Operator = "<"
+	LessOrEqual    Operator = "<="
+	Greater        Operator = ">"
+	GreaterOrEqual Operator = ">="
+)
+
+/*
+SurveyCondition compares a variable with a value, a float64 for a numeric variable and a string otherwise. As in
+the expression language, a comparison with a missing value is false.
+*/
+type SurveyCondition struct {
+	Variable string
+	Operator Operator
+	Value    interface{}
+}
+
+/*
+SurveyQuery selects a page of the rows of a dataset for a period, or of one of its batches if BatchId is set,
+keeping the rows that meet every condition. Only the variables listed are returned, or every column of a row if
+none are. Pages follow the row key: a page has the rows after the key After, the last key of the page before.
+*/
+type SurveyQuery struct {
+	Dataset    Dataset
+	Period     ExportPeriod
+	BatchId    int
+	Variables  []string
+	Conditions []SurveyCondition
+	Limit      int
+	After      int64
+}
@@ -0,0 +1,43 @@
+package types
+
+/*
+SurveyTabulation cross-tabulates the rows of a dataset for a period that meet every condition, by the values of
+one variable or of two. Each row counts as its weight, or as 1 if no weight variable is given, and Value is the
+numeric variable whose weighted sum and mean are taken, if any.
+*/
+type SurveyTabulation struct {
+	Dataset    Dataset
+	Period     ExportPeriod
+	Conditions []SurveyCondition
+	Rows       string
+	Columns    string
+	Weight     string
+	Value      string
+}
+
+/*
+TabulationCell is one cell of a tabulation. A row without a weight is counted but not weighted, and a missing
+value is left out of the sum and mean.
+*/
+type TabulationCell struct {
+	Row           interface{} `json:"row"`
+	RowLabel      string      `json:"rowLabel,omitempty"`
+	Column        interface{} `json:"column,omitempty"`
+	ColumnLabel   string      `json:"columnLabel,omitempty"`
+	Count         int         `json:"count"`
+	WeightedCount float64     `json:"weightedCount"`
+	WeightedSum   *float64    `json:"weightedSum,omitempty"`
+	WeightedMean  *float64    `json:"weightedMean,omitempty"`
+}
+
+type Tabulation struct {
+	Dataset       Dataset          `json:"dataset"`
+	Period        string           `json:"period"`
+	Rows          string           `json:"rows"`
+	Columns       string           `json:"columns,omitempty"`
+	Weight        string           `json:"weight,omitempty"`
+	Value         string           `json:"value,omitempty"`
+	Count         int              `json:"count"`
+	WeightedCount float64          `json:"weightedCount"`
+	Cells         []TabulationCell `json:"cells"`
+}
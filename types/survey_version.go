@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// SurveyVersion is one load of a GB week or NI month. Only the current version of a period is used.
+type SurveyVersion struct {
+	VersionId  int        `db:"version_id,omitempty" json:"versionId"`
+	Id         int        `db:"id" json:"id"`
+	FileName   string     `db:"file_name" json:"fileName"`
+	FileSource FileSource `db:"file_source" json:"fileSource"`
+	Week       int        `db:"week" json:"week"`
+	Month      int        `db:"month" json:"month"`
+	Year       int        `db:"year" json:"year"`
+	Version    int        `db:"version" json:"version"`
+	IsCurrent  bool       `db:"is_current" json:"isCurrent"`
+	Rows       int        `db:"rows" json:"rows"`
+	AuditId    int        `db:"audit_id" json:"auditId"`
+	LoadedAt   time.Time  `db:"loaded_at" json:"loadedAt"`
+}
+
+// SurveyVersionDiff compares two versions of a period. Respondents are matched on CASENO.
+type SurveyVersionDiff struct {
+	From          SurveyVersion `json:"from"`
+	To            SurveyVersion `json:"to"`
+	RowDifference int           `json:"rowDifference"`
+	RowsAdded     int           `json:"rowsAdded"`
+	RowsRemoved   int           `json:"rowsRemoved"`
+	RowsInBoth    int           `json:"rowsInBoth"`
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type JobType string
+
+const (
+	GBSurveyJob            JobType = "gb_survey"
+	NISurveyJob            JobType = "ni_survey"
+	AddressJob             JobType = "address"
+	VariableDefinitionsJob JobType = "variable_definitions"
+	ValueLabelsJob         JobType = "value_labels"
+	SurveyDryRunJob        JobType = "survey_dry_run"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobFinished  JobStatus = "finished"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+type UploadJob struct {
+	Id           int        `db:"id,omitempty" json:"id"`
+	JobType      JobType    `db:"job_type" json:"jobType"`
+	FileName     string     `db:"file_name" json:"fileName"`
+	FilePath     string     `db:"file_path" json:"-"`
+	FileSource   FileSource `db:"file_source" json:"fileSource"`
+	BatchId      int        `db:"batch_id" json:"batchId"`
+	Week         int        `db:"week" json:"week"`
+	Month        int        `db:"month" json:"month"`
+	Year         int        `db:"year" json:"year"`
+	Status       JobStatus  `db:"status" json:"status"`
+	Attempts     int        `db:"attempts" json:"attempts"`
+	ErrorMessage string     `db:"error_message" json:"errorMessage"`
+	CreatedAt    time.Time  `db:"created_at" json:"createdAt"`
+	StartedAt    *time.Time `db:"started_at" json:"startedAt,omitempty"`
+	FinishedAt   *time.Time `db:"finished_at" json:"finishedAt,omitempty"`
+	Heartbeat    *time.Time `db:"heartbeat" json:"-"`
+	SubmittedBy  string     `db:"submitted_by" json:"submittedBy"`
+	Result       JobResult  `db:"result" json:"result,omitempty"`
+}
+
+// JobResult is what a job returns, such as the summary of a dry run, stored and returned as JSON
+type JobResult []byte
+
+func (r JobResult) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+func (r JobResult) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	return string(r), nil
+}
+
+func (r *JobResult) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		*r = append(JobResult(nil), v...)
+		return nil
+	case string:
+		*r = JobResult(v)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into JobResult", src)
+}
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// User is an account as an admin sees it. The password hash is never returned.
+type User struct {
+	Username          string     `db:"username" json:"username"`
+	Disabled          bool       `db:"disabled" json:"disabled"`
+	CreatedAt         time.Time  `db:"created_at" json:"createdAt"`
+	PasswordChangedAt time.Time  `db:"password_changed_at" json:"passwordChangedAt"`
+	LastLogin         *time.Time `db:"last_login" json:"lastLogin"`
+	FailedLogins      int        `db:"failed_logins" json:"failedLogins"`
+	LockedAt          *time.Time `db:"locked_at" json:"lockedAt"`
+	Roles             []Role     `db:"-" json:"roles"`
+}
+
+// NewUser is the body of a request to create a user
+type NewUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Roles    []Role `json:"roles"`
+}
+
+// PasswordChange is the body of a request to change or reset a password. Current is only needed to change one's own.
+type PasswordChange struct {
+	Current  string `json:"currentPassword"`
+	Password string `json:"password"`
+}
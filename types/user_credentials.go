@@ -1,6 +1,10 @@
 package types
 
+import "time"
+
 type UserCredentials struct {
-	Username string `validate:"nonzero" db:"username"`
-	Password string `validate:"nonzero" db:"password"`
+	Username string     `validate:"nonzero" db:"username"`
+	Password string     `validate:"nonzero" db:"password"`
+	Disabled bool       `db:"disabled"`
+	LockedAt *time.Time `db:"locked_at"`
 }
@@ -0,0 +1,34 @@
+package types
+
+// Validation rules reported in a validation report
+const (
+	RuleRequiredColumn = "requiredColumn"
+	RuleMissingValue   = "missingValue"
+	RuleNumeric        = "numeric"
+	RuleNoRows         = "noRows"
+	RuleSameRefDate    = "sameReferenceDate"
+	RuleRefDateSunday  = "referenceDateSunday"
+	RuleRefDateWeek    = "referenceDateWeek"
+	RuleRefDateMonth   = "referenceDateMonth"
+	RuleRefDateYear    = "referenceDateYear"
+	RuleNumberOfWeeks  = "numberOfWeeks"
+	RuleRange          = "range"
+	RuleValueLabel     = "valueLabel"
+	RuleCondition      = "condition"
+	RuleUnique         = "unique"
+)
+
+/*
+ValidationFailure is one line of a validation report. Row is the 1 based row number in the file, or 0 where
+the failure applies to the file as a whole. RuleName is the name of the configured rule that failed.
+*/
+type ValidationFailure struct {
+	AuditId  int    `db:"audit_id" json:"-" csv:"-"`
+	RuleName string `db:"rule_name" json:"ruleName" csv:"ruleName"`
+	Rule     string `db:"rule" json:"rule" csv:"rule"`
+	Column   string `db:"column_name" json:"column" csv:"column"`
+	Row      int    `db:"row_number" json:"row" csv:"row"`
+	CaseNo   string `db:"caseno" json:"caseno" csv:"caseno"`
+	Value    string `db:"value" json:"value" csv:"value"`
+	Message  string `db:"message" json:"message" csv:"message"`
+}
@@ -10,6 +10,7 @@ type ValueLabelsRow struct {
 	Source       string    `db:"source" json:"source"`
 	VariableType SavType   `db:"type" json:"type"`
 	LastUpdated  time.Time `db:"last_updated" json:"last_updated"`
+	UpdatedBy    string    `db:"updated_by" json:"updated_by"`
 }
 
 type ValueLabelsView struct {
@@ -19,6 +20,7 @@ type ValueLabelsView struct {
 	LabelValue       int       `db:"label_value"  json:"label_value"`
 	LabelDescription SavType   `db:"label_description" json:"description"`
 	LastUpdated      time.Time `db:"last_updated" json:"last_updated"`
+	UpdatedBy        string    `db:"updated_by" json:"updated_by"`
 }
 
 type ValueLabelsImport struct {
@@ -19,6 +19,7 @@ type VariableDefinitions struct {
 	Imputation     bool           `db:"imputation"`
 	DV             bool           `db:"dv" `
 	ValidFrom      time.Time      `db:"valid_from"`
+	ChangedBy      string         `db:"changed_by"`
 }
 
 type VariableDefinitionsQuery struct {
@@ -34,6 +35,7 @@ type VariableDefinitionsQuery struct {
 	Imputation     bool      `json:"imputation"`
 	DV             bool      `json:"dv"`
 	ValidFrom      time.Time `json:"validFrom"`
+	ChangedBy      string    `json:"changedBy"`
 }
 
 type VariableDefinitionsImport struct {
@@ -9,50 +9,126 @@ const (
 	NotFound
 )
 
+// Events pushed to web socket subscribers as an upload progresses
+const (
+	EventQueued           = "queued"
+	EventStarted          = "started"
+	EventProgress         = "progress"
+	EventRowCount         = "rowCount"
+	EventValidationFailed = "validationFailed"
+	EventFinished         = "finished"
+	EventError            = "error"
+	EventCancelled        = "cancelled"
+)
+
+// WSMessage is the status of the upload job JobId, which loads the file Filename
 type WSMessage struct {
-	Filename     string  `json:"fileName"`
-	Percentage   float64 `json:"percent"`
-	Status       int     `json:"status"`
-	ErrorMessage string  `json:"errorMessage, omitempty"`
-}
+	JobId        int     `db:"job_id" json:"jobId"`
+	Filename     string  `db:"file_name" json:"fileName"`
+	Percentage   float64 `db:"percentage" json:"percent"`
+	Status       int     `db:"status" json:"status"`
+	ErrorMessage string  `db:"error_message" json:"errorMessage,omitempty"`
+	RowsInFile   int     `db:"rows_in_file" json:"rowsInFile,omitempty"`
+	RowsLoaded   int     `db:"rows_loaded" json:"rowsLoaded,omitempty"`
+	Event        string  `db:"-" json:"event,omitempty"`
 
-type Persist func()
+	persistStatus Persist
+	persisted     float64
+}
 
-var persistStatus Persist
+type Persist func(*WSMessage)
 
-func NewWSMessage(fileName string, persist Persist) *WSMessage {
-	persistStatus = persist
+func NewWSMessage(jobId int, fileName string, persist Persist) *WSMessage {
 	return &WSMessage{
-		Filename:     fileName,
-		Percentage:   0,
-		Status:       Idle,
-		ErrorMessage: "",
+		JobId:         jobId,
+		Filename:      fileName,
+		Percentage:    0,
+		Status:        Idle,
+		ErrorMessage:  "",
+		persistStatus: persist,
 	}
 }
 
+func (up *WSMessage) persist() {
+	up.persisted = up.Percentage
+	if up.persistStatus != nil {
+		up.persistStatus(up)
+	}
+}
+
+// SetPercentage is called for every row loaded so the status is only persisted when the
+// percentage moves on by a whole step.
 func (up *WSMessage) SetPercentage(percentage float64) {
 	up.Percentage = percentage
-	persistStatus()
+	if int(percentage) == int(up.persisted) {
+		return
+	}
+	up.Event = EventProgress
+	up.persist()
+}
+
+func (up *WSMessage) SetQueued() {
+	up.Status = Idle
+	up.Percentage = 0
+	up.ErrorMessage = ""
+	up.Event = EventQueued
+	up.persist()
 }
 
 func (up *WSMessage) SetUploadStarted() {
 	up.Status = UploadStarted
-	persistStatus()
+	up.Event = EventStarted
+	up.persist()
+}
+
+// SetRowCounts reports the number of rows read from the file and, once filtering has been done, the number
+// that will be loaded
+func (up *WSMessage) SetRowCounts(inFile, loaded int) {
+	up.RowsInFile = inFile
+	up.RowsLoaded = loaded
+	up.Event = EventRowCount
+	up.persist()
+}
+
+// SetValidationFailed reports a validation failure. The upload itself is marked as failed by SetUploadError.
+func (up *WSMessage) SetValidationFailed(errorMessage string) {
+	up.ErrorMessage = errorMessage
+	up.Event = EventValidationFailed
+	up.persist()
 }
 
 func (up *WSMessage) SetUploadFinished() {
 	up.Status = UploadFinished
 	up.Percentage = 100
-	persistStatus()
+	up.Event = EventFinished
+	up.persist()
 }
 
 func (up *WSMessage) SetUploadError(errorMessage string) {
 	up.Status = UploadError
 	up.ErrorMessage = errorMessage
-	persistStatus()
+	up.Event = EventError
+	up.persist()
 }
 
 func (up *WSMessage) SetUploadCancelled() {
 	up.Status = UploadCancelled
-	persistStatus()
+	up.Event = EventCancelled
+	up.persist()
+}
+
+// Subscription actions a web socket client can send
+const (
+	Subscribe   = "subscribe"
+	Unsubscribe = "unsubscribe"
+)
+
+// WSRequest is sent by web socket clients. A request without an action asks for the current status of the
+// job jobId or, without one, of the latest upload of fileName. Subscribing to a job, a fileName or all uploads
+// pushes every status change to the client as it happens.
+type WSRequest struct {
+	Action   string `json:"action,omitempty"`
+	JobId    int    `json:"jobId,omitempty"`
+	Filename string `json:"fileName"`
+	All      bool   `json:"all,omitempty"`
 }
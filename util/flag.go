@@ -0,0 +1,9 @@
+package util
+
+// Flag is a bool as the 1 or 0 it is held as in files and audit messages
+func Flag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,19 @@
+package util
+
+/*
+HSerial identifies a household. It is built from the sample identifiers held on every survey record
+*/
+func HSerial(quota, week, w1yr, qrtr, addr, wavfnd, hhld float64) int64 {
+	n := (quota * 1000000000) + (week * 10000000) + (w1yr * 1000000) +
+		(qrtr * 100000) + (addr * 1000) + (wavfnd * 100) + (hhld + 1)
+	return int64(n)
+}
+
+/*
+CaseNo identifies a respondent: the household identifiers followed by the person number
+*/
+func CaseNo(quota, week, w1yr, qrtr, addr, wavfnd, hhld, persno float64) int64 {
+	n := (quota * 100000000000) + (week * 1000000000) + (w1yr * 100000000) +
+		(qrtr * 10000000) + (addr * 100000) + (wavfnd * 10000) + (hhld * 100) + persno
+	return int64(n)
+}